@@ -19,7 +19,11 @@ func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer cancel()
 
-	server := server.New(*env)
+	server, err := server.New(*env)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	err = server.Start(ctx)
 	if err != nil {
 		log.Fatal(err)
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/adamelfsborg-code/movie-nest/pkg/omdb/fake"
+)
+
+// fakeomdb serves the recorded OMDb fixtures, start the API with
+// OMDB_BASE_URL pointing at it to run without network access.
+func main() {
+	addr := flag.String("addr", "localhost:8091", "address to listen on")
+	flag.Parse()
+
+	fmt.Printf("Fake OMDb listening on http://%v\n", *addr)
+	log.Fatal(http.ListenAndServe(*addr, fake.NewHandler()))
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/adamelfsborg-code/movie-nest/pkg/themoviedb/fake"
+)
+
+// faketmdb serves the recorded TMDB fixtures, start the API with
+// MOVIEDB_BASE_URL pointing at it to run without network access.
+func main() {
+	addr := flag.String("addr", "localhost:8090", "address to listen on")
+	flag.Parse()
+
+	fmt.Printf("Fake TMDB listening on http://%v\n", *addr)
+	log.Fatal(http.ListenAndServe(*addr, fake.NewHandler()))
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+
+	"github.com/adamelfsborg-code/movie-nest/config"
+	"github.com/adamelfsborg-code/movie-nest/db"
+	"github.com/adamelfsborg-code/movie-nest/db/migrations"
+)
+
+const usage = "Usage: migrate up | down [steps] | status"
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatal(usage)
+	}
+
+	env, err := config.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	database := db.Connect(*env)
+	defer database.Close()
+
+	migrator, err := migrations.New(database)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch os.Args[1] {
+	case "up":
+		done, err := migrator.Up(ctx)
+		for _, migration := range done {
+			fmt.Printf("Applied %v_%v\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(done) == 0 {
+			fmt.Println("Schema is up to date")
+		}
+	case "down":
+		steps := 1
+		if len(os.Args) > 2 {
+			steps, err = strconv.Atoi(os.Args[2])
+			if err != nil || steps < 1 {
+				log.Fatalf("Invalid steps: %v", os.Args[2])
+			}
+		}
+
+		done, err := migrator.Down(ctx, steps)
+		for _, migration := range done {
+			fmt.Printf("Reverted %v_%v\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = fmt.Sprintf("applied %v", status.AppliedAt.Format("2006-01-02 15:04:05"))
+			}
+			fmt.Printf("%v_%v\t%v\n", status.Version, status.Name, state)
+		}
+	default:
+		log.Fatal(usage)
+	}
+}
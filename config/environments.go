@@ -3,20 +3,48 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+const (
+	EventBusNats  = "nats"
+	EventBusLocal = "local"
+	EventBusNoop  = "noop"
+)
+
 type Environments struct {
 	ServerAddr       string
+	DebugAddr        string
 	DatabaseAddr     string
 	DatabaseUser     string
 	DatabasePassword string
 	DatabaseName     string
 	MovieDBApiKey    string
 	MovieDBAuthToken string
+	MovieDBBaseURL   string
+	MovieDBTimeout   time.Duration
+	MovieDBLogSample float64
+	MovieDBLanguage  string
+	MovieDBRegion    string
+	OMDbApiKey       string
+	OMDbBaseURL      string
+	ImageBaseURL     string
+	ImageCacheDir    string
+	ImageCacheSize   int64
+	InviteBaseURL    string
+	AllowedOrigins   []string
 	SecretKey        []byte
 	NatsAddr         string
+	NatsQueueGroup   string
+	EventBus         string
+	EventHistoryAge  time.Duration
+	MovieMetadataTTL time.Duration
+	DemoMode         bool
 }
 
 var Env *Environments
@@ -33,23 +61,37 @@ func New() (*Environments, error) {
 		return nil, fmt.Errorf("SERVER_ADDR not found.")
 	}
 
-	databaseAddr, exists := os.LookupEnv("DATABASE_ADDR")
+	debugAddr, exists := os.LookupEnv("DEBUG_ADDR")
 	if exists == false {
+		debugAddr = "127.0.0.1:6060"
+	}
+
+	demoMode := false
+	demoModeParam, exists := os.LookupEnv("DEMO_MODE")
+	if exists {
+		demoMode, err = strconv.ParseBool(demoModeParam)
+		if err != nil {
+			return nil, fmt.Errorf("DEMO_MODE is not a valid bool: %w", err)
+		}
+	}
+
+	databaseAddr, exists := os.LookupEnv("DATABASE_ADDR")
+	if exists == false && demoMode == false {
 		return nil, fmt.Errorf("DATABASE_ADDR not found.")
 	}
 
 	databaseUser, exists := os.LookupEnv("DATABASE_USER")
-	if exists == false {
+	if exists == false && demoMode == false {
 		return nil, fmt.Errorf("DATABASE_USER not found.")
 	}
 
 	databasePassword, exists := os.LookupEnv("DATABASE_PASSWORD")
-	if exists == false {
+	if exists == false && demoMode == false {
 		return nil, fmt.Errorf("DATABASE_PASSWORD not found.")
 	}
 
 	databaseName, exists := os.LookupEnv("DATABASE_NAME")
-	if exists == false {
+	if exists == false && demoMode == false {
 		return nil, fmt.Errorf("DATABASE_NAME not found.")
 	}
 
@@ -63,26 +105,139 @@ func New() (*Environments, error) {
 		return nil, fmt.Errorf("MOVIEDB_AUTH_TOKEN not found")
 	}
 
+	movieDBBaseURL, _ := os.LookupEnv("MOVIEDB_BASE_URL")
+
+	var movieDBTimeout time.Duration
+	movieDBTimeoutParam, exists := os.LookupEnv("MOVIEDB_TIMEOUT")
+	if exists {
+		movieDBTimeout, err = time.ParseDuration(movieDBTimeoutParam)
+		if err != nil {
+			return nil, fmt.Errorf("MOVIEDB_TIMEOUT is not a valid duration: %w", err)
+		}
+	}
+
+	movieDBLogSample := 1.0
+	movieDBLogSampleParam, exists := os.LookupEnv("MOVIEDB_LOG_SAMPLE_RATE")
+	if exists {
+		movieDBLogSample, err = strconv.ParseFloat(movieDBLogSampleParam, 64)
+		if err != nil || movieDBLogSample < 0 || movieDBLogSample > 1 {
+			return nil, fmt.Errorf("MOVIEDB_LOG_SAMPLE_RATE must be between 0 and 1")
+		}
+	}
+
+	movieDBLanguage, exists := os.LookupEnv("MOVIEDB_LANGUAGE")
+	if exists == false {
+		movieDBLanguage = "en-US"
+	}
+
+	movieDBRegion, _ := os.LookupEnv("MOVIEDB_REGION")
+
+	omdbApiKey, _ := os.LookupEnv("OMDB_API_KEY")
+	omdbBaseURL, _ := os.LookupEnv("OMDB_BASE_URL")
+
+	imageBaseURL, _ := os.LookupEnv("IMAGE_BASE_URL")
+
+	imageCacheDir, exists := os.LookupEnv("IMAGE_CACHE_DIR")
+	if exists == false {
+		imageCacheDir = filepath.Join(os.TempDir(), "movie-nest-images")
+	}
+
+	imageCacheSize := int64(512)
+	imageCacheSizeParam, exists := os.LookupEnv("IMAGE_CACHE_SIZE_MB")
+	if exists {
+		imageCacheSize, err = strconv.ParseInt(imageCacheSizeParam, 10, 64)
+		if err != nil || imageCacheSize <= 0 {
+			return nil, fmt.Errorf("IMAGE_CACHE_SIZE_MB must be a positive number")
+		}
+	}
+
+	inviteBaseURL, _ := os.LookupEnv("INVITE_BASE_URL")
+
+	allowedOrigins := []string{"http://localhost:5173"}
+	allowedOriginsParam, exists := os.LookupEnv("ALLOWED_ORIGINS")
+	if exists {
+		allowedOrigins = strings.Split(allowedOriginsParam, ",")
+		for i := range allowedOrigins {
+			allowedOrigins[i] = strings.TrimSpace(allowedOrigins[i])
+		}
+	}
+
 	secretKey, exists := os.LookupEnv("SECRET_KEY")
 	if exists == false {
 		return nil, fmt.Errorf("SECRET_KEY not found")
 	}
 
-	natsAddr, exists := os.LookupEnv("NATS_ADDR")
+	natsAddr, natsExists := os.LookupEnv("NATS_ADDR")
+
+	natsQueueGroup, exists := os.LookupEnv("NATS_QUEUE_GROUP")
 	if exists == false {
-		return nil, fmt.Errorf("NATS_ADDR not found")
+		natsQueueGroup = "movie-nest"
+	}
+
+	eventBus, exists := os.LookupEnv("EVENT_BUS")
+	if exists == false {
+		eventBus = EventBusLocal
+		if natsExists {
+			eventBus = EventBusNats
+		}
+	}
+
+	switch eventBus {
+	case EventBusNats:
+		if natsExists == false {
+			return nil, fmt.Errorf("NATS_ADDR not found")
+		}
+	case EventBusLocal, EventBusNoop:
+	default:
+		return nil, fmt.Errorf("EVENT_BUS must be one of %v, %v or %v", EventBusNats, EventBusLocal, EventBusNoop)
+	}
+
+	eventHistoryAge := time.Hour * 24 * 30
+	eventHistoryAgeParam, exists := os.LookupEnv("EVENT_HISTORY_MAX_AGE")
+	if exists {
+		eventHistoryAge, err = time.ParseDuration(eventHistoryAgeParam)
+		if err != nil {
+			return nil, fmt.Errorf("EVENT_HISTORY_MAX_AGE is not a valid duration: %w", err)
+		}
+	}
+
+	movieMetadataTTL := time.Hour * 24
+	movieMetadataTTLParam, exists := os.LookupEnv("MOVIE_METADATA_TTL")
+	if exists {
+		movieMetadataTTL, err = time.ParseDuration(movieMetadataTTLParam)
+		if err != nil {
+			return nil, fmt.Errorf("MOVIE_METADATA_TTL is not a valid duration: %w", err)
+		}
 	}
 
 	env := &Environments{
 		ServerAddr:       serverAddr,
+		DebugAddr:        debugAddr,
 		DatabaseAddr:     databaseAddr,
 		DatabaseUser:     databaseUser,
 		DatabasePassword: databasePassword,
 		DatabaseName:     databaseName,
 		MovieDBApiKey:    movieDBApiKey,
 		MovieDBAuthToken: movieDBAuthToken,
+		MovieDBBaseURL:   movieDBBaseURL,
+		MovieDBTimeout:   movieDBTimeout,
+		MovieDBLogSample: movieDBLogSample,
+		MovieDBLanguage:  movieDBLanguage,
+		MovieDBRegion:    movieDBRegion,
+		OMDbApiKey:       omdbApiKey,
+		OMDbBaseURL:      omdbBaseURL,
+		ImageBaseURL:     imageBaseURL,
+		ImageCacheDir:    imageCacheDir,
+		ImageCacheSize:   imageCacheSize << 20,
+		InviteBaseURL:    inviteBaseURL,
+		AllowedOrigins:   allowedOrigins,
 		SecretKey:        []byte(secretKey),
 		NatsAddr:         natsAddr,
+		NatsQueueGroup:   natsQueueGroup,
+		EventBus:         eventBus,
+		EventHistoryAge:  eventHistoryAge,
+		MovieMetadataTTL: movieMetadataTTL,
+		DemoMode:         demoMode,
 	}
 
 	Env = env
@@ -0,0 +1,110 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/adamelfsborg-code/movie-nest/config"
+	"github.com/adamelfsborg-code/movie-nest/pkg/themoviedb"
+)
+
+// Availability tells how a shelf item can be watched in the room's
+// region. Subscribed lists the providers streaming it that the room has
+// a subscription to.
+type Availability struct {
+	Region     string                     `json:"region"`
+	Link       string                     `json:"link,omitempty"`
+	Stream     bool                       `json:"stream"`
+	Rent       bool                       `json:"rent"`
+	Buy        bool                       `json:"buy"`
+	Subscribed []themoviedb.WatchProvider `json:"subscribed"`
+}
+
+// Streamable is true when the item streams on one of the room's
+// subscriptions.
+func (a *Availability) Streamable() bool {
+	return a != nil && len(a.Subscribed) > 0
+}
+
+// NewSubscriptions validates the TMDB provider ids of a room's streaming
+// subscriptions, dropping duplicates.
+func NewSubscriptions(providerIDs []uint) ([]uint, error) {
+	seen := make(map[uint]struct{})
+	subscriptions := make([]uint, 0, len(providerIDs))
+	for _, id := range providerIDs {
+		if id == 0 {
+			return nil, fmt.Errorf("Invalid provider id %v", id)
+		}
+		if _, exists := seen[id]; exists {
+			continue
+		}
+		seen[id] = struct{}{}
+		subscriptions = append(subscriptions, id)
+	}
+
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i] < subscriptions[j]
+	})
+	return subscriptions, nil
+}
+
+// roomRegion is the region availability is looked up in, the room's or
+// the configured default.
+func roomRegion(env config.Environments, room Room) string {
+	return firstNonEmpty(room.Region, env.MovieDBRegion)
+}
+
+func newAvailability(providers themoviedb.WatchProviders, region string, subscriptions []uint) *Availability {
+	regionProviders := providers[region]
+
+	availability := &Availability{
+		Region:     region,
+		Link:       regionProviders.Link,
+		Stream:     len(regionProviders.Flatrate)+len(regionProviders.Free)+len(regionProviders.Ads) > 0,
+		Rent:       len(regionProviders.Rent) > 0,
+		Buy:        len(regionProviders.Buy) > 0,
+		Subscribed: make([]themoviedb.WatchProvider, 0),
+	}
+
+	for _, provider := range regionProviders.Flatrate {
+		for _, id := range subscriptions {
+			if provider.ID == id {
+				availability.Subscribed = append(availability.Subscribed, provider)
+				break
+			}
+		}
+	}
+
+	return availability
+}
+
+// attachAvailability sets the availability of movies with metadata in the
+// room's region, and narrows their watch providers down to that region.
+// Nothing is attached when neither the room nor the config has a region.
+func attachAvailability(movies []*Movie, env config.Environments, room Room) {
+	region := roomRegion(env, room)
+	if region == "" {
+		return
+	}
+
+	for _, movie := range movies {
+		if movie.Details == nil {
+			continue
+		}
+
+		movie.Availability = newAvailability(movie.Details.WatchProviders, region, room.Subscriptions)
+		movie.Details.WatchProviders = movie.Details.WatchProviders.Region(region)
+	}
+}
+
+// streamableMovies keeps the movies that stream on the room's
+// subscriptions.
+func streamableMovies(movies []Movie) []Movie {
+	streamable := make([]Movie, 0, len(movies))
+	for _, movie := range movies {
+		if movie.Availability.Streamable() {
+			streamable = append(streamable, movie)
+		}
+	}
+	return streamable
+}
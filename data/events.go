@@ -0,0 +1,217 @@
+package data
+
+import (
+	"github.com/adamelfsborg-code/movie-nest/events"
+	"github.com/google/uuid"
+)
+
+func roomCreatedEvent(room Room) *events.RoomCreated {
+	return &events.RoomCreated{
+		ID:        room.ID,
+		Name:      room.Name,
+		Timestamp: room.Timestamp,
+	}
+}
+
+func roomUpdatedEvent(room Room) *events.RoomUpdated {
+	return &events.RoomUpdated{
+		ID:            room.ID,
+		Name:          room.Name,
+		Language:      room.Language,
+		Region:        room.Region,
+		Subscriptions: room.Subscriptions,
+	}
+}
+
+func roomArchivedEvent(room Room) *events.RoomArchived {
+	return &events.RoomArchived{
+		ID:         room.ID,
+		Name:       room.Name,
+		ArchivedAt: *room.ArchivedAt,
+	}
+}
+
+func roomUnarchivedEvent(room Room) *events.RoomUnarchived {
+	return &events.RoomUnarchived{
+		ID:   room.ID,
+		Name: room.Name,
+	}
+}
+
+func roomOwnershipTransferredEvent(room Room, previousOwnerID uuid.UUID, owner User) *events.RoomOwnershipTransferred {
+	return &events.RoomOwnershipTransferred{
+		RoomID:          room.ID,
+		RoomName:        room.Name,
+		PreviousOwnerID: previousOwnerID,
+		OwnerID:         owner.ID,
+		OwnerName:       owner.Name,
+	}
+}
+
+func roomDeletedEvent(room Room) *events.RoomDeleted {
+	return &events.RoomDeleted{
+		ID:   room.ID,
+		Name: room.Name,
+	}
+}
+
+func roomMemberAddedEvent(room Room, user User, roomUser RoomUser) *events.RoomMemberAdded {
+	return &events.RoomMemberAdded{
+		RoomID:    room.ID,
+		RoomName:  room.Name,
+		UserID:    user.ID,
+		UserName:  user.Name,
+		Role:      string(roomUser.Role),
+		Timestamp: roomUser.Timestamp,
+	}
+}
+
+func roomMemberRoleChangedEvent(room Room, user User, previous, role Role) *events.RoomMemberRoleChanged {
+	return &events.RoomMemberRoleChanged{
+		RoomID:       room.ID,
+		RoomName:     room.Name,
+		UserID:       user.ID,
+		UserName:     user.Name,
+		PreviousRole: string(previous),
+		Role:         string(role),
+	}
+}
+
+func roomMemberJoinedEvent(room Room, user User, roomUser RoomUser, invite RoomInvite) *events.RoomMemberJoined {
+	return &events.RoomMemberJoined{
+		RoomID:    room.ID,
+		RoomName:  room.Name,
+		UserID:    user.ID,
+		UserName:  user.Name,
+		Role:      string(roomUser.Role),
+		InviteID:  invite.ID,
+		Timestamp: roomUser.Timestamp,
+	}
+}
+
+func roomMemberLeftEvent(room Room, user User) *events.RoomMemberLeft {
+	return &events.RoomMemberLeft{
+		RoomID:   room.ID,
+		RoomName: room.Name,
+		UserID:   user.ID,
+		UserName: user.Name,
+	}
+}
+
+func roomMemberRemovedEvent(room Room, user User) *events.RoomMemberRemoved {
+	return &events.RoomMemberRemoved{
+		RoomID:   room.ID,
+		RoomName: room.Name,
+		UserID:   user.ID,
+		UserName: user.Name,
+	}
+}
+
+func roomInvitationCreatedEvent(room Room, user, inviter User, invitation RoomInvitation) *events.RoomInvitationCreated {
+	return &events.RoomInvitationCreated{
+		ID:            invitation.ID,
+		RoomID:        room.ID,
+		RoomName:      room.Name,
+		UserID:        user.ID,
+		UserName:      user.Name,
+		InvitedBy:     inviter.ID,
+		InvitedByName: inviter.Name,
+		Role:          string(invitation.Role),
+		Timestamp:     invitation.Timestamp,
+	}
+}
+
+func roomInvitationUpdatedEvent(invitation RoomInvitation) *events.RoomInvitationUpdated {
+	event := &events.RoomInvitationUpdated{
+		ID:     invitation.ID,
+		RoomID: invitation.RoomID,
+		UserID: invitation.UserID,
+		Status: string(invitation.Status),
+	}
+	if invitation.RespondedAt != nil {
+		event.RespondedAt = *invitation.RespondedAt
+	}
+	return event
+}
+
+func shelfCreatedEvent(shelf Shelf) *events.ShelfCreated {
+	return &events.ShelfCreated{
+		ID:        shelf.ID,
+		RoomID:    shelf.RoomID,
+		Name:      shelf.Name,
+		Timestamp: shelf.Timestamp,
+	}
+}
+
+func shelfMovieAddedEvent(roomID uuid.UUID, movie Movie) *events.ShelfMovieAdded {
+	return &events.ShelfMovieAdded{
+		ID:           movie.ID,
+		RoomID:       roomID,
+		ShelfID:      movie.ShelfID,
+		MediaType:    movie.MediaType,
+		MovieID:      movie.MovieID,
+		SeasonNumber: movie.SeasonNumber,
+	}
+}
+
+func shelfMovieRatedEvent(roomID, shelfID uuid.UUID, rating MovieRating) *events.ShelfMovieRated {
+	return &events.ShelfMovieRated{
+		ID:        rating.ID,
+		RoomID:    roomID,
+		ShelfID:   shelfID,
+		MovieID:   rating.MovieID,
+		UserID:    rating.UserID,
+		Rating:    rating.Rating,
+		Timestamp: rating.Timestamp,
+	}
+}
+
+func roomShelvesReorderedEvent(roomID uuid.UUID, shelves []Shelf) *events.RoomShelvesReordered {
+	event := &events.RoomShelvesReordered{
+		RoomID:   roomID,
+		ShelfIDs: make([]uuid.UUID, 0, len(shelves)),
+	}
+	for _, shelf := range shelves {
+		event.ShelfIDs = append(event.ShelfIDs, shelf.ID)
+	}
+	return event
+}
+
+func shelfRenamedEvent(shelf Shelf) *events.ShelfRenamed {
+	return &events.ShelfRenamed{
+		ID:     shelf.ID,
+		RoomID: shelf.RoomID,
+		Name:   shelf.Name,
+	}
+}
+
+func shelfDeletedEvent(shelf Shelf) *events.ShelfDeleted {
+	return &events.ShelfDeleted{
+		ID:     shelf.ID,
+		RoomID: shelf.RoomID,
+		Name:   shelf.Name,
+	}
+}
+
+func shelfMovieRemovedEvent(roomID uuid.UUID, movie Movie) *events.ShelfMovieRemoved {
+	return &events.ShelfMovieRemoved{
+		ID:           movie.ID,
+		RoomID:       roomID,
+		ShelfID:      movie.ShelfID,
+		MediaType:    movie.MediaType,
+		MovieID:      movie.MovieID,
+		SeasonNumber: movie.SeasonNumber,
+	}
+}
+
+func shelfMovieMovedEvent(roomID, fromShelfID uuid.UUID, movie Movie) *events.ShelfMovieMoved {
+	return &events.ShelfMovieMoved{
+		ID:           movie.ID,
+		RoomID:       roomID,
+		ShelfID:      movie.ShelfID,
+		FromShelfID:  fromShelfID,
+		MediaType:    movie.MediaType,
+		MovieID:      movie.MovieID,
+		SeasonNumber: movie.SeasonNumber,
+	}
+}
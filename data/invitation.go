@@ -0,0 +1,335 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrBlocked          = errors.New("User does not accept invitations from you")
+	ErrAlreadyInvited   = errors.New("User already has a pending invitation to the room")
+	ErrInvitationClosed = errors.New("Invitation is no longer pending")
+)
+
+type InvitationStatus string
+
+const (
+	InvitationPending   InvitationStatus = "pending"
+	InvitationAccepted  InvitationStatus = "accepted"
+	InvitationDeclined  InvitationStatus = "declined"
+	InvitationCancelled InvitationStatus = "cancelled"
+)
+
+type InvitationData struct {
+	DB *pg.DB
+}
+
+// RoomInvitation asks UserID to join RoomID with Role. The membership is
+// only created once the user accepts.
+type RoomInvitation struct {
+	ID          uuid.UUID        `json:"id" db:"id"`
+	RoomID      uuid.UUID        `json:"room_id" db:"room_id"`
+	UserID      uuid.UUID        `json:"user_id" db:"user_id"`
+	InvitedBy   uuid.UUID        `json:"invited_by" db:"invited_by"`
+	Role        Role             `json:"role" db:"role"`
+	Status      InvitationStatus `json:"status" db:"status"`
+	RespondedAt *time.Time       `json:"responded_at,omitempty" db:"responded_at"`
+	Timestamp   time.Time        `json:"timestamp" db:"timestamp"`
+}
+
+// RoomInvitationInfo is a pending invitation with the names clients show.
+type RoomInvitationInfo struct {
+	RoomInvitation
+	RoomName      string `json:"room_name" db:"room_name"`
+	UserName      string `json:"user_name" db:"user_name"`
+	InvitedByName string `json:"invited_by_name" db:"invited_by_name"`
+}
+
+// UserBlock keeps BlockedUserID from inviting UserID to rooms.
+type UserBlock struct {
+	UserID        uuid.UUID `json:"user_id" db:"user_id" pg:",pk"`
+	BlockedUserID uuid.UUID `json:"blocked_user_id" db:"blocked_user_id" pg:",pk"`
+	Timestamp     time.Time `json:"timestamp" db:"timestamp"`
+}
+
+func NewRoomInvitation(roomID, userID uuid.UUID, role Role) *RoomInvitation {
+	return &RoomInvitation{
+		RoomID: roomID,
+		UserID: userID,
+		Role:   role,
+	}
+}
+
+const invitationInfoQuery = `
+	SELECT i.*, r.name AS room_name, u.name AS user_name, COALESCE(b.name, '') AS invited_by_name
+	FROM room_invitations i
+	JOIN rooms r ON r.id = i.room_id
+	JOIN users u ON u.id = i.user_id
+	LEFT JOIN users b ON b.id = i.invited_by
+	WHERE i.status = 'pending' AND %v
+	ORDER BY i."timestamp" DESC
+`
+
+// InviteUser creates a pending invitation. The actor needs the invite
+// permission, has to outrank the role and must not be blocked by the user.
+func (i *InvitationData) InviteUser(invitation RoomInvitation, actorID uuid.UUID) (*RoomInvitation, error) {
+	if invitation.Role == "" {
+		invitation.Role = RoleMember
+	}
+
+	err := i.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		role, err := writableRoomRole(tx, invitation.RoomID, actorID)
+		if err != nil {
+			return err
+		}
+
+		if !role.Can(PermissionInvite) || !role.CanAssign("", invitation.Role) {
+			return ErrForbidden
+		}
+
+		var user User
+		err = tx.Model(&user).Where("id = ?", invitation.UserID).Select()
+		if err != nil {
+			return err
+		}
+
+		member, err := roomRole(tx, invitation.RoomID, invitation.UserID)
+		if err != nil {
+			return err
+		}
+
+		if member != "" {
+			return ErrAlreadyMember
+		}
+
+		blocked, err := tx.Model((*UserBlock)(nil)).
+			Where("user_id = ? AND blocked_user_id = ?", invitation.UserID, actorID).
+			Exists()
+		if err != nil {
+			return err
+		}
+
+		if blocked {
+			return ErrBlocked
+		}
+
+		pending, err := tx.Model((*RoomInvitation)(nil)).
+			Where("room_id = ? AND user_id = ? AND status = ?", invitation.RoomID, invitation.UserID, InvitationPending).
+			Exists()
+		if err != nil {
+			return err
+		}
+
+		if pending {
+			return ErrAlreadyInvited
+		}
+
+		var room Room
+		err = tx.Model(&room).Where("id = ?", invitation.RoomID).Select()
+		if err != nil {
+			return fmt.Errorf("Failed to get room: %w", err)
+		}
+
+		var inviter User
+		err = tx.Model(&inviter).Where("id = ?", actorID).Select()
+		if err != nil {
+			return fmt.Errorf("Failed to get user: %w", err)
+		}
+
+		invitation.InvitedBy = actorID
+		invitation.Status = InvitationPending
+		_, err = tx.Model(&invitation).Insert()
+		if err != nil {
+			return err
+		}
+
+		return enqueueEvent(tx, actorID, room.ID, roomInvitationCreatedEvent(room, user, inviter, invitation))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &invitation, nil
+}
+
+func (i *InvitationData) GetInvitationsByRoomID(roomID uuid.UUID) []RoomInvitationInfo {
+	invitations := make([]RoomInvitationInfo, 0)
+	i.DB.Query(&invitations, fmt.Sprintf(invitationInfoQuery, "i.room_id = ?"), roomID)
+	return invitations
+}
+
+func (i *InvitationData) GetInvitationsByUserID(userID uuid.UUID) []RoomInvitationInfo {
+	invitations := make([]RoomInvitationInfo, 0)
+	i.DB.Query(&invitations, fmt.Sprintf(invitationInfoQuery, "i.user_id = ?"), userID)
+	return invitations
+}
+
+// AcceptInvitation makes userID a member with the invitation's role and
+// returns the room.
+func (i *InvitationData) AcceptInvitation(invitationID, userID uuid.UUID) (*Room, error) {
+	var room Room
+
+	err := i.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		invitation, err := pendingInvitation(tx, "id = ? AND user_id = ?", invitationID, userID)
+		if err != nil {
+			return err
+		}
+
+		err = requireWritableRoom(tx, invitation.RoomID)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&room).Where("id = ?", invitation.RoomID).Select()
+		if err != nil {
+			return fmt.Errorf("Failed to get room: %w", err)
+		}
+
+		var user User
+		err = tx.Model(&user).Where("id = ?", userID).Select()
+		if err != nil {
+			return fmt.Errorf("Failed to get user: %w", err)
+		}
+
+		err = setInvitationStatus(tx, invitation, InvitationAccepted)
+		if err != nil {
+			return err
+		}
+
+		// The user may have joined through an invite link in the meantime.
+		member, err := roomRole(tx, invitation.RoomID, userID)
+		if err != nil {
+			return err
+		}
+
+		if member == "" {
+			roomUser := RoomUser{
+				RoomID: invitation.RoomID,
+				UserID: userID,
+				Role:   invitation.Role,
+			}
+
+			_, err = tx.Model(&roomUser).Insert()
+			if err != nil {
+				return err
+			}
+
+			err = enqueueEvent(tx, userID, room.ID, roomMemberAddedEvent(room, user, roomUser))
+			if err != nil {
+				return err
+			}
+		}
+
+		return enqueueEvent(tx, userID, room.ID, roomInvitationUpdatedEvent(*invitation))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &room, nil
+}
+
+// DeclineInvitation declines an invitation of userID. With block the
+// inviter cannot invite the user again.
+func (i *InvitationData) DeclineInvitation(invitationID, userID uuid.UUID, block bool) error {
+	return i.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		invitation, err := pendingInvitation(tx, "id = ? AND user_id = ?", invitationID, userID)
+		if err != nil {
+			return err
+		}
+
+		err = setInvitationStatus(tx, invitation, InvitationDeclined)
+		if err != nil {
+			return err
+		}
+
+		if block && invitation.InvitedBy != uuid.Nil {
+			_, err = tx.Model(&UserBlock{UserID: userID, BlockedUserID: invitation.InvitedBy}).
+				OnConflict("DO NOTHING").
+				Insert()
+			if err != nil {
+				return err
+			}
+		}
+
+		return enqueueEvent(tx, userID, invitation.RoomID, roomInvitationUpdatedEvent(*invitation))
+	})
+}
+
+// CancelInvitation withdraws a pending invitation of roomID. The actor
+// needs the invite permission.
+func (i *InvitationData) CancelInvitation(roomID, invitationID, actorID uuid.UUID) error {
+	return i.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		role, err := writableRoomRole(tx, roomID, actorID)
+		if err != nil {
+			return err
+		}
+
+		if !role.Can(PermissionInvite) {
+			return ErrForbidden
+		}
+
+		invitation, err := pendingInvitation(tx, "id = ? AND room_id = ?", invitationID, roomID)
+		if err != nil {
+			return err
+		}
+
+		err = setInvitationStatus(tx, invitation, InvitationCancelled)
+		if err != nil {
+			return err
+		}
+
+		return enqueueEvent(tx, actorID, roomID, roomInvitationUpdatedEvent(*invitation))
+	})
+}
+
+func (i *InvitationData) GetBlockedUsers(userID uuid.UUID) []User {
+	users := make([]User, 0)
+	i.DB.Model(&users).
+		Column("id", "name", "timestamp").
+		Where(`"user".id IN (SELECT blocked_user_id FROM user_blocks WHERE user_id = ?)`, userID).
+		Order("name").
+		Select()
+	return users
+}
+
+func (i *InvitationData) UnblockUser(userID, blockedUserID uuid.UUID) error {
+	result, err := i.DB.Model((*UserBlock)(nil)).
+		Where("user_id = ? AND blocked_user_id = ?", userID, blockedUserID).
+		Delete()
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return pg.ErrNoRows
+	}
+	return nil
+}
+
+func pendingInvitation(tx *pg.Tx, condition string, params ...interface{}) (*RoomInvitation, error) {
+	var invitation RoomInvitation
+	err := tx.Model(&invitation).Where(condition, params...).For("UPDATE").Select()
+	if err != nil {
+		return nil, err
+	}
+
+	if invitation.Status != InvitationPending {
+		return nil, ErrInvitationClosed
+	}
+	return &invitation, nil
+}
+
+func setInvitationStatus(tx *pg.Tx, invitation *RoomInvitation, status InvitationStatus) error {
+	now := time.Now()
+	invitation.Status = status
+	invitation.RespondedAt = &now
+
+	_, err := tx.Model(invitation).Column("status", "responded_at").WherePK().Update()
+	return err
+}
@@ -0,0 +1,225 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/adamelfsborg-code/movie-nest/config"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInviteExpired = errors.New("Invite has expired")
+	ErrInviteRevoked = errors.New("Invite has been revoked")
+	ErrInviteUsedUp  = errors.New("Invite has been used up")
+	ErrAlreadyMember = errors.New("User is already a member of the room")
+)
+
+type InviteData struct {
+	DB  *pg.DB
+	Env config.Environments
+}
+
+// RoomInvite lets anyone with Code join RoomID with Role. ExpiresAt and
+// MaxUses are optional. Link is the shareable link, set when
+// INVITE_BASE_URL is configured.
+type RoomInvite struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	RoomID    uuid.UUID  `json:"room_id" db:"room_id"`
+	Code      string     `json:"code" db:"code"`
+	Role      Role       `json:"role" db:"role"`
+	CreatedBy uuid.UUID  `json:"created_by" db:"created_by"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	MaxUses   *int       `json:"max_uses,omitempty" db:"max_uses"`
+	Uses      int        `json:"uses" db:"uses"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	Timestamp time.Time  `json:"timestamp" db:"timestamp"`
+	Link      string     `json:"link,omitempty" pg:"-"`
+}
+
+func NewRoomInvite(roomID uuid.UUID, role Role, expiresAt *time.Time, maxUses *int) (*RoomInvite, error) {
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("expires_at must be in the future")
+	}
+
+	if maxUses != nil && *maxUses < 1 {
+		return nil, fmt.Errorf("max_uses must be at least 1")
+	}
+
+	code, err := newInviteCode()
+	if err != nil {
+		return nil, err
+	}
+
+	return &RoomInvite{
+		RoomID:    roomID,
+		Code:      code,
+		Role:      role,
+		ExpiresAt: expiresAt,
+		MaxUses:   maxUses,
+	}, nil
+}
+
+func newInviteCode() (string, error) {
+	b := make([]byte, 9)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("Failed to generate invite code: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// check returns why the invite cannot be redeemed at now, nil when it can.
+func (i *RoomInvite) check(now time.Time) error {
+	switch {
+	case i.RevokedAt != nil:
+		return ErrInviteRevoked
+	case i.ExpiresAt != nil && !i.ExpiresAt.After(now):
+		return ErrInviteExpired
+	case i.MaxUses != nil && i.Uses >= *i.MaxUses:
+		return ErrInviteUsedUp
+	}
+	return nil
+}
+
+func inviteLink(env config.Environments, invite *RoomInvite) {
+	if env.InviteBaseURL != "" {
+		invite.Link = strings.TrimSuffix(env.InviteBaseURL, "/") + "/" + invite.Code
+	}
+}
+
+// CreateInvite stores the invite. The actor needs the invite permission and
+// has to outrank the role the invite grants.
+func (i *InviteData) CreateInvite(invite RoomInvite, actorID uuid.UUID) (*RoomInvite, error) {
+	err := i.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		role, err := writableRoomRole(tx, invite.RoomID, actorID)
+		if err != nil {
+			return err
+		}
+
+		if !role.Can(PermissionInvite) || !role.CanAssign("", invite.Role) {
+			return ErrForbidden
+		}
+
+		invite.CreatedBy = actorID
+		_, err = tx.Model(&invite).Insert()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	inviteLink(i.Env, &invite)
+	return &invite, nil
+}
+
+func (i *InviteData) GetInvitesByRoomID(roomID uuid.UUID) []RoomInvite {
+	invites := make([]RoomInvite, 0)
+	i.DB.Model(&invites).Where("room_id = ?", roomID).Order("timestamp DESC").Select()
+
+	for j := range invites {
+		inviteLink(i.Env, &invites[j])
+	}
+	return invites
+}
+
+// RevokeInvite stops an invite of roomID from being redeemed. Revoking
+// twice keeps the first time.
+func (i *InviteData) RevokeInvite(roomID, inviteID, actorID uuid.UUID) error {
+	return i.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		role, err := writableRoomRole(tx, roomID, actorID)
+		if err != nil {
+			return err
+		}
+
+		if !role.Can(PermissionInvite) {
+			return ErrForbidden
+		}
+
+		result, err := tx.Model((*RoomInvite)(nil)).
+			Set("revoked_at = COALESCE(revoked_at, now())").
+			Where("id = ? AND room_id = ?", inviteID, roomID).
+			Update()
+		if err != nil {
+			return err
+		}
+
+		if result.RowsAffected() == 0 {
+			return pg.ErrNoRows
+		}
+		return nil
+	})
+}
+
+// RedeemInvite makes userID a member of the invite's room with the role the
+// invite grants and returns the room.
+func (i *InviteData) RedeemInvite(code string, userID uuid.UUID) (*Room, error) {
+	var room Room
+
+	err := i.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		var invite RoomInvite
+		err := tx.Model(&invite).Where("code = ?", code).For("UPDATE").Select()
+		if err != nil {
+			return err
+		}
+
+		err = invite.check(time.Now())
+		if err != nil {
+			return err
+		}
+
+		err = requireWritableRoom(tx, invite.RoomID)
+		if err != nil {
+			return err
+		}
+
+		role, err := roomRole(tx, invite.RoomID, userID)
+		if err != nil {
+			return err
+		}
+
+		if role != "" {
+			return ErrAlreadyMember
+		}
+
+		err = tx.Model(&room).Where("id = ?", invite.RoomID).Select()
+		if err != nil {
+			return fmt.Errorf("Failed to get room: %w", err)
+		}
+
+		var user User
+		err = tx.Model(&user).Where("id = ?", userID).Select()
+		if err != nil {
+			return fmt.Errorf("Failed to get user: %w", err)
+		}
+
+		roomUser := RoomUser{
+			RoomID: invite.RoomID,
+			UserID: userID,
+			Role:   invite.Role,
+		}
+
+		_, err = tx.Model(&roomUser).Insert()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Model(&invite).Set("uses = uses + 1").WherePK().Update()
+		if err != nil {
+			return err
+		}
+
+		return enqueueEvent(tx, userID, room.ID, roomMemberJoinedEvent(room, user, roomUser, invite))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &room, nil
+}
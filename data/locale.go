@@ -0,0 +1,76 @@
+package data
+
+import (
+	"fmt"
+
+	"github.com/adamelfsborg-code/movie-nest/config"
+	"github.com/adamelfsborg-code/movie-nest/pkg/themoviedb"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+// NewLocale validates a language preference, such as sv-SE, and an ISO
+// 3166-1 region, such as SE. Empty values clear the preference.
+func NewLocale(language, region string) (*themoviedb.Locale, error) {
+	validate := validator.New()
+
+	err := validate.Var(language, "omitempty,bcp47_language_tag")
+	if err != nil {
+		return nil, fmt.Errorf("Invalid language %q", language)
+	}
+
+	err = validate.Var(region, "omitempty,iso3166_1_alpha2")
+	if err != nil {
+		return nil, fmt.Errorf("Invalid region %q", region)
+	}
+
+	return &themoviedb.Locale{Language: language, Region: region}, nil
+}
+
+// resolveLocale picks the user's preference, then the room's default and
+// then the configured default, separately for language and region.
+func resolveLocale(env config.Environments, user User, room Room) themoviedb.Locale {
+	locale := themoviedb.Locale{
+		Language: firstNonEmpty(user.Language, room.Language, env.MovieDBLanguage, themoviedb.DefaultLanguage),
+		Region:   firstNonEmpty(user.Region, room.Region, env.MovieDBRegion),
+	}
+	return locale
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// userLocale resolves the locale of userID in roomID, roomID can be
+// uuid.Nil outside of rooms.
+func userLocale(db orm.DB, env config.Environments, userID, roomID uuid.UUID) themoviedb.Locale {
+	var user User
+	var room Room
+
+	err := db.Model(&user).Column("language", "region").Where("id = ?", userID).Select()
+	if err != nil {
+		fmt.Println("Failed to get user locale: ", err)
+	}
+
+	if roomID != uuid.Nil {
+		err = db.Model(&room).Column("language", "region").Where("id = ?", roomID).Select()
+		if err != nil {
+			fmt.Println("Failed to get room locale: ", err)
+		}
+	}
+
+	return resolveLocale(env, user, room)
+}
+
+// locale is userLocale for the memory stores, the caller holds the lock.
+func (d *MemoryDB) locale(env config.Environments, userID, roomID uuid.UUID) themoviedb.Locale {
+	user, _ := d.user(userID)
+	room, _ := d.room(roomID)
+	return resolveLocale(env, user, room)
+}
@@ -0,0 +1,297 @@
+package data
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/adamelfsborg-code/movie-nest/config"
+	"github.com/adamelfsborg-code/movie-nest/events"
+	"github.com/google/uuid"
+)
+
+// MemoryDB is an in-memory stand-in for the Postgres schema. It enforces
+// the same primary keys, foreign keys and unique constraints as the
+// migrations so the memory stores fail where Postgres would.
+type MemoryDB struct {
+	mu              sync.RWMutex
+	users           []User
+	rooms           []Room
+	roomUsers       []RoomUser
+	roomInvites     []RoomInvite
+	roomInvitations []RoomInvitation
+	userBlocks      []UserBlock
+	shelves         []Shelf
+	movies          []Movie
+	movieRatings    []MovieRating
+
+	movieMetadata []MovieMetadata
+}
+
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{}
+}
+
+var (
+	_ RoomStore       = (*MemoryRoomData)(nil)
+	_ InviteStore     = (*MemoryInviteData)(nil)
+	_ InvitationStore = (*MemoryInvitationData)(nil)
+	_ ShelfStore      = (*MemoryShelfData)(nil)
+	_ MovieStore      = (*MemoryMovieData)(nil)
+	_ UserStore       = (*MemoryUserData)(nil)
+
+	_ MetadataStore = (*MemoryMetadataData)(nil)
+)
+
+func NewMemoryStores(env config.Environments, db *MemoryDB, bus events.Bus) Stores {
+	provider := newProvider(env)
+	metadata := NewMetadataCache(env, provider, &MemoryMetadataData{DB: db})
+
+	return Stores{
+		Rooms:       &MemoryRoomData{Env: env, DB: db, Bus: bus, Metadata: metadata},
+		Invites:     &MemoryInviteData{Env: env, DB: db, Bus: bus},
+		Invitations: &MemoryInvitationData{DB: db, Bus: bus},
+		Shelves:     &MemoryShelfData{Env: env, DB: db, Bus: bus, Provider: provider, Metadata: metadata},
+		Movies:      &MemoryMovieData{Env: env, DB: db, Bus: bus, Provider: provider, Metadata: metadata},
+		Users:       &MemoryUserData{Env: env, DB: db},
+		Metadata:    metadata,
+	}
+}
+
+// publishEvent is used by the memory stores, which have no outbox and
+// publish straight after a successful write.
+func publishEvent(bus events.Bus, actorID, roomID uuid.UUID, payload events.Payload) {
+	event, err := events.NewEnvelopeEvent(actorID, roomID, payload)
+	if err != nil {
+		fmt.Println("Failed to create event: ", err)
+		return
+	}
+
+	err = bus.Publish(event)
+	if err != nil {
+		fmt.Println("Failed to publish event: ", err)
+	}
+}
+
+func violatesForeignKey(table, column string) error {
+	return fmt.Errorf("insert on table %q violates foreign key constraint on %q", table, column)
+}
+
+func violatesUnique(table string, columns string) error {
+	return fmt.Errorf("duplicate key value in %q violates unique constraint on (%v)", table, columns)
+}
+
+// newRow mirrors the column defaults of the schema.
+func newRow(id *uuid.UUID, timestamp *time.Time) {
+	if *id == uuid.Nil {
+		*id = uuid.New()
+	}
+
+	if timestamp != nil && timestamp.IsZero() {
+		*timestamp = time.Now()
+	}
+}
+
+func (d *MemoryDB) user(userID uuid.UUID) (User, bool) {
+	for _, user := range d.users {
+		if user.ID == userID {
+			return user, true
+		}
+	}
+	return User{}, false
+}
+
+func (d *MemoryDB) room(roomID uuid.UUID) (Room, bool) {
+	for _, room := range d.rooms {
+		if room.ID == roomID {
+			return room, true
+		}
+	}
+	return Room{}, false
+}
+
+func (d *MemoryDB) roomRow(roomID uuid.UUID) *Room {
+	for i := range d.rooms {
+		if d.rooms[i].ID == roomID {
+			return &d.rooms[i]
+		}
+	}
+	return nil
+}
+
+func (d *MemoryDB) shelf(shelfID uuid.UUID) (Shelf, bool) {
+	for _, shelf := range d.shelves {
+		if shelf.ID == shelfID {
+			return shelf, true
+		}
+	}
+	return Shelf{}, false
+}
+
+func (d *MemoryDB) shelfRow(shelfID uuid.UUID) *Shelf {
+	for i := range d.shelves {
+		if d.shelves[i].ID == shelfID {
+			return &d.shelves[i]
+		}
+	}
+	return nil
+}
+
+func (d *MemoryDB) movie(movieID uuid.UUID) (Movie, bool) {
+	for _, movie := range d.movies {
+		if movie.ID == movieID {
+			return movie, true
+		}
+	}
+	return Movie{}, false
+}
+
+func (d *MemoryDB) metadata(key MediaKey) (*MovieMetadata, bool) {
+	for i := range d.movieMetadata {
+		if d.movieMetadata[i].key() == key {
+			return &d.movieMetadata[i], true
+		}
+	}
+	return nil, false
+}
+
+func (d *MemoryDB) isMember(roomID, userID uuid.UUID) bool {
+	for _, roomUser := range d.roomUsers {
+		if roomUser.RoomID == roomID && roomUser.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *MemoryDB) role(roomID, userID uuid.UUID) Role {
+	for _, roomUser := range d.roomUsers {
+		if roomUser.RoomID == roomID && roomUser.UserID == userID {
+			return roomUser.Role
+		}
+	}
+	return ""
+}
+
+// writableRole is role for writes, which archived rooms refuse.
+func (d *MemoryDB) writableRole(roomID, userID uuid.UUID) (Role, error) {
+	role := d.role(roomID, userID)
+	if role != "" && d.archived(roomID) {
+		return "", ErrRoomArchived
+	}
+	return role, nil
+}
+
+func (d *MemoryDB) archived(roomID uuid.UUID) bool {
+	room, exists := d.room(roomID)
+	return exists && room.ArchivedAt != nil
+}
+
+// deleteRoomUser ends a membership and returns the room and user for the
+// event.
+func (d *MemoryDB) deleteRoomUser(roomID, userID uuid.UUID) (Room, User) {
+	for i, roomUser := range d.roomUsers {
+		if roomUser.RoomID == roomID && roomUser.UserID == userID {
+			d.roomUsers = append(d.roomUsers[:i], d.roomUsers[i+1:]...)
+			break
+		}
+	}
+
+	room, _ := d.room(roomID)
+	user, _ := d.user(userID)
+	return room, user
+}
+
+// deleteShelves removes the matching shelves with their movies and
+// ratings, like the foreign keys cascade in postgres.
+func (d *MemoryDB) deleteShelves(match func(Shelf) bool) {
+	shelfIDs := make(map[uuid.UUID]bool)
+	shelves := d.shelves[:0]
+	for _, shelf := range d.shelves {
+		if match(shelf) {
+			shelfIDs[shelf.ID] = true
+			continue
+		}
+		shelves = append(shelves, shelf)
+	}
+	d.shelves = shelves
+
+	d.deleteMovies(func(movie Movie) bool {
+		return shelfIDs[movie.ShelfID]
+	})
+}
+
+// deleteMovies removes the matching movies with their ratings.
+func (d *MemoryDB) deleteMovies(match func(Movie) bool) {
+	movieIDs := make(map[uuid.UUID]bool)
+	movies := d.movies[:0]
+	for _, movie := range d.movies {
+		if match(movie) {
+			movieIDs[movie.ID] = true
+			continue
+		}
+		movies = append(movies, movie)
+	}
+	d.movies = movies
+
+	ratings := d.movieRatings[:0]
+	for _, rating := range d.movieRatings {
+		if !movieIDs[rating.MovieID] {
+			ratings = append(ratings, rating)
+		}
+	}
+	d.movieRatings = ratings
+}
+
+func (d *MemoryDB) roomMembers(roomID uuid.UUID) []User {
+	var users []User
+	for _, roomUser := range d.roomUsers {
+		if roomUser.RoomID != roomID {
+			continue
+		}
+		if user, exists := d.user(roomUser.UserID); exists {
+			users = append(users, user)
+		}
+	}
+	return users
+}
+
+func (d *MemoryDB) insertRoomUser(roomUser *RoomUser) error {
+	if _, exists := d.room(roomUser.RoomID); !exists {
+		return violatesForeignKey("room_users", "room_id")
+	}
+
+	if _, exists := d.user(roomUser.UserID); !exists {
+		return violatesForeignKey("room_users", "user_id")
+	}
+
+	if d.isMember(roomUser.RoomID, roomUser.UserID) {
+		return violatesUnique("room_users", "room_id, user_id")
+	}
+
+	if roomUser.Role == "" {
+		roomUser.Role = RoleMember
+	}
+
+	if roomUser.Role == RoleOwner {
+		for _, existing := range d.roomUsers {
+			if existing.RoomID == roomUser.RoomID && existing.Role == RoleOwner {
+				return violatesUnique("room_users", "room_id) WHERE (role = 'owner'")
+			}
+		}
+	}
+
+	newRow(&roomUser.ID, &roomUser.Timestamp)
+	d.roomUsers = append(d.roomUsers, *roomUser)
+	return nil
+}
+
+// publicUser strips the password hash the same way the jsonb projections
+// in the Postgres queries do.
+func publicUser(user User) *User {
+	return &User{
+		ID:        user.ID,
+		Name:      user.Name,
+		Timestamp: user.Timestamp,
+	}
+}
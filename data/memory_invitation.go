@@ -0,0 +1,268 @@
+package data
+
+import (
+	"sort"
+	"time"
+
+	"github.com/adamelfsborg-code/movie-nest/events"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+)
+
+type MemoryInvitationData struct {
+	DB  *MemoryDB
+	Bus events.Bus
+}
+
+func (i *MemoryInvitationData) InviteUser(invitation RoomInvitation, actorID uuid.UUID) (*RoomInvitation, error) {
+	if invitation.Role == "" {
+		invitation.Role = RoleMember
+	}
+
+	i.DB.mu.Lock()
+	role, err := i.DB.writableRole(invitation.RoomID, actorID)
+	if err != nil {
+		i.DB.mu.Unlock()
+		return nil, err
+	}
+
+	if !role.Can(PermissionInvite) || !role.CanAssign("", invitation.Role) {
+		i.DB.mu.Unlock()
+		return nil, ErrForbidden
+	}
+
+	user, exists := i.DB.user(invitation.UserID)
+	if !exists {
+		i.DB.mu.Unlock()
+		return nil, pg.ErrNoRows
+	}
+
+	if i.DB.isMember(invitation.RoomID, invitation.UserID) {
+		i.DB.mu.Unlock()
+		return nil, ErrAlreadyMember
+	}
+
+	if i.DB.isBlocked(invitation.UserID, actorID) {
+		i.DB.mu.Unlock()
+		return nil, ErrBlocked
+	}
+
+	for _, existing := range i.DB.roomInvitations {
+		if existing.RoomID == invitation.RoomID && existing.UserID == invitation.UserID && existing.Status == InvitationPending {
+			i.DB.mu.Unlock()
+			return nil, ErrAlreadyInvited
+		}
+	}
+
+	room, _ := i.DB.room(invitation.RoomID)
+	inviter, _ := i.DB.user(actorID)
+
+	invitation.InvitedBy = actorID
+	invitation.Status = InvitationPending
+	newRow(&invitation.ID, &invitation.Timestamp)
+	i.DB.roomInvitations = append(i.DB.roomInvitations, invitation)
+	i.DB.mu.Unlock()
+
+	publishEvent(i.Bus, actorID, room.ID, roomInvitationCreatedEvent(room, user, inviter, invitation))
+	return &invitation, nil
+}
+
+func (i *MemoryInvitationData) GetInvitationsByRoomID(roomID uuid.UUID) []RoomInvitationInfo {
+	return i.invitationInfos(func(invitation RoomInvitation) bool {
+		return invitation.RoomID == roomID
+	})
+}
+
+func (i *MemoryInvitationData) GetInvitationsByUserID(userID uuid.UUID) []RoomInvitationInfo {
+	return i.invitationInfos(func(invitation RoomInvitation) bool {
+		return invitation.UserID == userID
+	})
+}
+
+func (i *MemoryInvitationData) invitationInfos(match func(RoomInvitation) bool) []RoomInvitationInfo {
+	i.DB.mu.RLock()
+	defer i.DB.mu.RUnlock()
+
+	invitations := make([]RoomInvitationInfo, 0)
+	for _, invitation := range i.DB.roomInvitations {
+		if invitation.Status != InvitationPending || !match(invitation) {
+			continue
+		}
+
+		room, _ := i.DB.room(invitation.RoomID)
+		user, _ := i.DB.user(invitation.UserID)
+		inviter, _ := i.DB.user(invitation.InvitedBy)
+
+		invitations = append(invitations, RoomInvitationInfo{
+			RoomInvitation: invitation,
+			RoomName:       room.Name,
+			UserName:       user.Name,
+			InvitedByName:  inviter.Name,
+		})
+	}
+
+	sort.SliceStable(invitations, func(a, b int) bool {
+		return invitations[a].Timestamp.After(invitations[b].Timestamp)
+	})
+	return invitations
+}
+
+func (i *MemoryInvitationData) AcceptInvitation(invitationID, userID uuid.UUID) (*Room, error) {
+	i.DB.mu.Lock()
+	invitation, err := i.DB.pendingInvitation(func(invitation RoomInvitation) bool {
+		return invitation.ID == invitationID && invitation.UserID == userID
+	})
+	if err != nil {
+		i.DB.mu.Unlock()
+		return nil, err
+	}
+
+	if i.DB.archived(invitation.RoomID) {
+		i.DB.mu.Unlock()
+		return nil, ErrRoomArchived
+	}
+
+	var roomUser *RoomUser
+	if !i.DB.isMember(invitation.RoomID, userID) {
+		roomUser = &RoomUser{
+			RoomID: invitation.RoomID,
+			UserID: userID,
+			Role:   invitation.Role,
+		}
+
+		err = i.DB.insertRoomUser(roomUser)
+		if err != nil {
+			i.DB.mu.Unlock()
+			return nil, err
+		}
+	}
+
+	setMemoryInvitationStatus(invitation, InvitationAccepted)
+	accepted := *invitation
+	room, _ := i.DB.room(invitation.RoomID)
+	user, _ := i.DB.user(userID)
+	i.DB.mu.Unlock()
+
+	if roomUser != nil {
+		publishEvent(i.Bus, userID, room.ID, roomMemberAddedEvent(room, user, *roomUser))
+	}
+	publishEvent(i.Bus, userID, room.ID, roomInvitationUpdatedEvent(accepted))
+	return &room, nil
+}
+
+func (i *MemoryInvitationData) DeclineInvitation(invitationID, userID uuid.UUID, block bool) error {
+	i.DB.mu.Lock()
+	invitation, err := i.DB.pendingInvitation(func(invitation RoomInvitation) bool {
+		return invitation.ID == invitationID && invitation.UserID == userID
+	})
+	if err != nil {
+		i.DB.mu.Unlock()
+		return err
+	}
+
+	setMemoryInvitationStatus(invitation, InvitationDeclined)
+	declined := *invitation
+
+	if block && invitation.InvitedBy != uuid.Nil && !i.DB.isBlocked(userID, invitation.InvitedBy) {
+		i.DB.userBlocks = append(i.DB.userBlocks, UserBlock{
+			UserID:        userID,
+			BlockedUserID: invitation.InvitedBy,
+			Timestamp:     time.Now(),
+		})
+	}
+	i.DB.mu.Unlock()
+
+	publishEvent(i.Bus, userID, declined.RoomID, roomInvitationUpdatedEvent(declined))
+	return nil
+}
+
+func (i *MemoryInvitationData) CancelInvitation(roomID, invitationID, actorID uuid.UUID) error {
+	i.DB.mu.Lock()
+	role, err := i.DB.writableRole(roomID, actorID)
+	if err != nil {
+		i.DB.mu.Unlock()
+		return err
+	}
+
+	if !role.Can(PermissionInvite) {
+		i.DB.mu.Unlock()
+		return ErrForbidden
+	}
+
+	invitation, err := i.DB.pendingInvitation(func(invitation RoomInvitation) bool {
+		return invitation.ID == invitationID && invitation.RoomID == roomID
+	})
+	if err != nil {
+		i.DB.mu.Unlock()
+		return err
+	}
+
+	setMemoryInvitationStatus(invitation, InvitationCancelled)
+	cancelled := *invitation
+	i.DB.mu.Unlock()
+
+	publishEvent(i.Bus, actorID, roomID, roomInvitationUpdatedEvent(cancelled))
+	return nil
+}
+
+func (i *MemoryInvitationData) GetBlockedUsers(userID uuid.UUID) []User {
+	i.DB.mu.RLock()
+	defer i.DB.mu.RUnlock()
+
+	users := make([]User, 0)
+	for _, userBlock := range i.DB.userBlocks {
+		if userBlock.UserID != userID {
+			continue
+		}
+		if user, exists := i.DB.user(userBlock.BlockedUserID); exists {
+			users = append(users, *publicUser(user))
+		}
+	}
+
+	sort.SliceStable(users, func(a, b int) bool {
+		return users[a].Name < users[b].Name
+	})
+	return users
+}
+
+func (i *MemoryInvitationData) UnblockUser(userID, blockedUserID uuid.UUID) error {
+	i.DB.mu.Lock()
+	defer i.DB.mu.Unlock()
+
+	for j, userBlock := range i.DB.userBlocks {
+		if userBlock.UserID == userID && userBlock.BlockedUserID == blockedUserID {
+			i.DB.userBlocks = append(i.DB.userBlocks[:j], i.DB.userBlocks[j+1:]...)
+			return nil
+		}
+	}
+	return pg.ErrNoRows
+}
+
+func (d *MemoryDB) isBlocked(userID, blockedUserID uuid.UUID) bool {
+	for _, userBlock := range d.userBlocks {
+		if userBlock.UserID == userID && userBlock.BlockedUserID == blockedUserID {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *MemoryDB) pendingInvitation(match func(RoomInvitation) bool) (*RoomInvitation, error) {
+	for j := range d.roomInvitations {
+		if !match(d.roomInvitations[j]) {
+			continue
+		}
+
+		if d.roomInvitations[j].Status != InvitationPending {
+			return nil, ErrInvitationClosed
+		}
+		return &d.roomInvitations[j], nil
+	}
+	return nil, pg.ErrNoRows
+}
+
+func setMemoryInvitationStatus(invitation *RoomInvitation, status InvitationStatus) {
+	now := time.Now()
+	invitation.Status = status
+	invitation.RespondedAt = &now
+}
@@ -0,0 +1,151 @@
+package data
+
+import (
+	"sort"
+	"time"
+
+	"github.com/adamelfsborg-code/movie-nest/config"
+	"github.com/adamelfsborg-code/movie-nest/events"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+)
+
+type MemoryInviteData struct {
+	DB  *MemoryDB
+	Env config.Environments
+	Bus events.Bus
+}
+
+func (i *MemoryInviteData) CreateInvite(invite RoomInvite, actorID uuid.UUID) (*RoomInvite, error) {
+	i.DB.mu.Lock()
+	defer i.DB.mu.Unlock()
+
+	if _, exists := i.DB.room(invite.RoomID); !exists {
+		return nil, violatesForeignKey("room_invites", "room_id")
+	}
+
+	role, err := i.DB.writableRole(invite.RoomID, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !role.Can(PermissionInvite) || !role.CanAssign("", invite.Role) {
+		return nil, ErrForbidden
+	}
+
+	for _, existing := range i.DB.roomInvites {
+		if existing.Code == invite.Code {
+			return nil, violatesUnique("room_invites", "code")
+		}
+	}
+
+	if invite.Role == "" {
+		invite.Role = RoleMember
+	}
+
+	invite.CreatedBy = actorID
+	newRow(&invite.ID, &invite.Timestamp)
+	i.DB.roomInvites = append(i.DB.roomInvites, invite)
+
+	inviteLink(i.Env, &invite)
+	return &invite, nil
+}
+
+func (i *MemoryInviteData) GetInvitesByRoomID(roomID uuid.UUID) []RoomInvite {
+	i.DB.mu.RLock()
+	defer i.DB.mu.RUnlock()
+
+	invites := make([]RoomInvite, 0)
+	for _, invite := range i.DB.roomInvites {
+		if invite.RoomID == roomID {
+			inviteLink(i.Env, &invite)
+			invites = append(invites, invite)
+		}
+	}
+
+	sort.SliceStable(invites, func(a, b int) bool {
+		return invites[a].Timestamp.After(invites[b].Timestamp)
+	})
+	return invites
+}
+
+func (i *MemoryInviteData) RevokeInvite(roomID, inviteID, actorID uuid.UUID) error {
+	i.DB.mu.Lock()
+	defer i.DB.mu.Unlock()
+
+	role, err := i.DB.writableRole(roomID, actorID)
+	if err != nil {
+		return err
+	}
+
+	if !role.Can(PermissionInvite) {
+		return ErrForbidden
+	}
+
+	for j := range i.DB.roomInvites {
+		invite := &i.DB.roomInvites[j]
+		if invite.ID != inviteID || invite.RoomID != roomID {
+			continue
+		}
+
+		if invite.RevokedAt == nil {
+			now := time.Now()
+			invite.RevokedAt = &now
+		}
+		return nil
+	}
+	return pg.ErrNoRows
+}
+
+func (i *MemoryInviteData) RedeemInvite(code string, userID uuid.UUID) (*Room, error) {
+	i.DB.mu.Lock()
+
+	var invite *RoomInvite
+	for j := range i.DB.roomInvites {
+		if i.DB.roomInvites[j].Code == code {
+			invite = &i.DB.roomInvites[j]
+		}
+	}
+
+	if invite == nil {
+		i.DB.mu.Unlock()
+		return nil, pg.ErrNoRows
+	}
+
+	err := invite.check(time.Now())
+	if err != nil {
+		i.DB.mu.Unlock()
+		return nil, err
+	}
+
+	if i.DB.archived(invite.RoomID) {
+		i.DB.mu.Unlock()
+		return nil, ErrRoomArchived
+	}
+
+	if i.DB.isMember(invite.RoomID, userID) {
+		i.DB.mu.Unlock()
+		return nil, ErrAlreadyMember
+	}
+
+	roomUser := RoomUser{
+		RoomID: invite.RoomID,
+		UserID: userID,
+		Role:   invite.Role,
+	}
+
+	err = i.DB.insertRoomUser(&roomUser)
+	if err != nil {
+		i.DB.mu.Unlock()
+		return nil, err
+	}
+
+	invite.Uses++
+	redeemed := *invite
+	room, _ := i.DB.room(invite.RoomID)
+	user, _ := i.DB.user(userID)
+	i.DB.mu.Unlock()
+
+	publishEvent(i.Bus, userID, room.ID, roomMemberJoinedEvent(room, user, roomUser, redeemed))
+	return &room, nil
+}
@@ -0,0 +1,88 @@
+package data
+
+import (
+	"time"
+)
+
+type MemoryMetadataData struct {
+	DB *MemoryDB
+}
+
+func (m *MemoryMetadataData) GetMetadata(keys []MediaKey) ([]MovieMetadata, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	var metadata []MovieMetadata
+	for _, key := range keys {
+		if cached, exists := m.DB.metadata(key); exists {
+			metadata = append(metadata, *cached)
+		}
+	}
+	return metadata, nil
+}
+
+func (m *MemoryMetadataData) SaveMetadata(metadata MovieMetadata) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	metadata.LastError = ""
+	metadata.LastErrorAt = nil
+
+	if cached, exists := m.DB.metadata(metadata.key()); exists {
+		*cached = metadata
+		return nil
+	}
+
+	m.DB.movieMetadata = append(m.DB.movieMetadata, metadata)
+	return nil
+}
+
+func (m *MemoryMetadataData) SaveMetadataError(key MediaKey, fetchErr error) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if cached, exists := m.DB.metadata(key); exists {
+		now := time.Now()
+		cached.LastError = fetchErr.Error()
+		cached.LastErrorAt = &now
+	}
+	return nil
+}
+
+func (m *MemoryMetadataData) StaleMetadata(fetchedBefore, failedBefore time.Time, defaultLanguage string, limit int) ([]MediaKey, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	var keys []MediaKey
+	seen := make(map[MediaKey]struct{})
+	add := func(key MediaKey) {
+		if _, exists := seen[key]; exists || len(keys) >= limit {
+			return
+		}
+		seen[key] = struct{}{}
+		keys = append(keys, key)
+	}
+
+	for _, movie := range m.DB.movies {
+		cached := false
+		for _, metadata := range m.DB.movieMetadata {
+			if metadata.MediaType != movie.MediaType || metadata.MovieID != movie.MovieID {
+				continue
+			}
+			cached = true
+
+			if !metadata.FetchedAt.Before(fetchedBefore) {
+				continue
+			}
+			if metadata.LastErrorAt != nil && !metadata.LastErrorAt.Before(failedBefore) {
+				continue
+			}
+			add(metadata.key())
+		}
+
+		if !cached {
+			add(movie.mediaKey(defaultLanguage))
+		}
+	}
+	return keys, nil
+}
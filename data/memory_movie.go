@@ -0,0 +1,330 @@
+package data
+
+import (
+	"context"
+
+	"github.com/adamelfsborg-code/movie-nest/config"
+	"github.com/adamelfsborg-code/movie-nest/events"
+	"github.com/adamelfsborg-code/movie-nest/pkg/metadata"
+	"github.com/adamelfsborg-code/movie-nest/pkg/themoviedb"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+)
+
+type MemoryMovieData struct {
+	Env      config.Environments
+	DB       *MemoryDB
+	Bus      events.Bus
+	Provider metadata.Provider
+	Metadata *MetadataCache
+}
+
+func (m *MemoryMovieData) CreateMovie(movie Movie, actorID uuid.UUID) error {
+	m.DB.mu.Lock()
+	shelf, exists := m.DB.shelf(movie.ShelfID)
+	if !exists {
+		m.DB.mu.Unlock()
+		return violatesForeignKey("movies", "shelf_id")
+	}
+
+	role, err := m.DB.writableRole(shelf.RoomID, actorID)
+	if err != nil {
+		m.DB.mu.Unlock()
+		return err
+	}
+
+	if !role.Can(PermissionAddMovie) {
+		m.DB.mu.Unlock()
+		return ErrForbidden
+	}
+
+	for _, existing := range m.DB.movies {
+		if existing.ShelfID == movie.ShelfID && existing.mediaKey("") == movie.mediaKey("") && sameSeason(existing.SeasonNumber, movie.SeasonNumber) {
+			m.DB.mu.Unlock()
+			return violatesUnique("movies", "shelf_id, media_type, movie_id, season_number")
+		}
+	}
+
+	newRow(&movie.ID, nil)
+	m.DB.movies = append(m.DB.movies, movie)
+	m.DB.mu.Unlock()
+
+	publishEvent(m.Bus, actorID, shelf.RoomID, shelfMovieAddedEvent(shelf.RoomID, movie))
+	return nil
+}
+
+func (m *MemoryMovieData) GetMovie(ctx context.Context, movieID uint, userID uuid.UUID) (*themoviedb.Movie, error) {
+	m.DB.mu.RLock()
+	locale := m.DB.locale(m.Env, userID, uuid.Nil)
+	m.DB.mu.RUnlock()
+
+	return m.Metadata.Movie(ctx, MediaKey{MediaType: themoviedb.MediaMovie, ID: movieID, Language: locale.Language})
+}
+
+func (m *MemoryMovieData) GetTV(ctx context.Context, tvID uint, userID uuid.UUID) (*themoviedb.Movie, error) {
+	m.DB.mu.RLock()
+	locale := m.DB.locale(m.Env, userID, uuid.Nil)
+	m.DB.mu.RUnlock()
+
+	return m.Metadata.Movie(ctx, MediaKey{MediaType: themoviedb.MediaTV, ID: tvID, Language: locale.Language})
+}
+
+func (m *MemoryMovieData) FindByIMDbID(ctx context.Context, imdbID string, userID uuid.UUID) (*themoviedb.Movie, error) {
+	m.DB.mu.RLock()
+	locale := m.DB.locale(m.Env, userID, uuid.Nil)
+	m.DB.mu.RUnlock()
+
+	return m.Provider.FindByExternalID(ctx, metadata.ExternalID{Source: metadata.SourceIMDb, ID: imdbID}, locale.Language)
+}
+
+func (m *MemoryMovieData) GetMovieDetails(ctx context.Context, movieID, userID uuid.UUID) (*MovieDetails, error) {
+	m.DB.mu.RLock()
+	movie, exists := m.DB.movie(movieID)
+	shelf, _ := m.DB.shelf(movie.ShelfID)
+	room, _ := m.DB.room(shelf.RoomID)
+	locale := m.DB.locale(m.Env, userID, room.ID)
+	m.DB.mu.RUnlock()
+
+	if !exists {
+		return nil, pg.ErrNoRows
+	}
+
+	details, err := m.Metadata.Movie(ctx, movie.mediaKey(locale.Language))
+	if err != nil {
+		return nil, err
+	}
+
+	if movie.SeasonNumber != nil {
+		movie.Season, _ = details.Season(*movie.SeasonNumber)
+	}
+
+	movie.Details = details
+	attachAvailability([]*Movie{&movie}, m.Env, room)
+	movie.Details = nil
+
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	var movieRatingResp []MovieRatingResp
+	var avgRating MovieAvgRating
+	var total float64
+
+	for _, rating := range m.DB.movieRatings {
+		if rating.MovieID != movieID {
+			continue
+		}
+
+		user, _ := m.DB.user(rating.UserID)
+		movieRatingResp = append(movieRatingResp, MovieRatingResp{
+			User: UserResp{
+				ID:        user.ID,
+				Name:      user.Name,
+				Timestamp: user.Timestamp,
+			},
+			Rating:    rating.Rating,
+			Timestamp: rating.Timestamp,
+		})
+		total += rating.Rating
+	}
+
+	if len(movieRatingResp) > 0 {
+		avgRating = MovieAvgRating{
+			MovieID: movieID,
+			Rating:  total / float64(len(movieRatingResp)),
+		}
+	}
+
+	movieDetails := &MovieDetails{
+		Movie:          movie,
+		MovieDetails:   *details,
+		MovieAvgRating: avgRating,
+		MovieRatings:   movieRatingResp,
+	}
+
+	return movieDetails, nil
+}
+
+func (m *MemoryMovieData) RateMovie(rating MovieRating) error {
+	m.DB.mu.Lock()
+	movie, exists := m.DB.movie(rating.MovieID)
+	if !exists {
+		m.DB.mu.Unlock()
+		return violatesForeignKey("movie_ratings", "movie_id")
+	}
+
+	if _, exists := m.DB.user(rating.UserID); !exists {
+		m.DB.mu.Unlock()
+		return violatesForeignKey("movie_ratings", "user_id")
+	}
+
+	shelf, _ := m.DB.shelf(movie.ShelfID)
+	role, err := m.DB.writableRole(shelf.RoomID, rating.UserID)
+	if err != nil {
+		m.DB.mu.Unlock()
+		return err
+	}
+
+	if !role.Can(PermissionRate) {
+		m.DB.mu.Unlock()
+		return ErrForbidden
+	}
+
+	newRow(&rating.ID, &rating.Timestamp)
+	m.DB.movieRatings = append(m.DB.movieRatings, rating)
+	m.DB.mu.Unlock()
+
+	publishEvent(m.Bus, rating.UserID, shelf.RoomID, shelfMovieRatedEvent(shelf.RoomID, shelf.ID, rating))
+	return nil
+}
+
+func (m *MemoryMovieData) GetMovieRole(movieID, userID uuid.UUID) (Role, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	movie, exists := m.DB.movie(movieID)
+	if !exists {
+		return "", nil
+	}
+
+	shelf, exists := m.DB.shelf(movie.ShelfID)
+	if !exists {
+		return "", nil
+	}
+
+	return m.DB.role(shelf.RoomID, userID), nil
+}
+
+func (m *MemoryMovieData) RemoveMovie(movieID, actorID uuid.UUID) error {
+	m.DB.mu.Lock()
+	movie, exists := m.DB.movie(movieID)
+	if !exists {
+		m.DB.mu.Unlock()
+		return pg.ErrNoRows
+	}
+
+	shelf, _ := m.DB.shelf(movie.ShelfID)
+	role, err := m.DB.writableRole(shelf.RoomID, actorID)
+	if err != nil {
+		m.DB.mu.Unlock()
+		return err
+	}
+
+	if !role.Can(PermissionDelete) {
+		m.DB.mu.Unlock()
+		return ErrForbidden
+	}
+
+	m.DB.deleteMovies(func(existing Movie) bool {
+		return existing.ID == movieID
+	})
+	m.DB.mu.Unlock()
+
+	publishEvent(m.Bus, actorID, shelf.RoomID, shelfMovieRemovedEvent(shelf.RoomID, movie))
+	return nil
+}
+
+func (m *MemoryMovieData) MoveMovie(movieID, shelfID, actorID uuid.UUID) (*Movie, error) {
+	m.DB.mu.Lock()
+	movie, roomID, err := m.DB.movieForShelf(movieID, shelfID, actorID)
+	if err != nil {
+		m.DB.mu.Unlock()
+		return nil, err
+	}
+
+	if movie.ShelfID == shelfID {
+		unchanged := *movie
+		m.DB.mu.Unlock()
+		return &unchanged, nil
+	}
+
+	if m.DB.onShelf(*movie, shelfID) {
+		m.DB.mu.Unlock()
+		return nil, ErrMovieOnShelf
+	}
+
+	fromShelfID := movie.ShelfID
+	movie.ShelfID = shelfID
+	moved := *movie
+	m.DB.mu.Unlock()
+
+	publishEvent(m.Bus, actorID, roomID, shelfMovieMovedEvent(roomID, fromShelfID, moved))
+	return &moved, nil
+}
+
+func (m *MemoryMovieData) CopyMovie(movieID, shelfID, actorID uuid.UUID) (*Movie, error) {
+	m.DB.mu.Lock()
+	original, roomID, err := m.DB.movieForShelf(movieID, shelfID, actorID)
+	if err != nil {
+		m.DB.mu.Unlock()
+		return nil, err
+	}
+
+	if m.DB.onShelf(*original, shelfID) {
+		m.DB.mu.Unlock()
+		return nil, ErrMovieOnShelf
+	}
+
+	movie := Movie{
+		MediaType:    original.MediaType,
+		MovieID:      original.MovieID,
+		SeasonNumber: original.SeasonNumber,
+		ShelfID:      shelfID,
+	}
+	newRow(&movie.ID, nil)
+	m.DB.movies = append(m.DB.movies, movie)
+	m.DB.mu.Unlock()
+
+	publishEvent(m.Bus, actorID, roomID, shelfMovieAddedEvent(roomID, movie))
+	return &movie, nil
+}
+
+// movieForShelf is the memory version of movieForShelf, the movie it
+// returns points into the movies.
+func (d *MemoryDB) movieForShelf(movieID, shelfID, actorID uuid.UUID) (*Movie, uuid.UUID, error) {
+	var movie *Movie
+	for i := range d.movies {
+		if d.movies[i].ID == movieID {
+			movie = &d.movies[i]
+		}
+	}
+
+	if movie == nil {
+		return nil, uuid.Nil, pg.ErrNoRows
+	}
+
+	shelf, _ := d.shelf(movie.ShelfID)
+	role, err := d.writableRole(shelf.RoomID, actorID)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	if !role.Can(PermissionAddMovie) {
+		return nil, uuid.Nil, ErrForbidden
+	}
+
+	target, exists := d.shelf(shelfID)
+	if !exists {
+		return nil, uuid.Nil, pg.ErrNoRows
+	}
+
+	if target.RoomID != shelf.RoomID {
+		return nil, uuid.Nil, ErrOtherRoom
+	}
+	return movie, shelf.RoomID, nil
+}
+
+func (d *MemoryDB) onShelf(movie Movie, shelfID uuid.UUID) bool {
+	for _, existing := range d.movies {
+		if existing.ShelfID == shelfID && existing.mediaKey("") == movie.mediaKey("") && sameSeason(existing.SeasonNumber, movie.SeasonNumber) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameSeason(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
@@ -0,0 +1,442 @@
+package data
+
+import (
+	"strings"
+	"time"
+
+	"github.com/adamelfsborg-code/movie-nest/config"
+	"github.com/adamelfsborg-code/movie-nest/events"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+)
+
+type MemoryRoomData struct {
+	DB       *MemoryDB
+	Env      config.Environments
+	Bus      events.Bus
+	Metadata *MetadataCache
+}
+
+func (r *MemoryRoomData) CreateRoom(room Room, userID uuid.UUID) error {
+	r.DB.mu.Lock()
+	user, exists := r.DB.user(userID)
+	if !exists {
+		r.DB.mu.Unlock()
+		return violatesForeignKey("room_users", "user_id")
+	}
+
+	newRow(&room.ID, &room.Timestamp)
+	r.DB.rooms = append(r.DB.rooms, room)
+
+	roomUser := RoomUser{
+		RoomID: room.ID,
+		UserID: userID,
+		Role:   RoleOwner,
+	}
+	err := r.DB.insertRoomUser(&roomUser)
+	r.DB.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	publishEvent(r.Bus, userID, room.ID, roomCreatedEvent(room))
+	publishEvent(r.Bus, userID, room.ID, roomMemberAddedEvent(room, user, roomUser))
+	return nil
+}
+
+func (r *MemoryRoomData) ListRooms() []Room {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	rooms := make([]Room, len(r.DB.rooms))
+	copy(rooms, r.DB.rooms)
+	return rooms
+}
+
+func (r *MemoryRoomData) GetRoomByID(roomID uuid.UUID) (*Room, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	room, exists := r.DB.room(roomID)
+	if !exists {
+		return nil, pg.ErrNoRows
+	}
+	return &room, nil
+}
+
+func (r *MemoryRoomData) GetRoomInfoByID(roomID, userID uuid.UUID) (*RoomInfo, error) {
+	roomInfo := r.roomInfo(roomID)
+
+	r.DB.mu.RLock()
+	locale := r.DB.locale(r.Env, userID, roomID)
+	r.DB.mu.RUnlock()
+
+	r.Metadata.attachMetadata(roomInfo.movies(), locale.Language)
+	if roomInfo.Room != nil {
+		attachAvailability(roomInfo.movies(), r.Env, *roomInfo.Room)
+	}
+	return roomInfo, nil
+}
+
+func (r *MemoryRoomData) roomInfo(roomID uuid.UUID) *RoomInfo {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	var roomInfo RoomInfo
+
+	room, exists := r.DB.room(roomID)
+	if !exists {
+		return &roomInfo
+	}
+
+	roomInfo.Room = &room
+
+	for _, user := range r.DB.roomMembers(roomID) {
+		roomInfo.Users = append(roomInfo.Users, publicUser(user))
+	}
+
+	for _, shelf := range r.DB.roomShelves(roomID) {
+		shelfMovies := &ShelfMovies{
+			ID:       shelf.ID,
+			Name:     shelf.Name,
+			Position: shelf.Position,
+			Movies:   make([]*Movie, 0),
+		}
+
+		for _, movie := range r.DB.movies {
+			if movie.ShelfID == shelf.ID {
+				shelfMovies.Movies = append(shelfMovies.Movies, &Movie{ID: movie.ID, MediaType: movie.MediaType, MovieID: movie.MovieID, SeasonNumber: movie.SeasonNumber})
+			}
+		}
+
+		roomInfo.Shelves = append(roomInfo.Shelves, shelfMovies)
+	}
+
+	return &roomInfo
+}
+
+func (r *MemoryRoomData) SetRoomUserRole(roomID, userID uuid.UUID, role Role, actorID uuid.UUID) error {
+	r.DB.mu.Lock()
+	actorRole, err := r.DB.writableRole(roomID, actorID)
+	if err != nil {
+		r.DB.mu.Unlock()
+		return err
+	}
+
+	if !actorRole.Can(PermissionManageRoom) {
+		r.DB.mu.Unlock()
+		return ErrForbidden
+	}
+
+	index := -1
+	for i, roomUser := range r.DB.roomUsers {
+		if roomUser.RoomID == roomID && roomUser.UserID == userID {
+			index = i
+		}
+	}
+
+	if index == -1 {
+		r.DB.mu.Unlock()
+		return pg.ErrNoRows
+	}
+
+	previous := r.DB.roomUsers[index].Role
+	if !actorRole.CanAssign(previous, role) {
+		r.DB.mu.Unlock()
+		return ErrForbidden
+	}
+
+	r.DB.roomUsers[index].Role = role
+	user, _ := r.DB.user(userID)
+	room, _ := r.DB.room(roomID)
+	r.DB.mu.Unlock()
+
+	publishEvent(r.Bus, actorID, roomID, roomMemberRoleChangedEvent(room, user, previous, role))
+	return nil
+}
+
+func (r *MemoryRoomData) UpdateRoom(roomID uuid.UUID, update RoomUpdate, actorID uuid.UUID) (*Room, error) {
+	r.DB.mu.Lock()
+	role, err := r.DB.writableRole(roomID, actorID)
+	if err != nil {
+		r.DB.mu.Unlock()
+		return nil, err
+	}
+
+	if !role.Can(PermissionManageRoom) {
+		r.DB.mu.Unlock()
+		return nil, ErrForbidden
+	}
+
+	room := r.DB.roomRow(roomID)
+	if room == nil {
+		r.DB.mu.Unlock()
+		return nil, pg.ErrNoRows
+	}
+
+	update.apply(room)
+	updated := *room
+	r.DB.mu.Unlock()
+
+	publishEvent(r.Bus, actorID, roomID, roomUpdatedEvent(updated))
+	return &updated, nil
+}
+
+func (r *MemoryRoomData) SetRoomArchived(roomID uuid.UUID, archived bool, actorID uuid.UUID) (*Room, error) {
+	r.DB.mu.Lock()
+	if !r.DB.role(roomID, actorID).Can(PermissionManageRoom) {
+		r.DB.mu.Unlock()
+		return nil, ErrForbidden
+	}
+
+	room := r.DB.roomRow(roomID)
+	if room == nil {
+		r.DB.mu.Unlock()
+		return nil, pg.ErrNoRows
+	}
+
+	if archived == (room.ArchivedAt != nil) {
+		unchanged := *room
+		r.DB.mu.Unlock()
+		return &unchanged, nil
+	}
+
+	room.ArchivedAt = nil
+	if archived {
+		now := time.Now()
+		room.ArchivedAt = &now
+	}
+	updated := *room
+	r.DB.mu.Unlock()
+
+	if archived {
+		publishEvent(r.Bus, actorID, roomID, roomArchivedEvent(updated))
+	} else {
+		publishEvent(r.Bus, actorID, roomID, roomUnarchivedEvent(updated))
+	}
+	return &updated, nil
+}
+
+func (r *MemoryRoomData) LeaveRoom(roomID, userID uuid.UUID) error {
+	r.DB.mu.Lock()
+	role := r.DB.role(roomID, userID)
+	if role == "" {
+		r.DB.mu.Unlock()
+		return pg.ErrNoRows
+	}
+
+	if role == RoleOwner {
+		r.DB.mu.Unlock()
+		return ErrOwnerCannotLeave
+	}
+
+	room, user := r.DB.deleteRoomUser(roomID, userID)
+	r.DB.mu.Unlock()
+
+	publishEvent(r.Bus, userID, roomID, roomMemberLeftEvent(room, user))
+	return nil
+}
+
+func (r *MemoryRoomData) RemoveRoomUser(roomID, userID, actorID uuid.UUID) error {
+	r.DB.mu.Lock()
+	role, err := r.DB.writableRole(roomID, actorID)
+	if err != nil {
+		r.DB.mu.Unlock()
+		return err
+	}
+
+	if !role.Can(PermissionManageRoom) {
+		r.DB.mu.Unlock()
+		return ErrForbidden
+	}
+
+	target := r.DB.role(roomID, userID)
+	if target == "" {
+		r.DB.mu.Unlock()
+		return pg.ErrNoRows
+	}
+
+	if !role.Outranks(target) {
+		r.DB.mu.Unlock()
+		return ErrForbidden
+	}
+
+	room, user := r.DB.deleteRoomUser(roomID, userID)
+	r.DB.mu.Unlock()
+
+	publishEvent(r.Bus, actorID, roomID, roomMemberRemovedEvent(room, user))
+	return nil
+}
+
+func (r *MemoryRoomData) TransferRoomOwnership(roomID, userID, actorID uuid.UUID) error {
+	r.DB.mu.Lock()
+	role, err := r.DB.writableRole(roomID, actorID)
+	if err != nil {
+		r.DB.mu.Unlock()
+		return err
+	}
+
+	if role != RoleOwner || userID == actorID {
+		r.DB.mu.Unlock()
+		return ErrForbidden
+	}
+
+	if !r.DB.isMember(roomID, userID) {
+		r.DB.mu.Unlock()
+		return pg.ErrNoRows
+	}
+
+	for i := range r.DB.roomUsers {
+		roomUser := &r.DB.roomUsers[i]
+		if roomUser.RoomID != roomID {
+			continue
+		}
+
+		switch roomUser.UserID {
+		case actorID:
+			roomUser.Role = RoleAdmin
+		case userID:
+			roomUser.Role = RoleOwner
+		}
+	}
+
+	room, _ := r.DB.room(roomID)
+	user, _ := r.DB.user(userID)
+	r.DB.mu.Unlock()
+
+	publishEvent(r.Bus, actorID, roomID, roomOwnershipTransferredEvent(room, actorID, user))
+	return nil
+}
+
+// DeleteRoom also removes the rows the room's foreign keys cascade to.
+func (r *MemoryRoomData) DeleteRoom(roomID, actorID uuid.UUID) error {
+	r.DB.mu.Lock()
+	if r.DB.role(roomID, actorID) != RoleOwner {
+		r.DB.mu.Unlock()
+		return ErrForbidden
+	}
+
+	room, exists := r.DB.room(roomID)
+	if !exists {
+		r.DB.mu.Unlock()
+		return pg.ErrNoRows
+	}
+
+	r.DB.deleteShelves(func(shelf Shelf) bool {
+		return shelf.RoomID == roomID
+	})
+
+	roomUsers := r.DB.roomUsers[:0]
+	for _, roomUser := range r.DB.roomUsers {
+		if roomUser.RoomID != roomID {
+			roomUsers = append(roomUsers, roomUser)
+		}
+	}
+	r.DB.roomUsers = roomUsers
+
+	invites := r.DB.roomInvites[:0]
+	for _, invite := range r.DB.roomInvites {
+		if invite.RoomID != roomID {
+			invites = append(invites, invite)
+		}
+	}
+	r.DB.roomInvites = invites
+
+	invitations := r.DB.roomInvitations[:0]
+	for _, invitation := range r.DB.roomInvitations {
+		if invitation.RoomID != roomID {
+			invitations = append(invitations, invitation)
+		}
+	}
+	r.DB.roomInvitations = invitations
+
+	rooms := r.DB.rooms[:0]
+	for _, existing := range r.DB.rooms {
+		if existing.ID != roomID {
+			rooms = append(rooms, existing)
+		}
+	}
+	r.DB.rooms = rooms
+	r.DB.mu.Unlock()
+
+	publishEvent(r.Bus, actorID, roomID, roomDeletedEvent(room))
+	return nil
+}
+
+func (r *MemoryRoomData) ListRoomsWithUsers() []RoomWithUser {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	roomsWithUsers := make([]RoomWithUser, 0, len(r.DB.rooms))
+	for _, room := range r.DB.rooms {
+		roomsWithUsers = append(roomsWithUsers, r.roomWithUsers(room))
+	}
+	return roomsWithUsers
+}
+
+func (r *MemoryRoomData) GetRoomWithUsersByID(roomID uuid.UUID) RoomWithUser {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	room, exists := r.DB.room(roomID)
+	if !exists {
+		return RoomWithUser{}
+	}
+	return r.roomWithUsers(room)
+}
+
+func (r *MemoryRoomData) GetUserRoomsByID(userID uuid.UUID) []Room {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	var rooms []Room
+	for _, room := range r.DB.rooms {
+		if r.DB.isMember(room.ID, userID) {
+			rooms = append(rooms, room)
+		}
+	}
+	return rooms
+}
+
+func (r *MemoryRoomData) GetAvailableUsers(roomID uuid.UUID, userID uuid.UUID, searchTerm string, excludeSelf bool, excludeExisting bool) []User {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	var users []User
+	for _, user := range r.DB.users {
+		if !strings.Contains(strings.ToLower(user.Name), searchTerm) {
+			continue
+		}
+
+		if excludeSelf && user.ID == userID {
+			continue
+		}
+
+		if excludeExisting && r.DB.isMember(roomID, user.ID) {
+			continue
+		}
+
+		users = append(users, user)
+	}
+	return users
+}
+
+func (r *MemoryRoomData) GetRoomRole(roomID, userID uuid.UUID) (Role, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	return r.DB.role(roomID, userID), nil
+}
+
+func (r *MemoryRoomData) roomWithUsers(room Room) RoomWithUser {
+	roomWithUsers := RoomWithUser{
+		Room:  &room,
+		Users: make([]*User, 0),
+	}
+
+	for _, user := range r.DB.roomMembers(room.ID) {
+		roomWithUsers.Users = append(roomWithUsers.Users, publicUser(user))
+	}
+	return roomWithUsers
+}
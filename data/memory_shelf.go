@@ -0,0 +1,232 @@
+package data
+
+import (
+	"context"
+	"sort"
+
+	"github.com/adamelfsborg-code/movie-nest/config"
+	"github.com/adamelfsborg-code/movie-nest/events"
+	"github.com/adamelfsborg-code/movie-nest/pkg/metadata"
+	"github.com/adamelfsborg-code/movie-nest/pkg/themoviedb"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+)
+
+type MemoryShelfData struct {
+	DB       *MemoryDB
+	Env      config.Environments
+	Bus      events.Bus
+	Provider metadata.Provider
+	Metadata *MetadataCache
+}
+
+func (s *MemoryShelfData) CreateShelf(shelf Shelf, actorID uuid.UUID) error {
+	s.DB.mu.Lock()
+	if _, exists := s.DB.room(shelf.RoomID); !exists {
+		s.DB.mu.Unlock()
+		return violatesForeignKey("shelves", "room_id")
+	}
+
+	role, err := s.DB.writableRole(shelf.RoomID, actorID)
+	if err != nil {
+		s.DB.mu.Unlock()
+		return err
+	}
+
+	if !role.Can(PermissionCreateShelf) {
+		s.DB.mu.Unlock()
+		return ErrForbidden
+	}
+
+	shelf.Position = 0
+	for _, existing := range s.DB.shelves {
+		if existing.RoomID == shelf.RoomID && existing.Position >= shelf.Position {
+			shelf.Position = existing.Position + 1
+		}
+	}
+
+	newRow(&shelf.ID, &shelf.Timestamp)
+	s.DB.shelves = append(s.DB.shelves, shelf)
+	s.DB.mu.Unlock()
+
+	publishEvent(s.Bus, actorID, shelf.RoomID, shelfCreatedEvent(shelf))
+	return nil
+}
+
+func (s *MemoryShelfData) GetShelvesByRoomID(roomID uuid.UUID) []Shelf {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	return s.DB.roomShelves(roomID)
+}
+
+func (s *MemoryShelfData) RenameShelf(shelfID uuid.UUID, name string, actorID uuid.UUID) (*Shelf, error) {
+	s.DB.mu.Lock()
+	shelf := s.DB.shelfRow(shelfID)
+	if shelf == nil {
+		s.DB.mu.Unlock()
+		return nil, pg.ErrNoRows
+	}
+
+	role, err := s.DB.writableRole(shelf.RoomID, actorID)
+	if err != nil {
+		s.DB.mu.Unlock()
+		return nil, err
+	}
+
+	if !role.Can(PermissionCreateShelf) {
+		s.DB.mu.Unlock()
+		return nil, ErrForbidden
+	}
+
+	shelf.Name = name
+	renamed := *shelf
+	s.DB.mu.Unlock()
+
+	publishEvent(s.Bus, actorID, renamed.RoomID, shelfRenamedEvent(renamed))
+	return &renamed, nil
+}
+
+func (s *MemoryShelfData) ReorderShelves(roomID uuid.UUID, shelfIDs []uuid.UUID, actorID uuid.UUID) ([]Shelf, error) {
+	s.DB.mu.Lock()
+	role, err := s.DB.writableRole(roomID, actorID)
+	if err != nil {
+		s.DB.mu.Unlock()
+		return nil, err
+	}
+
+	if !role.Can(PermissionCreateShelf) {
+		s.DB.mu.Unlock()
+		return nil, ErrForbidden
+	}
+
+	shelves, err := orderShelves(s.DB.roomShelves(roomID), shelfIDs)
+	if err != nil {
+		s.DB.mu.Unlock()
+		return nil, err
+	}
+
+	for _, shelf := range shelves {
+		s.DB.shelfRow(shelf.ID).Position = shelf.Position
+	}
+	s.DB.mu.Unlock()
+
+	publishEvent(s.Bus, actorID, roomID, roomShelvesReorderedEvent(roomID, shelves))
+	return shelves, nil
+}
+
+func (s *MemoryShelfData) DeleteShelf(shelfID, actorID uuid.UUID) error {
+	s.DB.mu.Lock()
+	shelf, exists := s.DB.shelf(shelfID)
+	if !exists {
+		s.DB.mu.Unlock()
+		return pg.ErrNoRows
+	}
+
+	role, err := s.DB.writableRole(shelf.RoomID, actorID)
+	if err != nil {
+		s.DB.mu.Unlock()
+		return err
+	}
+
+	if !role.Can(PermissionDelete) {
+		s.DB.mu.Unlock()
+		return ErrForbidden
+	}
+
+	s.DB.deleteShelves(func(existing Shelf) bool {
+		return existing.ID == shelfID
+	})
+	s.DB.mu.Unlock()
+
+	publishEvent(s.Bus, actorID, shelf.RoomID, shelfDeletedEvent(shelf))
+	return nil
+}
+
+func (s *MemoryShelfData) GetShelfMoviesByID(shelfID, userID uuid.UUID, streamable bool) []Movie {
+	s.DB.mu.RLock()
+	movies := s.shelfMovies(shelfID)
+	room := s.shelfRoom(shelfID)
+	locale := s.DB.locale(s.Env, userID, room.ID)
+	s.DB.mu.RUnlock()
+
+	s.Metadata.attachMetadata(moviePointers(movies), locale.Language)
+	attachAvailability(moviePointers(movies), s.Env, room)
+
+	if streamable {
+		return streamableMovies(movies)
+	}
+	return movies
+}
+
+func (s *MemoryShelfData) GetShelfInfoByID(shelfID uuid.UUID) Shelf {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	shelf, _ := s.DB.shelf(shelfID)
+	return shelf
+}
+
+func (s *MemoryShelfData) GetAvailableMovies(ctx context.Context, shelfID, userID uuid.UUID, search MovieSearch, excludeExisting bool) (*themoviedb.SearchMovieResp, error) {
+	s.DB.mu.RLock()
+	search.withLocale(s.DB.locale(s.Env, userID, s.shelfRoom(shelfID).ID))
+	s.DB.mu.RUnlock()
+
+	resp, err := searchMedia(ctx, s.Provider, search)
+	if err != nil {
+		return nil, err
+	}
+
+	if excludeExisting {
+		s.DB.mu.RLock()
+		existingMovies := s.shelfMovies(shelfID)
+		s.DB.mu.RUnlock()
+
+		excludeExistingMovies(resp, existingMovies)
+	}
+
+	return resp, nil
+}
+
+func (s *MemoryShelfData) GetShelfRole(shelfID, userID uuid.UUID) (Role, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	shelf, exists := s.DB.shelf(shelfID)
+	if !exists {
+		return "", nil
+	}
+
+	return s.DB.role(shelf.RoomID, userID), nil
+}
+
+func (s *MemoryShelfData) shelfRoom(shelfID uuid.UUID) Room {
+	shelf, _ := s.DB.shelf(shelfID)
+	room, _ := s.DB.room(shelf.RoomID)
+	return room
+}
+
+func (s *MemoryShelfData) shelfMovies(shelfID uuid.UUID) []Movie {
+	movies := make([]Movie, 0)
+	for _, movie := range s.DB.movies {
+		if movie.ShelfID == shelfID {
+			movies = append(movies, movie)
+		}
+	}
+	return movies
+}
+
+// roomShelves returns the shelves of the room in their order.
+func (d *MemoryDB) roomShelves(roomID uuid.UUID) []Shelf {
+	var shelves []Shelf
+	for _, shelf := range d.shelves {
+		if shelf.RoomID == roomID {
+			shelves = append(shelves, shelf)
+		}
+	}
+
+	sort.SliceStable(shelves, func(a, b int) bool {
+		return shelves[a].Position < shelves[b].Position
+	})
+	return shelves
+}
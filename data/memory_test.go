@@ -0,0 +1,261 @@
+package data
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adamelfsborg-code/movie-nest/config"
+	"github.com/adamelfsborg-code/movie-nest/events"
+	"github.com/google/uuid"
+)
+
+func newTestStores(t *testing.T) Stores {
+	t.Helper()
+	return NewMemoryStores(config.Environments{}, NewMemoryDB(), events.NewNoopBus())
+}
+
+func registerTestUser(t *testing.T, stores Stores, name string) uuid.UUID {
+	t.Helper()
+
+	user := User{ID: uuid.New(), Name: name, Password: "secret"}
+	err := stores.Users.Register(user)
+	if err != nil {
+		t.Fatalf("Register(%v): %v", name, err)
+	}
+	return user.ID
+}
+
+func createTestRoom(t *testing.T, stores Stores, ownerID uuid.UUID) uuid.UUID {
+	t.Helper()
+
+	room := NewRoom("Movie night")
+	room.ID = uuid.New()
+	err := stores.Rooms.CreateRoom(*room, ownerID)
+	if err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+	return room.ID
+}
+
+func createTestShelf(t *testing.T, stores Stores, roomID, actorID uuid.UUID, name string) uuid.UUID {
+	t.Helper()
+
+	shelf := NewShelf(name, roomID)
+	shelf.ID = uuid.New()
+	err := stores.Shelves.CreateShelf(*shelf, actorID)
+	if err != nil {
+		t.Fatalf("CreateShelf(%v): %v", name, err)
+	}
+	return shelf.ID
+}
+
+func joinTestRoom(t *testing.T, stores Stores, roomID, ownerID, userID uuid.UUID, role Role) {
+	t.Helper()
+
+	invitation, err := stores.Invitations.InviteUser(*NewRoomInvitation(roomID, userID, role), ownerID)
+	if err != nil {
+		t.Fatalf("InviteUser: %v", err)
+	}
+
+	_, err = stores.Invitations.AcceptInvitation(invitation.ID, userID)
+	if err != nil {
+		t.Fatalf("AcceptInvitation: %v", err)
+	}
+}
+
+func TestMemoryRoomRoles(t *testing.T) {
+	stores := newTestStores(t)
+	owner := registerTestUser(t, stores, "owner")
+	member := registerTestUser(t, stores, "member")
+	outsider := registerTestUser(t, stores, "outsider")
+
+	roomID := createTestRoom(t, stores, owner)
+	joinTestRoom(t, stores, roomID, owner, member, RoleMember)
+
+	tests := []struct {
+		userID uuid.UUID
+		role   Role
+	}{
+		{owner, RoleOwner},
+		{member, RoleMember},
+		{outsider, ""},
+	}
+
+	for _, test := range tests {
+		role, _ := stores.Rooms.GetRoomRole(roomID, test.userID)
+		if role != test.role {
+			t.Errorf("GetRoomRole = %q, want %q", role, test.role)
+		}
+	}
+
+	err := stores.Rooms.DeleteRoom(roomID, member)
+	if !errors.Is(err, ErrForbidden) {
+		t.Errorf("DeleteRoom by member = %v, want %v", err, ErrForbidden)
+	}
+
+	err = stores.Rooms.LeaveRoom(roomID, owner)
+	if !errors.Is(err, ErrOwnerCannotLeave) {
+		t.Errorf("LeaveRoom by owner = %v, want %v", err, ErrOwnerCannotLeave)
+	}
+
+	err = stores.Rooms.LeaveRoom(roomID, member)
+	if err != nil {
+		t.Fatalf("LeaveRoom by member: %v", err)
+	}
+
+	if role, _ := stores.Rooms.GetRoomRole(roomID, member); role != "" {
+		t.Errorf("GetRoomRole after leaving = %q, want none", role)
+	}
+}
+
+func TestMemoryArchivedRoomRejectsWrites(t *testing.T) {
+	stores := newTestStores(t)
+	owner := registerTestUser(t, stores, "owner")
+	roomID := createTestRoom(t, stores, owner)
+
+	_, err := stores.Rooms.SetRoomArchived(roomID, true, owner)
+	if err != nil {
+		t.Fatalf("SetRoomArchived: %v", err)
+	}
+
+	err = stores.Shelves.CreateShelf(*NewShelf("Later", roomID), owner)
+	if !errors.Is(err, ErrRoomArchived) {
+		t.Errorf("CreateShelf in archived room = %v, want %v", err, ErrRoomArchived)
+	}
+
+	_, err = stores.Rooms.GetRoomInfoByID(roomID, owner)
+	if err != nil {
+		t.Errorf("GetRoomInfoByID in archived room: %v", err)
+	}
+}
+
+func TestMemoryShelfOrder(t *testing.T) {
+	stores := newTestStores(t)
+	owner := registerTestUser(t, stores, "owner")
+	roomID := createTestRoom(t, stores, owner)
+
+	first := createTestShelf(t, stores, roomID, owner, "First")
+	second := createTestShelf(t, stores, roomID, owner, "Second")
+
+	_, err := stores.Shelves.ReorderShelves(roomID, []uuid.UUID{second}, owner)
+	if !errors.Is(err, ErrShelfOrder) {
+		t.Errorf("ReorderShelves with a missing shelf = %v, want %v", err, ErrShelfOrder)
+	}
+
+	_, err = stores.Shelves.ReorderShelves(roomID, []uuid.UUID{second, first}, owner)
+	if err != nil {
+		t.Fatalf("ReorderShelves: %v", err)
+	}
+
+	shelves := stores.Shelves.GetShelvesByRoomID(roomID)
+	if len(shelves) != 2 || shelves[0].ID != second || shelves[1].ID != first {
+		t.Errorf("GetShelvesByRoomID after reorder = %+v, want second then first", shelves)
+	}
+}
+
+func TestMemoryMoveAndCopyMovie(t *testing.T) {
+	stores := newTestStores(t)
+	owner := registerTestUser(t, stores, "owner")
+	member := registerTestUser(t, stores, "member")
+
+	roomID := createTestRoom(t, stores, owner)
+	joinTestRoom(t, stores, roomID, owner, member, RoleMember)
+	from := createTestShelf(t, stores, roomID, owner, "From")
+	to := createTestShelf(t, stores, roomID, owner, "To")
+
+	otherRoomID := createTestRoom(t, stores, owner)
+	otherShelf := createTestShelf(t, stores, otherRoomID, owner, "Other")
+
+	movie := NewMovie(603, from)
+	movie.ID = uuid.New()
+	err := stores.Movies.CreateMovie(*movie, member)
+	if err != nil {
+		t.Fatalf("CreateMovie: %v", err)
+	}
+
+	copied, err := stores.Movies.CopyMovie(movie.ID, to, member)
+	if err != nil {
+		t.Fatalf("CopyMovie: %v", err)
+	}
+	if copied.ID == movie.ID || copied.ShelfID != to {
+		t.Errorf("CopyMovie = %+v, want a new movie on the target shelf", copied)
+	}
+
+	_, err = stores.Movies.CopyMovie(movie.ID, to, member)
+	if !errors.Is(err, ErrMovieOnShelf) {
+		t.Errorf("CopyMovie twice = %v, want %v", err, ErrMovieOnShelf)
+	}
+
+	_, err = stores.Movies.MoveMovie(movie.ID, otherShelf, owner)
+	if !errors.Is(err, ErrOtherRoom) {
+		t.Errorf("MoveMovie to another room = %v, want %v", err, ErrOtherRoom)
+	}
+
+	err = stores.Movies.RemoveMovie(copied.ID, member)
+	if !errors.Is(err, ErrForbidden) {
+		t.Errorf("RemoveMovie by member = %v, want %v", err, ErrForbidden)
+	}
+
+	err = stores.Movies.RemoveMovie(copied.ID, owner)
+	if err != nil {
+		t.Fatalf("RemoveMovie by owner: %v", err)
+	}
+
+	if movies := stores.Shelves.GetShelfMoviesByID(to, owner, false); len(movies) != 0 {
+		t.Errorf("GetShelfMoviesByID after remove = %v movies, want 0", len(movies))
+	}
+}
+
+func TestMemoryPartialRoomUpdate(t *testing.T) {
+	bus := events.NewChannelBus()
+	defer bus.Close()
+
+	published := make(chan events.Event, 16)
+	_, err := bus.Subscribe("rooms.>", func(event events.Event) {
+		published <- event
+	})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	stores := NewMemoryStores(config.Environments{}, NewMemoryDB(), bus)
+	owner := registerTestUser(t, stores, "owner")
+	roomID := createTestRoom(t, stores, owner)
+
+	subscriptions, err := NewRoomUpdate(nil, nil, nil, &[]uint{8})
+	if err != nil {
+		t.Fatalf("NewRoomUpdate: %v", err)
+	}
+	_, err = stores.Rooms.UpdateRoom(roomID, *subscriptions, owner)
+	if err != nil {
+		t.Fatalf("UpdateRoom(subscriptions): %v", err)
+	}
+
+	language, region := "sv-SE", "SE"
+	locale, err := NewRoomUpdate(nil, &language, &region, nil)
+	if err != nil {
+		t.Fatalf("NewRoomUpdate: %v", err)
+	}
+	room, err := stores.Rooms.UpdateRoom(roomID, *locale, owner)
+	if err != nil {
+		t.Fatalf("UpdateRoom(locale): %v", err)
+	}
+
+	if room.Name != "Movie night" || room.Language != language || room.Region != region || len(room.Subscriptions) != 1 {
+		t.Errorf("UpdateRoom = %+v, want the locale changed and the rest kept", room)
+	}
+
+	// Both updates, the subscriptions and the locale, are published.
+	subject := "rooms." + roomID.String() + ".updated"
+	for updated := 0; updated < 2; {
+		select {
+		case event := <-published:
+			if event.Subject == subject {
+				updated++
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("Received %v %v events, want 2", updated, subject)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package data
+
+import (
+	"fmt"
+
+	"github.com/adamelfsborg-code/movie-nest/config"
+	"github.com/adamelfsborg-code/movie-nest/pkg/themoviedb"
+	"github.com/adamelfsborg-code/movie-nest/shared"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+)
+
+type MemoryUserData struct {
+	Env config.Environments
+	DB  *MemoryDB
+}
+
+func (u *MemoryUserData) Register(user User) error {
+	u.DB.mu.Lock()
+	defer u.DB.mu.Unlock()
+
+	for _, existing := range u.DB.users {
+		if existing.Name == user.Name {
+			return violatesUnique("users", "name")
+		}
+	}
+
+	newRow(&user.ID, &user.Timestamp)
+	u.DB.users = append(u.DB.users, user)
+	return nil
+}
+
+func (u *MemoryUserData) List() []User {
+	u.DB.mu.RLock()
+	defer u.DB.mu.RUnlock()
+
+	users := make([]User, len(u.DB.users))
+	copy(users, u.DB.users)
+	return users
+}
+
+func (u *MemoryUserData) Login(name, password string) (string, error) {
+	u.DB.mu.RLock()
+	var user User
+	for _, existing := range u.DB.users {
+		if existing.Name == name {
+			user = existing
+			break
+		}
+	}
+	u.DB.mu.RUnlock()
+
+	valid := shared.CheckPasswordHash(password, user.Password)
+	if valid == false {
+		return "", fmt.Errorf("User does not exists")
+	}
+
+	return signUserToken(u.Env, user)
+}
+
+func (u *MemoryUserData) GetUserInfoByID(userID uuid.UUID) User {
+	u.DB.mu.RLock()
+	defer u.DB.mu.RUnlock()
+
+	user, _ := u.DB.user(userID)
+	return user
+}
+
+func (u *MemoryUserData) CheckUserExistsByID(userID uuid.UUID) bool {
+	u.DB.mu.RLock()
+	defer u.DB.mu.RUnlock()
+
+	_, exists := u.DB.user(userID)
+	return exists
+}
+
+func (u *MemoryUserData) GetUsersInRoom(roomID uuid.UUID, userID uuid.UUID, excludeSelf bool) []User {
+	u.DB.mu.RLock()
+	defer u.DB.mu.RUnlock()
+
+	var users []User
+	for _, user := range u.DB.roomMembers(roomID) {
+		if excludeSelf && user.ID == userID {
+			continue
+		}
+		users = append(users, user)
+	}
+	return users
+}
+
+func (u *MemoryUserData) SetUserLocale(userID uuid.UUID, locale themoviedb.Locale) error {
+	u.DB.mu.Lock()
+	defer u.DB.mu.Unlock()
+
+	for i := range u.DB.users {
+		if u.DB.users[i].ID == userID {
+			u.DB.users[i].Language = locale.Language
+			u.DB.users[i].Region = locale.Region
+			return nil
+		}
+	}
+	return pg.ErrNoRows
+}
@@ -0,0 +1,103 @@
+package data
+
+import (
+	"time"
+
+	"github.com/adamelfsborg-code/movie-nest/pkg/themoviedb"
+	"github.com/go-pg/pg/v10"
+)
+
+// MovieMetadata is the last TMDB response for a movie or TV series, keyed
+// by media type, TMDB id and language.
+type MovieMetadata struct {
+	tableName struct{} `pg:"movie_metadata"`
+
+	MediaType   string           `json:"media_type" db:"media_type" pg:",pk"`
+	MovieID     uint             `json:"movie_id" db:"movie_id" pg:",pk"`
+	Language    string           `json:"language" db:"language" pg:",pk,use_zero"`
+	Data        themoviedb.Movie `json:"data" db:"data"`
+	FetchedAt   time.Time        `json:"fetched_at" db:"fetched_at"`
+	LastError   string           `json:"last_error" db:"last_error"`
+	LastErrorAt *time.Time       `json:"last_error_at" db:"last_error_at"`
+}
+
+func (m *MovieMetadata) key() MediaKey {
+	return MediaKey{MediaType: m.MediaType, ID: m.MovieID, Language: m.Language}
+}
+
+type MetadataStore interface {
+	GetMetadata(keys []MediaKey) ([]MovieMetadata, error)
+	SaveMetadata(metadata MovieMetadata) error
+	SaveMetadataError(key MediaKey, fetchErr error) error
+	StaleMetadata(fetchedBefore, failedBefore time.Time, defaultLanguage string, limit int) ([]MediaKey, error)
+}
+
+type MetadataData struct {
+	DB *pg.DB
+}
+
+func (m *MetadataData) GetMetadata(keys []MediaKey) ([]MovieMetadata, error) {
+	var metadata []MovieMetadata
+	if len(keys) == 0 {
+		return metadata, nil
+	}
+
+	tuples := make([]interface{}, len(keys))
+	for i, key := range keys {
+		tuples[i] = []interface{}{key.MediaType, key.ID, key.Language}
+	}
+
+	err := m.DB.Model(&metadata).Where("(media_type, movie_id, language) IN (?)", pg.InMulti(tuples...)).Select()
+	if err != nil {
+		return nil, err
+	}
+
+	return metadata, nil
+}
+
+func (m *MetadataData) SaveMetadata(metadata MovieMetadata) error {
+	_, err := m.DB.Model(&metadata).
+		OnConflict("(media_type, movie_id, language) DO UPDATE").
+		Set("data = EXCLUDED.data").
+		Set("fetched_at = EXCLUDED.fetched_at").
+		Set("last_error = NULL").
+		Set("last_error_at = NULL").
+		Insert()
+	return err
+}
+
+// SaveMetadataError records a failed refresh on an existing entry, the
+// cached data is kept and served until a refresh succeeds.
+func (m *MetadataData) SaveMetadataError(key MediaKey, fetchErr error) error {
+	_, err := m.DB.Model((*MovieMetadata)(nil)).
+		Set("last_error = ?", fetchErr.Error()).
+		Set("last_error_at = now()").
+		Where("media_type = ? AND movie_id = ? AND language = ?", key.MediaType, key.ID, key.Language).
+		Update()
+	return err
+}
+
+// StaleMetadata lists the cached entries of movies and TV series on any
+// shelf that were fetched before fetchedBefore, and the shelf items that
+// have no metadata at all in defaultLanguage. Entries that failed to
+// refresh after failedBefore are skipped.
+func (m *MetadataData) StaleMetadata(fetchedBefore, failedBefore time.Time, defaultLanguage string, limit int) ([]MediaKey, error) {
+	var keys []MediaKey
+
+	_, err := m.DB.Query(&keys, `
+		SELECT DISTINCT m.media_type, m.movie_id AS id, COALESCE(mm.language, ?) AS language
+		FROM movies m
+		LEFT JOIN movie_metadata mm ON mm.media_type = m.media_type AND mm.movie_id = m.movie_id
+		WHERE mm.movie_id IS NULL
+			OR (
+				mm.fetched_at < ?
+				AND (mm.last_error_at IS NULL OR mm.last_error_at < ?)
+			)
+		LIMIT ?
+	`, defaultLanguage, fetchedBefore, failedBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,251 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/adamelfsborg-code/movie-nest/config"
+	"github.com/adamelfsborg-code/movie-nest/pkg/metadata"
+	"github.com/adamelfsborg-code/movie-nest/pkg/themoviedb"
+)
+
+// MetadataCache keeps TMDB metadata in a MetadataStore. Reads are served
+// from the store and only go to TMDB for movies that were never fetched.
+// Entries older than TTL are still served while they are refreshed in the
+// background, so views keep working when TMDB is down.
+type MetadataCache struct {
+	Store      MetadataStore
+	Fetch      func(ctx context.Context, key MediaKey) (*themoviedb.Movie, error)
+	TTL        time.Duration
+	RetryAfter time.Duration
+	Interval   time.Duration
+	BatchSize  int
+	Metrics    *themoviedb.Metrics
+
+	// DefaultLanguage is the language shelf items are refreshed in before
+	// anyone looked at them.
+	DefaultLanguage string
+
+	mu         sync.Mutex
+	refreshing map[MediaKey]struct{}
+	failed     map[MediaKey]time.Time
+}
+
+func NewMetadataCache(env config.Environments, provider metadata.Provider, store MetadataStore) *MetadataCache {
+	return &MetadataCache{
+		Store:           store,
+		Fetch:           fetchMedia(provider),
+		TTL:             env.MovieMetadataTTL,
+		RetryAfter:      time.Minute * 5,
+		Interval:        time.Minute,
+		BatchSize:       20,
+		Metrics:         themoviedb.DefaultMetrics,
+		DefaultLanguage: firstNonEmpty(env.MovieDBLanguage, themoviedb.DefaultLanguage),
+		refreshing:      make(map[MediaKey]struct{}),
+		failed:          make(map[MediaKey]time.Time),
+	}
+}
+
+func fetchMedia(provider metadata.Provider) func(ctx context.Context, key MediaKey) (*themoviedb.Movie, error) {
+	return func(ctx context.Context, key MediaKey) (*themoviedb.Movie, error) {
+		return provider.Get(ctx, metadata.Key{MediaType: key.MediaType, ID: key.ID, Language: key.Language})
+	}
+}
+
+// Movie is a read-through lookup of one movie or TV series.
+func (c *MetadataCache) Movie(ctx context.Context, key MediaKey) (*themoviedb.Movie, error) {
+	cached, err := c.Store.GetMetadata([]MediaKey{key})
+	if err != nil {
+		fmt.Println("Failed to read movie metadata: ", err)
+	}
+
+	c.observe(len(cached) > 0)
+
+	if len(cached) > 0 {
+		if c.stale(cached[0]) {
+			go c.revalidate(key)
+		}
+
+		movie := cached[0].Data
+		return &movie, nil
+	}
+
+	return c.Refresh(ctx, key)
+}
+
+// Movies returns the cached metadata of keys without waiting on TMDB.
+// Missing and stale entries are fetched in the background.
+func (c *MetadataCache) Movies(keys []MediaKey) map[MediaKey]themoviedb.Movie {
+	movies := make(map[MediaKey]themoviedb.Movie)
+
+	cached, err := c.Store.GetMetadata(keys)
+	if err != nil {
+		fmt.Println("Failed to read movie metadata: ", err)
+	}
+
+	var pending []MediaKey
+	for _, metadata := range cached {
+		movies[metadata.key()] = metadata.Data
+		if c.stale(metadata) {
+			pending = append(pending, metadata.key())
+		}
+	}
+
+	for _, key := range keys {
+		_, exists := movies[key]
+		c.observe(exists)
+		if !exists {
+			pending = append(pending, key)
+		}
+	}
+
+	if len(pending) > 0 {
+		go func() {
+			for _, key := range pending {
+				c.revalidate(key)
+			}
+		}()
+	}
+
+	return movies
+}
+
+// Refresh fetches a movie or TV series from TMDB and stores it.
+func (c *MetadataCache) Refresh(ctx context.Context, key MediaKey) (*themoviedb.Movie, error) {
+	movie, err := c.Fetch(ctx, key)
+	if err != nil {
+		saveErr := c.Store.SaveMetadataError(key, err)
+		if saveErr != nil {
+			fmt.Println("Failed to save movie metadata error: ", saveErr)
+		}
+		return nil, err
+	}
+
+	err = c.Store.SaveMetadata(MovieMetadata{
+		MediaType: key.MediaType,
+		MovieID:   key.ID,
+		Language:  key.Language,
+		Data:      *movie,
+		FetchedAt: time.Now(),
+	})
+	if err != nil {
+		fmt.Println("Failed to save movie metadata: ", err)
+	}
+
+	return movie, nil
+}
+
+// Run refreshes stale and missing metadata of the movies on any shelf
+// every Interval until ctx is cancelled.
+func (c *MetadataCache) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+
+	for {
+		err := c.RefreshBatch()
+		if err != nil {
+			fmt.Println("Failed to refresh movie metadata: ", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *MetadataCache) RefreshBatch() error {
+	now := time.Now()
+
+	keys, err := c.Store.StaleMetadata(now.Add(-c.TTL), now.Add(-c.RetryAfter), c.DefaultLanguage, c.BatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		c.revalidate(key)
+	}
+
+	return nil
+}
+
+func (c *MetadataCache) stale(metadata MovieMetadata) bool {
+	now := time.Now()
+
+	if metadata.FetchedAt.After(now.Add(-c.TTL)) {
+		return false
+	}
+
+	return metadata.LastErrorAt == nil || metadata.LastErrorAt.Before(now.Add(-c.RetryAfter))
+}
+
+// revalidate refreshes an entry unless a refresh of it is already running
+// or failed less than RetryAfter ago.
+func (c *MetadataCache) revalidate(key MediaKey) {
+	c.mu.Lock()
+	if _, exists := c.refreshing[key]; exists {
+		c.mu.Unlock()
+		return
+	}
+	if failedAt, exists := c.failed[key]; exists && time.Since(failedAt) < c.RetryAfter {
+		c.mu.Unlock()
+		return
+	}
+	c.refreshing[key] = struct{}{}
+	c.mu.Unlock()
+
+	_, err := c.Refresh(context.Background(), key)
+
+	c.mu.Lock()
+	delete(c.refreshing, key)
+	delete(c.failed, key)
+	if err != nil {
+		c.pruneFailed()
+		c.failed[key] = time.Now()
+	}
+	c.mu.Unlock()
+
+	if err != nil {
+		fmt.Printf("Failed to refresh %v %v (%v): %v\n", key.MediaType, key.ID, key.Language, err)
+	}
+}
+
+// pruneFailed forgets failures older than RetryAfter, so keys that keep
+// failing, such as movies deleted from TMDB, do not pile up.
+func (c *MetadataCache) pruneFailed() {
+	for key, failedAt := range c.failed {
+		if time.Since(failedAt) >= c.RetryAfter {
+			delete(c.failed, key)
+		}
+	}
+}
+
+// attachMetadata sets Details on movies from whatever is cached in
+// language.
+func (c *MetadataCache) attachMetadata(movies []*Movie, language string) {
+	if len(movies) == 0 {
+		return
+	}
+
+	keys := make([]MediaKey, 0, len(movies))
+	for _, movie := range movies {
+		keys = append(keys, movie.mediaKey(language))
+	}
+
+	cached := c.Movies(keys)
+	for _, movie := range movies {
+		if details, exists := cached[movie.mediaKey(language)]; exists {
+			movie.Details = &details
+			movie.attachSeason()
+		}
+	}
+}
+
+func (c *MetadataCache) observe(hit bool) {
+	if c.Metrics != nil {
+		c.Metrics.ObserveCache("movie_metadata", hit)
+	}
+}
@@ -0,0 +1,48 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adamelfsborg-code/movie-nest/pkg/themoviedb"
+)
+
+func TestMetadataCacheForgetsOldFailures(t *testing.T) {
+	fetches := 0
+	cache := &MetadataCache{
+		Store: &MemoryMetadataData{DB: NewMemoryDB()},
+		Fetch: func(ctx context.Context, key MediaKey) (*themoviedb.Movie, error) {
+			fetches++
+			return nil, themoviedb.ErrNotFound
+		},
+		RetryAfter: time.Millisecond * 50,
+		refreshing: make(map[MediaKey]struct{}),
+		failed:     make(map[MediaKey]time.Time),
+	}
+
+	gone := MediaKey{MediaType: themoviedb.MediaMovie, ID: 1, Language: "en-US"}
+	cache.revalidate(gone)
+	cache.revalidate(gone)
+	if fetches != 1 {
+		t.Errorf("Fetched %v times within RetryAfter, want 1", fetches)
+	}
+
+	time.Sleep(cache.RetryAfter)
+
+	other := MediaKey{MediaType: themoviedb.MediaMovie, ID: 2, Language: "en-US"}
+	cache.revalidate(other)
+
+	if _, exists := cache.failed[gone]; exists {
+		t.Error("Failure older than RetryAfter was kept")
+	}
+	if _, exists := cache.failed[other]; !exists || len(cache.failed) != 1 {
+		t.Errorf("failed = %v, want only the latest failure", cache.failed)
+	}
+
+	_, err := cache.Refresh(context.Background(), gone)
+	if !errors.Is(err, themoviedb.ErrNotFound) {
+		t.Errorf("Refresh = %v, want %v", err, themoviedb.ErrNotFound)
+	}
+}
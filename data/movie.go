@@ -1,27 +1,53 @@
 package data
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/adamelfsborg-code/movie-nest/config"
+	"github.com/adamelfsborg-code/movie-nest/pkg/metadata"
+	"github.com/adamelfsborg-code/movie-nest/pkg/omdb"
 	"github.com/adamelfsborg-code/movie-nest/pkg/themoviedb"
 	"github.com/go-pg/pg/v10"
 	"github.com/google/uuid"
-	"github.com/nats-io/nats.go"
+)
+
+var (
+	ErrOtherRoom    = errors.New("Movies can only be moved or copied to shelves in the same room")
+	ErrMovieOnShelf = errors.New("The movie is already on that shelf")
 )
 
 type MovieData struct {
-	Env  config.Environments
-	DB   pg.DB
-	Nats *nats.Conn
+	Env      config.Environments
+	DB       *pg.DB
+	Provider metadata.Provider
+	Metadata *MetadataCache
 }
 
+// Movie is a TMDB movie or TV series on a shelf, MovieID is its TMDB id
+// and MediaType tells which. A TV series can be narrowed to one season
+// with SeasonNumber. Details holds the cached TMDB metadata when it is
+// available and Season the matching season of it. Availability tells
+// where it can be watched in the room's region.
 type Movie struct {
-	ID      uuid.UUID `json:"id" db:"id"`
-	MovieID uint      `json:"movie_id" db:"movie_id"`
-	ShelfID uuid.UUID `json:"shelf_id" db:"shelf_id"`
+	ID           uuid.UUID          `json:"id" db:"id"`
+	MediaType    string             `json:"media_type" db:"media_type"`
+	MovieID      uint               `json:"movie_id" db:"movie_id"`
+	SeasonNumber *uint              `json:"season_number,omitempty" db:"season_number"`
+	ShelfID      uuid.UUID          `json:"shelf_id" db:"shelf_id"`
+	Details      *themoviedb.Movie  `json:"details,omitempty" db:"-" pg:"-"`
+	Season       *themoviedb.Season `json:"season,omitempty" db:"-" pg:"-"`
+	Availability *Availability      `json:"availability,omitempty" db:"-" pg:"-"`
+}
+
+// MediaKey identifies a TMDB movie or TV series in one language, TMDB ids
+// are only unique per media type.
+type MediaKey struct {
+	MediaType string
+	ID        uint
+	Language  string
 }
 
 type MovieAvgRating struct {
@@ -52,44 +78,156 @@ type MovieRatingResp struct {
 
 func NewMovie(movieID uint, shelfID uuid.UUID) *Movie {
 	return &Movie{
-		MovieID: movieID,
-		ShelfID: shelfID,
+		MediaType: themoviedb.MediaMovie,
+		MovieID:   movieID,
+		ShelfID:   shelfID,
 	}
 }
 
-func (m *MovieData) CreateMovie(movie Movie) error {
-	_, err := m.DB.Model(&movie).Insert()
-	data, _ := json.Marshal(&movie)
-	m.Nats.Publish(fmt.Sprintf("shelves.%v.movies.new", &movie.ShelfID), []byte(data))
-	return err
+// NewMediaItem returns a movie or TV series for a shelf, seasonNumber is
+// only allowed for TV series. An empty mediaType is a movie.
+func NewMediaItem(mediaType string, tmdbID uint, seasonNumber *uint, shelfID uuid.UUID) (*Movie, error) {
+	switch mediaType {
+	case "", themoviedb.MediaMovie:
+		if seasonNumber != nil {
+			return nil, fmt.Errorf("Movies have no seasons")
+		}
+		return NewMovie(tmdbID, shelfID), nil
+	case themoviedb.MediaTV:
+		return &Movie{
+			MediaType:    themoviedb.MediaTV,
+			MovieID:      tmdbID,
+			SeasonNumber: seasonNumber,
+			ShelfID:      shelfID,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w %q", ErrUnknownMediaType, mediaType)
 }
 
-func (m *MovieData) GetMovie(movieID uint) (*themoviedb.Movie, error) {
-	movieDB := themoviedb.NewMovieDBOptions(m.Env.MovieDBAuthToken, "")
+func (m *Movie) mediaKey(language string) MediaKey {
+	return MediaKey{MediaType: m.MediaType, ID: m.MovieID, Language: language}
+}
 
-	movie, err := movieDB.GetMovie(movieID)
-	if err != nil {
-		return nil, err
+// attachSeason sets Season from Details when the item is narrowed to one.
+func (m *Movie) attachSeason() {
+	if m.Details == nil || m.SeasonNumber == nil {
+		return
+	}
+
+	m.Season, _ = m.Details.Season(*m.SeasonNumber)
+}
+
+func newMovieDB(env config.Environments) *themoviedb.MovieDBOptions {
+	movieDB := themoviedb.NewMovieDBOptions(env.MovieDBAuthToken, "")
+	if env.MovieDBBaseURL != "" {
+		movieDB.BaseURL = env.MovieDBBaseURL
 	}
+	if env.MovieDBTimeout > 0 {
+		movieDB.Timeout = env.MovieDBTimeout
+	}
+	movieDB.LogSampleRate = env.MovieDBLogSample
+	return movieDB
+}
+
+// newProvider is TMDB, completed by and falling back to OMDb when
+// OMDB_API_KEY is set.
+func newProvider(env config.Environments) metadata.Provider {
+	tmdb := metadata.NewTMDB(newMovieDB(env))
+	if env.OMDbApiKey == "" {
+		return tmdb
+	}
+
+	client := omdb.NewClient(env.OMDbApiKey)
+	if env.OMDbBaseURL != "" {
+		client.BaseURL = env.OMDbBaseURL
+	}
+	if env.MovieDBTimeout > 0 {
+		client.Timeout = env.MovieDBTimeout
+	}
+
+	return metadata.NewComposite(tmdb, metadata.NewOMDb(client))
+}
+
+func moviePointers(movies []Movie) []*Movie {
+	pointers := make([]*Movie, len(movies))
+	for i := range movies {
+		pointers[i] = &movies[i]
+	}
+	return pointers
+}
+
+func (m *MovieData) CreateMovie(movie Movie, actorID uuid.UUID) error {
+	return m.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		var shelf Shelf
+		err := tx.Model(&shelf).Where("id = ?", &movie.ShelfID).Select()
+		if err != nil {
+			return fmt.Errorf("Failed to get shelf: %w", err)
+		}
 
-	return movie, nil
+		role, err := writableRoomRole(tx, shelf.RoomID, actorID)
+		if err != nil {
+			return err
+		}
+
+		if !role.Can(PermissionAddMovie) {
+			return ErrForbidden
+		}
+
+		_, err = tx.Model(&movie).Insert()
+		if err != nil {
+			return err
+		}
+
+		return enqueueEvent(tx, actorID, shelf.RoomID, shelfMovieAddedEvent(shelf.RoomID, movie))
+	})
 }
 
-func (m *MovieData) GetMovieDetails(movieID uuid.UUID) (*MovieDetails, error) {
-	movieDB := themoviedb.NewMovieDBOptions(m.Env.MovieDBAuthToken, "")
+func (m *MovieData) GetMovie(ctx context.Context, movieID uint, userID uuid.UUID) (*themoviedb.Movie, error) {
+	locale := userLocale(m.DB, m.Env, userID, uuid.Nil)
+	return m.Metadata.Movie(ctx, MediaKey{MediaType: themoviedb.MediaMovie, ID: movieID, Language: locale.Language})
+}
 
+func (m *MovieData) GetTV(ctx context.Context, tvID uint, userID uuid.UUID) (*themoviedb.Movie, error) {
+	locale := userLocale(m.DB, m.Env, userID, uuid.Nil)
+	return m.Metadata.Movie(ctx, MediaKey{MediaType: themoviedb.MediaTV, ID: tvID, Language: locale.Language})
+}
+
+// FindByIMDbID looks up a movie or TV series by IMDb id, straight from the
+// metadata provider.
+func (m *MovieData) FindByIMDbID(ctx context.Context, imdbID string, userID uuid.UUID) (*themoviedb.Movie, error) {
+	locale := userLocale(m.DB, m.Env, userID, uuid.Nil)
+	return m.Provider.FindByExternalID(ctx, metadata.ExternalID{Source: metadata.SourceIMDb, ID: imdbID}, locale.Language)
+}
+
+func (m *MovieData) GetMovieDetails(ctx context.Context, movieID, userID uuid.UUID) (*MovieDetails, error) {
 	movie := &Movie{}
 
-	err := m.DB.Model(movie).Where("id = ?", &movieID).Select()
+	err := m.DB.ModelContext(ctx, movie).Where("id = ?", &movieID).Select()
+	if err != nil {
+		return nil, err
+	}
+
+	room, err := shelfRoom(m.DB, movie.ShelfID)
 	if err != nil {
 		return nil, err
 	}
 
-	details, err := movieDB.GetMovie(movie.MovieID)
+	locale := userLocale(m.DB, m.Env, userID, room.ID)
+
+	details, err := m.Metadata.Movie(ctx, movie.mediaKey(locale.Language))
 	if err != nil {
 		return nil, err
 	}
 
+	if movie.SeasonNumber != nil {
+		movie.Season, _ = details.Season(*movie.SeasonNumber)
+	}
+
+	movie.Details = details
+	attachAvailability([]*Movie{movie}, m.Env, room)
+	movie.Details = nil
+
 	var movieRatingResp []MovieRatingResp
 
 	m.DB.Query(&movieRatingResp, `
@@ -125,8 +263,209 @@ func (m *MovieData) GetMovieDetails(movieID uuid.UUID) (*MovieDetails, error) {
 }
 
 func (m *MovieData) RateMovie(rating MovieRating) error {
-	_, err := m.DB.Model(&rating).Insert()
-	data, _ := json.Marshal(&rating)
-	m.Nats.Publish(fmt.Sprintf("movies.%v.rated", &rating.MovieID), []byte(data))
-	return err
+	return m.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		var shelf Shelf
+		err := tx.Model(&shelf).
+			Join("JOIN movies m ON m.shelf_id = shelf.id").
+			Where("m.id = ?", &rating.MovieID).
+			Select()
+		if err != nil {
+			return fmt.Errorf("Failed to get movie: %w", err)
+		}
+
+		role, err := writableRoomRole(tx, shelf.RoomID, rating.UserID)
+		if err != nil {
+			return err
+		}
+
+		if !role.Can(PermissionRate) {
+			return ErrForbidden
+		}
+
+		_, err = tx.Model(&rating).Insert()
+		if err != nil {
+			return err
+		}
+
+		return enqueueEvent(tx, rating.UserID, shelf.RoomID, shelfMovieRatedEvent(shelf.RoomID, shelf.ID, rating))
+	})
+}
+
+// RemoveMovie takes the movie off its shelf, its ratings are deleted with
+// it.
+func (m *MovieData) RemoveMovie(movieID, actorID uuid.UUID) error {
+	return m.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		var movie Movie
+		err := tx.Model(&movie).Where("id = ?", movieID).For("UPDATE").Select()
+		if err != nil {
+			return err
+		}
+
+		var shelf Shelf
+		err = tx.Model(&shelf).Where("id = ?", movie.ShelfID).Select()
+		if err != nil {
+			return fmt.Errorf("Failed to get shelf: %w", err)
+		}
+
+		role, err := writableRoomRole(tx, shelf.RoomID, actorID)
+		if err != nil {
+			return err
+		}
+
+		if !role.Can(PermissionDelete) {
+			return ErrForbidden
+		}
+
+		_, err = tx.Model(&movie).WherePK().Delete()
+		if err != nil {
+			return err
+		}
+
+		return enqueueEvent(tx, actorID, shelf.RoomID, shelfMovieRemovedEvent(shelf.RoomID, movie))
+	})
+}
+
+// MoveMovie puts the movie on another shelf of the same room, keeping its
+// ratings. Moving it to the shelf it is on changes nothing.
+func (m *MovieData) MoveMovie(movieID, shelfID, actorID uuid.UUID) (*Movie, error) {
+	var movie Movie
+
+	err := m.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		var err error
+		var roomID uuid.UUID
+		movie, roomID, err = movieForShelf(tx, movieID, shelfID, actorID)
+		if err != nil {
+			return err
+		}
+
+		if movie.ShelfID == shelfID {
+			return nil
+		}
+
+		err = requireNotOnShelf(tx, movie, shelfID)
+		if err != nil {
+			return err
+		}
+
+		fromShelfID := movie.ShelfID
+		movie.ShelfID = shelfID
+		_, err = tx.Model(&movie).Column("shelf_id").WherePK().Update()
+		if err != nil {
+			return err
+		}
+
+		return enqueueEvent(tx, actorID, roomID, shelfMovieMovedEvent(roomID, fromShelfID, movie))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &movie, nil
+}
+
+// CopyMovie puts the movie on another shelf of the same room as well. The
+// copy starts without ratings.
+func (m *MovieData) CopyMovie(movieID, shelfID, actorID uuid.UUID) (*Movie, error) {
+	var movie Movie
+
+	err := m.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		original, roomID, err := movieForShelf(tx, movieID, shelfID, actorID)
+		if err != nil {
+			return err
+		}
+
+		err = requireNotOnShelf(tx, original, shelfID)
+		if err != nil {
+			return err
+		}
+
+		movie = Movie{
+			MediaType:    original.MediaType,
+			MovieID:      original.MovieID,
+			SeasonNumber: original.SeasonNumber,
+			ShelfID:      shelfID,
+		}
+		_, err = tx.Model(&movie).Insert()
+		if err != nil {
+			return err
+		}
+
+		return enqueueEvent(tx, actorID, roomID, shelfMovieAddedEvent(roomID, movie))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &movie, nil
+}
+
+// movieForShelf loads a movie that is moved or copied to shelfID and
+// returns its room. The shelf has to be in the same room and the actor
+// needs the add_movie permission there.
+func movieForShelf(tx *pg.Tx, movieID, shelfID, actorID uuid.UUID) (Movie, uuid.UUID, error) {
+	var movie Movie
+	err := tx.Model(&movie).Where("id = ?", movieID).For("UPDATE").Select()
+	if err != nil {
+		return movie, uuid.Nil, err
+	}
+
+	var shelf Shelf
+	err = tx.Model(&shelf).Where("id = ?", movie.ShelfID).Select()
+	if err != nil {
+		return movie, uuid.Nil, fmt.Errorf("Failed to get shelf: %w", err)
+	}
+
+	role, err := writableRoomRole(tx, shelf.RoomID, actorID)
+	if err != nil {
+		return movie, uuid.Nil, err
+	}
+
+	if !role.Can(PermissionAddMovie) {
+		return movie, uuid.Nil, ErrForbidden
+	}
+
+	var target Shelf
+	err = tx.Model(&target).Where("id = ?", shelfID).Select()
+	if err != nil {
+		return movie, uuid.Nil, err
+	}
+
+	if target.RoomID != shelf.RoomID {
+		return movie, uuid.Nil, ErrOtherRoom
+	}
+	return movie, shelf.RoomID, nil
+}
+
+func requireNotOnShelf(tx *pg.Tx, movie Movie, shelfID uuid.UUID) error {
+	exists, err := tx.Model((*Movie)(nil)).
+		Where("shelf_id = ? AND media_type = ? AND movie_id = ?", shelfID, movie.MediaType, movie.MovieID).
+		Where("season_number IS NOT DISTINCT FROM ?", movie.SeasonNumber).
+		Exists()
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return ErrMovieOnShelf
+	}
+	return nil
+}
+
+// GetMovieRole returns the role of userID in the room of the shelf the
+// movie is on.
+func (m *MovieData) GetMovieRole(movieID, userID uuid.UUID) (Role, error) {
+	var shelf Shelf
+	err := m.DB.Model(&shelf).
+		Column("shelf.room_id").
+		Join("JOIN movies m ON m.shelf_id = shelf.id").
+		Where("m.id = ?", movieID).
+		Select()
+	if err == pg.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return roomRole(m.DB, shelf.RoomID, userID)
 }
@@ -0,0 +1,225 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/adamelfsborg-code/movie-nest/events"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/google/uuid"
+)
+
+// OutboxEvent is a NATS message written in the same transaction as the
+// change it describes. OutboxRelay publishes it once the transaction has
+// committed, so rolled back writes never produce events.
+type OutboxEvent struct {
+	ID            uuid.UUID  `json:"id" db:"id"`
+	Subject       string     `json:"subject" db:"subject"`
+	Payload       []byte     `json:"payload" db:"payload"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	Attempts      int        `json:"attempts" db:"attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" db:"next_attempt_at"`
+	LastError     string     `json:"last_error" db:"last_error"`
+	PublishedAt   *time.Time `json:"published_at" db:"published_at"`
+}
+
+func enqueueEvent(db orm.DB, actorID, roomID uuid.UUID, payload events.Payload) error {
+	event, err := events.NewEnvelopeEvent(actorID, roomID, payload)
+	if err != nil {
+		return err
+	}
+
+	// The row shares the envelope id so the relay can publish it as the
+	// message id and retries are deduplicated by JetStream.
+	id, err := uuid.Parse(event.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Model(&OutboxEvent{
+		ID:      id,
+		Subject: event.Subject,
+		Payload: event.Data,
+	}).Insert()
+	if err != nil {
+		return fmt.Errorf("Failed to enqueue event %v: %w", event.Subject, err)
+	}
+
+	return nil
+}
+
+type OutboxRelay struct {
+	DB          *pg.DB
+	Bus         events.Bus
+	Interval    time.Duration
+	BatchSize   int
+	MaxAttempts int
+	MaxBackoff  time.Duration
+	Retention   time.Duration
+}
+
+func NewOutboxRelay(db *pg.DB, bus events.Bus) *OutboxRelay {
+	return &OutboxRelay{
+		DB:          db,
+		Bus:         bus,
+		Interval:    time.Second,
+		BatchSize:   100,
+		MaxAttempts: 20,
+		MaxBackoff:  time.Minute * 5,
+		Retention:   time.Hour * 24,
+	}
+}
+
+// Run relays pending events until the context is cancelled.
+func (o *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(o.Interval)
+	defer ticker.Stop()
+
+	var lastPrune time.Time
+
+	for {
+		for {
+			relayed, err := o.RelayBatch(ctx)
+			if err != nil {
+				fmt.Println("Failed to relay outbox events: ", err)
+			}
+			if err != nil || relayed < o.BatchSize {
+				break
+			}
+		}
+
+		if time.Since(lastPrune) > time.Minute*10 {
+			err := o.prune(ctx)
+			if err != nil {
+				fmt.Println("Failed to prune outbox events: ", err)
+			}
+			lastPrune = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayBatch publishes up to BatchSize due events and returns how many it
+// picked up. Rows are locked with SKIP LOCKED so several API instances can
+// relay from the same table.
+//
+// Delivery is at-least-once: an event whose publish could not be confirmed
+// is published again later, possibly after newer events. Consumers have to
+// deduplicate on the envelope id, as JetStream, the realtime hub and the
+// websocket gateway do.
+func (o *OutboxRelay) RelayBatch(ctx context.Context) (int, error) {
+	var relayed int
+
+	err := o.DB.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		var pending []OutboxEvent
+		err := tx.ModelContext(ctx, &pending).
+			Where("published_at IS NULL").
+			Where("attempts < ?", o.MaxAttempts).
+			Where("next_attempt_at <= now()").
+			Order("created_at ASC").
+			Limit(o.BatchSize).
+			For("UPDATE SKIP LOCKED").
+			Select()
+		if err != nil {
+			return err
+		}
+
+		relayed = len(pending)
+		if relayed == 0 {
+			return nil
+		}
+
+		published, failed := o.publish(pending)
+
+		if len(published) > 0 {
+			_, err := tx.ModelContext(ctx, (*OutboxEvent)(nil)).
+				Set("published_at = now()").
+				Where("id IN (?)", pg.In(published)).
+				Update()
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, event := range failed {
+			backoff := o.backoff(event.Attempts + 1)
+			_, err := tx.ModelContext(ctx, (*OutboxEvent)(nil)).
+				Set("attempts = attempts + 1").
+				Set("last_error = ?", event.LastError).
+				Set("next_attempt_at = now() + ? * interval '1 millisecond'", backoff.Milliseconds()).
+				Where("id = ?", event.ID).
+				Update()
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return relayed, err
+}
+
+// publish hands events to the bus. Events are only published once the bus
+// confirmed them with a flush, when the flush fails the events handed to
+// it are retried. Events the bus refused keep their own error.
+func (o *OutboxRelay) publish(pending []OutboxEvent) ([]uuid.UUID, []OutboxEvent) {
+	var published []OutboxEvent
+	var failed []OutboxEvent
+
+	for _, event := range pending {
+		err := o.Bus.Publish(events.Event{
+			ID:      event.ID.String(),
+			Subject: event.Subject,
+			Data:    event.Payload,
+		})
+		if err != nil {
+			event.LastError = err.Error()
+			failed = append(failed, event)
+			continue
+		}
+		published = append(published, event)
+	}
+
+	if len(published) == 0 {
+		return nil, failed
+	}
+
+	err := o.Bus.Flush(time.Second * 5)
+	if err != nil {
+		for _, event := range published {
+			event.LastError = err.Error()
+			failed = append(failed, event)
+		}
+		return nil, failed
+	}
+
+	ids := make([]uuid.UUID, len(published))
+	for i, event := range published {
+		ids[i] = event.ID
+	}
+	return ids, failed
+}
+
+func (o *OutboxRelay) backoff(attempts int) time.Duration {
+	backoff := time.Duration(math.Pow(2, float64(attempts))) * time.Second
+	if backoff <= 0 || backoff > o.MaxBackoff {
+		return o.MaxBackoff
+	}
+	return backoff
+}
+
+func (o *OutboxRelay) prune(ctx context.Context) error {
+	_, err := o.DB.ModelContext(ctx, (*OutboxEvent)(nil)).
+		Where("published_at < ?", time.Now().Add(-o.Retention)).
+		Delete()
+	return err
+}
@@ -0,0 +1,88 @@
+package data
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adamelfsborg-code/movie-nest/events"
+	"github.com/google/uuid"
+)
+
+// flakyBus refuses events on rejected and fails every flush when
+// flushErr is set.
+type flakyBus struct {
+	events.NoopBus
+	rejected  string
+	flushErr  error
+	published []string
+}
+
+func (b *flakyBus) Publish(event events.Event) error {
+	if event.Subject == b.rejected {
+		return errors.New("subject rejected")
+	}
+	b.published = append(b.published, event.ID)
+	return nil
+}
+
+func (b *flakyBus) Flush(timeout time.Duration) error {
+	return b.flushErr
+}
+
+func outboxEvents(subjects ...string) []OutboxEvent {
+	pending := make([]OutboxEvent, len(subjects))
+	for i, subject := range subjects {
+		pending[i] = OutboxEvent{ID: uuid.New(), Subject: subject}
+	}
+	return pending
+}
+
+func TestOutboxRelayPublish(t *testing.T) {
+	pending := outboxEvents("rooms.a.updated", "rooms.b.updated", "rooms.c.updated")
+
+	bus := &flakyBus{rejected: "rooms.b.updated"}
+	relay := NewOutboxRelay(nil, bus)
+
+	published, failed := relay.publish(pending)
+
+	if len(published) != 2 || published[0] != pending[0].ID || published[1] != pending[2].ID {
+		t.Errorf("Published %v, want the first and last event", published)
+	}
+	if len(failed) != 1 || failed[0].ID != pending[1].ID || failed[0].LastError != "subject rejected" {
+		t.Errorf("Failed %+v, want the rejected event with its error", failed)
+	}
+}
+
+func TestOutboxRelayPublishFlushFails(t *testing.T) {
+	pending := outboxEvents("rooms.a.updated", "rooms.b.updated", "rooms.c.updated")
+
+	bus := &flakyBus{rejected: "rooms.b.updated", flushErr: errors.New("flush timed out")}
+	relay := NewOutboxRelay(nil, bus)
+
+	published, failed := relay.publish(pending)
+
+	if len(published) != 0 {
+		t.Errorf("Published %v, want none as nothing was confirmed", published)
+	}
+
+	// Every event is retried, the rejected one keeps its own error.
+	errs := make(map[uuid.UUID]string)
+	for _, event := range failed {
+		errs[event.ID] = event.LastError
+	}
+
+	want := map[uuid.UUID]string{
+		pending[0].ID: "flush timed out",
+		pending[1].ID: "subject rejected",
+		pending[2].ID: "flush timed out",
+	}
+	if len(errs) != len(want) || len(failed) != len(want) {
+		t.Fatalf("Failed %+v, want each of the %v events once", failed, len(want))
+	}
+	for id, lastError := range want {
+		if errs[id] != lastError {
+			t.Errorf("Last error of %v = %q, want %q", id, errs[id], lastError)
+		}
+	}
+}
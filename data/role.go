@@ -0,0 +1,115 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrForbidden is returned by writes the acting user's room role does not
+// allow.
+var ErrForbidden = errors.New("Permission Not Allowd")
+
+// Role is a member's role in a room. The empty role is returned for users
+// that are not members and allows nothing.
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleAdmin  Role = "admin"
+	RoleMember Role = "member"
+	RoleViewer Role = "viewer"
+)
+
+type Permission string
+
+const (
+	PermissionView        Permission = "view"
+	PermissionRate        Permission = "rate"
+	PermissionAddMovie    Permission = "add_movie"
+	PermissionCreateShelf Permission = "create_shelf"
+	PermissionInvite      Permission = "invite"
+	PermissionManageRoom  Permission = "manage_room"
+	PermissionDelete      Permission = "delete"
+)
+
+// rolePermissions is the permission matrix. Owners and admins differ in
+// rank only, see Role.CanAssign.
+var rolePermissions = map[Role][]Permission{
+	RoleOwner: {
+		PermissionView, PermissionRate, PermissionAddMovie, PermissionCreateShelf,
+		PermissionInvite, PermissionManageRoom, PermissionDelete,
+	},
+	RoleAdmin: {
+		PermissionView, PermissionRate, PermissionAddMovie, PermissionCreateShelf,
+		PermissionInvite, PermissionManageRoom, PermissionDelete,
+	},
+	RoleMember: {
+		PermissionView, PermissionRate, PermissionAddMovie, PermissionCreateShelf,
+	},
+	RoleViewer: {
+		PermissionView,
+	},
+}
+
+var roleRanks = map[Role]int{
+	RoleViewer: 1,
+	RoleMember: 2,
+	RoleAdmin:  3,
+	RoleOwner:  4,
+}
+
+// NewRole validates a role given by a client. An empty role defaults to
+// member. Owner cannot be given, a room has one owner, its creator.
+func NewRole(role string) (Role, error) {
+	if role == "" {
+		return RoleMember, nil
+	}
+
+	switch Role(role) {
+	case RoleAdmin, RoleMember, RoleViewer:
+		return Role(role), nil
+	}
+	return "", fmt.Errorf("role must be one of %v, %v or %v", RoleAdmin, RoleMember, RoleViewer)
+}
+
+func (r Role) Can(permission Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Role) Permissions() []Permission {
+	return append([]Permission{}, rolePermissions[r]...)
+}
+
+// CanAssign reports whether a member with role r may give role to a member
+// who has current, current being empty for new members. Roles can only be
+// handed out and taken away below one's own rank.
+func (r Role) CanAssign(current, role Role) bool {
+	if current != "" && roleRanks[current] >= roleRanks[r] {
+		return false
+	}
+	return roleRanks[role] < roleRanks[r]
+}
+
+// Outranks reports whether r is above other, the way members can only be
+// removed by someone above them.
+func (r Role) Outranks(other Role) bool {
+	return roleRanks[r] > roleRanks[other]
+}
+
+// RoomRole is a user's role in a room, as returned by the role endpoints.
+type RoomRole struct {
+	Role        Role         `json:"role"`
+	Permissions []Permission `json:"permissions"`
+}
+
+func NewRoomRole(role Role) RoomRole {
+	return RoomRole{
+		Role:        role,
+		Permissions: role.Permissions(),
+	}
+}
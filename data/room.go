@@ -1,30 +1,53 @@
 package data
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/adamelfsborg-code/movie-nest/config"
+	"github.com/adamelfsborg-code/movie-nest/events"
+	"github.com/adamelfsborg-code/movie-nest/pkg/themoviedb"
 	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
 	"github.com/google/uuid"
-	"github.com/nats-io/nats.go"
 )
 
+var (
+	ErrRoomArchived     = errors.New("Room is archived")
+	ErrOwnerCannotLeave = errors.New("The owner has to transfer the room before leaving")
+)
+
+// RoomData stores rooms in Postgres. Events go through the outbox, Bus
+// only carries the revocations that have to reach the gateways right away.
 type RoomData struct {
-	DB   pg.DB
-	Nats *nats.Conn
+	DB       *pg.DB
+	Env      config.Environments
+	Bus      events.Bus
+	Metadata *MetadataCache
 }
 
+// Room is a group of users sharing shelves. Language and Region are the
+// default locale of its members, see resolveLocale. Region is also where
+// shelf items are looked up on Subscriptions, the TMDB ids of the
+// streaming services the members have. Archived rooms are read-only.
 type Room struct {
-	ID        uuid.UUID `json:"id" db:"id"`
-	Name      string    `json:"name" db:"name"`
-	Timestamp time.Time `json:"timestamp" db:"timestamp"`
+	ID            uuid.UUID  `json:"id" db:"id"`
+	Name          string     `json:"name" db:"name"`
+	Language      string     `json:"language,omitempty" db:"language"`
+	Region        string     `json:"region,omitempty" db:"region"`
+	Subscriptions []uint     `json:"subscriptions,omitempty" db:"subscriptions" pg:",array"`
+	ArchivedAt    *time.Time `json:"archived_at,omitempty" db:"archived_at"`
+	Timestamp     time.Time  `json:"timestamp" db:"timestamp"`
 }
 
 type RoomUser struct {
 	ID        uuid.UUID `json:"id" db:"id"`
 	RoomID    uuid.UUID `json:"room_id" db:"room_id"`
 	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	Role      Role      `json:"role" db:"role"`
 	Timestamp time.Time `json:"timestamp" db:"timestamp"`
 }
 
@@ -44,30 +67,100 @@ type UserRooms struct {
 	Rooms []*Room `json:"rooms" db:"rooms"`
 }
 
+func (r *RoomInfo) movies() []*Movie {
+	var movies []*Movie
+	for _, shelf := range r.Shelves {
+		movies = append(movies, shelf.Movies...)
+	}
+	return movies
+}
+
 func NewRoom(name string) *Room {
 	return &Room{
 		Name: name,
 	}
 }
 
-func NewRoomUser(roomID, userID uuid.UUID) *RoomUser {
-	return &RoomUser{
-		RoomID: roomID,
-		UserID: userID,
+// RoomUpdate holds the room fields to change, nil fields are kept. Empty
+// Language and Region clear the room's default.
+type RoomUpdate struct {
+	Name          *string
+	Language      *string
+	Region        *string
+	Subscriptions *[]uint
+}
+
+func NewRoomUpdate(name, language, region *string, providerIDs *[]uint) (*RoomUpdate, error) {
+	update := &RoomUpdate{
+		Language: language,
+		Region:   region,
+	}
+
+	if name != nil {
+		trimmed := strings.TrimSpace(*name)
+		if trimmed == "" {
+			return nil, fmt.Errorf("name must not be empty")
+		}
+		update.Name = &trimmed
+	}
+
+	var locale themoviedb.Locale
+	if language != nil {
+		locale.Language = *language
+	}
+	if region != nil {
+		locale.Region = *region
+	}
+
+	_, err := NewLocale(locale.Language, locale.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	if providerIDs != nil {
+		subscriptions, err := NewSubscriptions(*providerIDs)
+		if err != nil {
+			return nil, err
+		}
+		update.Subscriptions = &subscriptions
+	}
+
+	return update, nil
+}
+
+func (u RoomUpdate) apply(room *Room) {
+	if u.Name != nil {
+		room.Name = *u.Name
+	}
+	if u.Language != nil {
+		room.Language = *u.Language
+	}
+	if u.Region != nil {
+		room.Region = *u.Region
+	}
+	if u.Subscriptions != nil {
+		room.Subscriptions = append([]uint(nil), (*u.Subscriptions)...)
 	}
 }
 
 func (r *RoomData) CreateRoom(room Room, userID uuid.UUID) error {
-	_, err := r.DB.Model(&room).Insert()
-
-	r.AddUserToRoom(RoomUser{
-		RoomID: room.ID,
-		UserID: userID,
+	return r.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		_, err := tx.Model(&room).Insert()
+		if err != nil {
+			return err
+		}
+
+		err = enqueueEvent(tx, userID, room.ID, roomCreatedEvent(room))
+		if err != nil {
+			return err
+		}
+
+		return r.addUserToRoom(tx, RoomUser{
+			RoomID: room.ID,
+			UserID: userID,
+			Role:   RoleOwner,
+		}, userID)
 	})
-
-	data, _ := json.Marshal(room)
-	r.Nats.Publish(fmt.Sprintf("rooms.users.%v.created", &userID), []byte(data))
-	return err
 }
 
 func (r *RoomData) ListRooms() []Room {
@@ -85,7 +178,9 @@ func (r *RoomData) GetRoomByID(roomID uuid.UUID) (*Room, error) {
 	return &room, nil
 }
 
-func (r *RoomData) GetRoomInfoByID(roomID uuid.UUID) (*RoomInfo, error) {
+// GetRoomInfoByID returns the room with its members and shelves, movie
+// details are in the locale of userID.
+func (r *RoomData) GetRoomInfoByID(roomID, userID uuid.UUID) (*RoomInfo, error) {
 	var roomInfo RoomInfo
 
 	_, _ = r.DB.Query(&roomInfo, `
@@ -93,16 +188,20 @@ func (r *RoomData) GetRoomInfoByID(roomID uuid.UUID) (*RoomInfo, error) {
 			SELECT s.id AS shelf_id, 
 				jsonb_build_object(
 					'id', m.id,
+					'media_type', m.media_type,
 					'movie_id', m.movie_id,
+					'season_number', m.season_number,
 					'timestamp', m."timestamp"
 				) AS movie
 			FROM shelves s
 			JOIN movies m ON s.id = m.shelf_id
-			WHERE s.room_id = '26e126f7-84d9-41dd-843f-44931badece5'
+			WHERE s.room_id = ?
 		)
 		SELECT 
 			jsonb_build_object(
-				'id', r.id, 'name', r."name", 'timestamp', r."timestamp"
+				'id', r.id, 'name', r."name", 'language', r.language, 'region', r.region,
+				'subscriptions', r.subscriptions, 'archived_at', r.archived_at,
+				'timestamp', r."timestamp"
 			) AS room,
 			(
 				SELECT jsonb_agg(
@@ -117,34 +216,376 @@ func (r *RoomData) GetRoomInfoByID(roomID uuid.UUID) (*RoomInfo, error) {
 			(
 				SELECT jsonb_agg(
 					jsonb_build_object(
-						'id', s.id, 'name', s."name", 'timestamp', s."timestamp", 'movies', 
+						'id', s.id, 'name', s."name", 'position', s.position, 'timestamp', s."timestamp", 'movies', 
 						COALESCE((SELECT jsonb_agg(movie) FROM ShelfMovies WHERE shelf_id = s.id), '[]'::jsonb)
 					)
+					ORDER BY s.position, s."timestamp"
 				)
 				FROM shelves s
 				WHERE s.room_id = r.id
 			) AS shelves
 		FROM rooms r
 		where r.id = ?
-	`, &roomID)
+	`, &roomID, &roomID)
+
+	locale := userLocale(r.DB, r.Env, userID, roomID)
+	r.Metadata.attachMetadata(roomInfo.movies(), locale.Language)
+	if roomInfo.Room != nil {
+		attachAvailability(roomInfo.movies(), r.Env, *roomInfo.Room)
+	}
 	return &roomInfo, nil
 }
 
-func (r *RoomData) AddUserToRoom(roomUser RoomUser) error {
-	_, err := r.DB.Model(&roomUser).Insert()
+// SetRoomUserRole changes the role of a member. The actor has to outrank
+// both the member's current and new role.
+func (r *RoomData) SetRoomUserRole(roomID, userID uuid.UUID, role Role, actorID uuid.UUID) error {
+	return r.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		actorRole, err := writableRoomRole(tx, roomID, actorID)
+		if err != nil {
+			return err
+		}
+
+		if !actorRole.Can(PermissionManageRoom) {
+			return ErrForbidden
+		}
+
+		var roomUser RoomUser
+		err = tx.Model(&roomUser).
+			Where("room_id = ? AND user_id = ?", roomID, userID).
+			For("UPDATE").
+			Select()
+		if err != nil {
+			return err
+		}
+
+		if !actorRole.CanAssign(roomUser.Role, role) {
+			return ErrForbidden
+		}
+
+		previous := roomUser.Role
+		roomUser.Role = role
+		_, err = tx.Model(&roomUser).Column("role").WherePK().Update()
+		if err != nil {
+			return err
+		}
+
+		var room Room
+		err = tx.Model(&room).Where("id = ?", roomID).Select()
+		if err != nil {
+			return fmt.Errorf("Failed to get room: %w", err)
+		}
+
+		var user User
+		err = tx.Model(&user).Where("id = ?", userID).Select()
+		if err != nil {
+			return fmt.Errorf("Failed to get user: %w", err)
+		}
+
+		return enqueueEvent(tx, actorID, roomID, roomMemberRoleChangedEvent(room, user, previous, role))
+	})
+}
+
+// UpdateRoom renames the room and changes its settings. The actor needs
+// the manage_room permission.
+func (r *RoomData) UpdateRoom(roomID uuid.UUID, update RoomUpdate, actorID uuid.UUID) (*Room, error) {
+	var room Room
+
+	err := r.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		role, err := writableRoomRole(tx, roomID, actorID)
+		if err != nil {
+			return err
+		}
+
+		if !role.Can(PermissionManageRoom) {
+			return ErrForbidden
+		}
+
+		err = tx.Model(&room).Where("id = ?", roomID).For("UPDATE").Select()
+		if err != nil {
+			return err
+		}
+
+		update.apply(&room)
+		_, err = tx.Model(&room).
+			Column("name", "language", "region", "subscriptions").
+			WherePK().
+			Update()
+		if err != nil {
+			return err
+		}
+
+		return enqueueEvent(tx, actorID, roomID, roomUpdatedEvent(room))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &room, nil
+}
+
+// SetRoomArchived archives or restores the room. Archiving an archived room
+// keeps the first time and publishes nothing.
+func (r *RoomData) SetRoomArchived(roomID uuid.UUID, archived bool, actorID uuid.UUID) (*Room, error) {
+	var room Room
+
+	err := r.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		role, err := roomRole(tx, roomID, actorID)
+		if err != nil {
+			return err
+		}
+
+		if !role.Can(PermissionManageRoom) {
+			return ErrForbidden
+		}
+
+		err = tx.Model(&room).Where("id = ?", roomID).For("UPDATE").Select()
+		if err != nil {
+			return err
+		}
+
+		if archived == (room.ArchivedAt != nil) {
+			return nil
+		}
+
+		room.ArchivedAt = nil
+		if archived {
+			now := time.Now()
+			room.ArchivedAt = &now
+		}
+
+		_, err = tx.Model(&room).Column("archived_at").WherePK().Update()
+		if err != nil {
+			return err
+		}
+
+		if archived {
+			return enqueueEvent(tx, actorID, roomID, roomArchivedEvent(room))
+		}
+		return enqueueEvent(tx, actorID, roomID, roomUnarchivedEvent(room))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &room, nil
+}
+
+// LeaveRoom ends the membership of userID. Owners have to transfer the room
+// first.
+func (r *RoomData) LeaveRoom(roomID, userID uuid.UUID) error {
+	err := r.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		var roomUser RoomUser
+		err := tx.Model(&roomUser).
+			Where("room_id = ? AND user_id = ?", roomID, userID).
+			For("UPDATE").
+			Select()
+		if err != nil {
+			return err
+		}
+
+		if roomUser.Role == RoleOwner {
+			return ErrOwnerCannotLeave
+		}
+
+		room, user, err := deleteRoomUser(tx, roomUser)
+		if err != nil {
+			return err
+		}
+
+		return enqueueEvent(tx, userID, roomID, roomMemberLeftEvent(room, user))
+	})
+	if err != nil {
+		return err
+	}
+
+	r.revoke(roomID, userID)
+	return nil
+}
+
+// RemoveRoomUser removes a member the actor outranks.
+func (r *RoomData) RemoveRoomUser(roomID, userID, actorID uuid.UUID) error {
+	err := r.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		role, err := writableRoomRole(tx, roomID, actorID)
+		if err != nil {
+			return err
+		}
+
+		if !role.Can(PermissionManageRoom) {
+			return ErrForbidden
+		}
+
+		var roomUser RoomUser
+		err = tx.Model(&roomUser).
+			Where("room_id = ? AND user_id = ?", roomID, userID).
+			For("UPDATE").
+			Select()
+		if err != nil {
+			return err
+		}
+
+		if !role.Outranks(roomUser.Role) {
+			return ErrForbidden
+		}
+
+		room, user, err := deleteRoomUser(tx, roomUser)
+		if err != nil {
+			return err
+		}
+
+		return enqueueEvent(tx, actorID, roomID, roomMemberRemovedEvent(room, user))
+	})
+	if err != nil {
+		return err
+	}
+
+	r.revoke(roomID, userID)
+	return nil
+}
+
+// deleteRoomUser ends a membership and returns the room and user for the
+// event.
+func deleteRoomUser(tx *pg.Tx, roomUser RoomUser) (Room, User, error) {
+	var room Room
+	err := tx.Model(&room).Where("id = ?", roomUser.RoomID).Select()
+	if err != nil {
+		return room, User{}, fmt.Errorf("Failed to get room: %w", err)
+	}
 
 	var user User
+	err = tx.Model(&user).Where("id = ?", roomUser.UserID).Select()
+	if err != nil {
+		return room, user, fmt.Errorf("Failed to get user: %w", err)
+	}
+
+	_, err = tx.Model(&roomUser).WherePK().Delete()
+	return room, user, err
+}
+
+// TransferRoomOwnership makes userID the owner of the room. Only the owner
+// can, and stays on as an admin.
+func (r *RoomData) TransferRoomOwnership(roomID, userID, actorID uuid.UUID) error {
+	return r.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		role, err := writableRoomRole(tx, roomID, actorID)
+		if err != nil {
+			return err
+		}
+
+		if role != RoleOwner || userID == actorID {
+			return ErrForbidden
+		}
+
+		var roomUser RoomUser
+		err = tx.Model(&roomUser).
+			Where("room_id = ? AND user_id = ?", roomID, userID).
+			For("UPDATE").
+			Select()
+		if err != nil {
+			return err
+		}
+
+		// The previous owner steps down first, a room has one owner.
+		_, err = tx.Model((*RoomUser)(nil)).
+			Set("role = ?", RoleAdmin).
+			Where("room_id = ? AND user_id = ?", roomID, actorID).
+			Update()
+		if err != nil {
+			return err
+		}
+
+		roomUser.Role = RoleOwner
+		_, err = tx.Model(&roomUser).Column("role").WherePK().Update()
+		if err != nil {
+			return err
+		}
+
+		var room Room
+		err = tx.Model(&room).Where("id = ?", roomID).Select()
+		if err != nil {
+			return fmt.Errorf("Failed to get room: %w", err)
+		}
+
+		var user User
+		err = tx.Model(&user).Where("id = ?", userID).Select()
+		if err != nil {
+			return fmt.Errorf("Failed to get user: %w", err)
+		}
+
+		return enqueueEvent(tx, actorID, roomID, roomOwnershipTransferredEvent(room, actorID, user))
+	})
+}
+
+// DeleteRoom deletes the room with its shelves, movies and ratings. Only
+// the owner can, archived or not.
+func (r *RoomData) DeleteRoom(roomID, actorID uuid.UUID) error {
+	var userIDs []uuid.UUID
+	err := r.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		role, err := roomRole(tx, roomID, actorID)
+		if err != nil {
+			return err
+		}
+
+		if role != RoleOwner {
+			return ErrForbidden
+		}
+
+		var room Room
+		err = tx.Model(&room).Where("id = ?", roomID).For("UPDATE").Select()
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model((*RoomUser)(nil)).
+			Column("user_id").
+			Where("room_id = ?", roomID).
+			Select(&userIDs)
+		if err != nil {
+			return fmt.Errorf("Failed to get room users: %w", err)
+		}
+
+		_, err = tx.Model(&room).WherePK().Delete()
+		if err != nil {
+			return err
+		}
+
+		return enqueueEvent(tx, actorID, roomID, roomDeletedEvent(room))
+	})
+	if err != nil {
+		return err
+	}
+
+	r.revoke(roomID, userIDs...)
+	return nil
+}
+
+// revoke tells the gateways that the users lost access to the room once
+// the change is committed. The outbox event revokes access as well when
+// it arrives, so a failed publish is only logged.
+func (r *RoomData) revoke(roomID uuid.UUID, userIDs ...uuid.UUID) {
+	err := events.PublishRevocation(r.Bus, roomID, userIDs...)
+	if err != nil {
+		fmt.Println("Failed to publish revocation: ", err)
+	}
+}
+
+func (r *RoomData) addUserToRoom(tx orm.DB, roomUser RoomUser, actorID uuid.UUID) error {
+	var room Room
+	err := tx.Model(&room).Where("id = ?", &roomUser.RoomID).Select()
+	if err != nil {
+		return fmt.Errorf("Failed to get room: %w", err)
+	}
 
-	r.DB.Model(&user).Where("id = ?", &roomUser.UserID).Select()
-	data, _ := json.Marshal(user)
-	r.Nats.Publish(fmt.Sprintf("rooms.%v.users.new", &roomUser.RoomID), []byte(data))
+	var user User
+	err = tx.Model(&user).Where("id = ?", &roomUser.UserID).Select()
+	if err != nil {
+		return fmt.Errorf("Failed to get user: %w", err)
+	}
 
-	room, err := r.GetRoomByID(roomUser.RoomID)
-	data, _ = json.Marshal(room)
-	fmt.Printf("Publishing to: rooms.users.%v.added", &roomUser.UserID)
-	r.Nats.Publish(fmt.Sprintf("rooms.users.%v.added", &roomUser.UserID), []byte(data))
+	_, err = tx.Model(&roomUser).Insert()
+	if err != nil {
+		return err
+	}
 
-	return err
+	return enqueueEvent(tx, actorID, room.ID, roomMemberAddedEvent(room, user, roomUser))
 }
 
 func (r *RoomData) ListRoomsWithUsers() []RoomWithUser {
@@ -154,7 +595,9 @@ func (r *RoomData) ListRoomsWithUsers() []RoomWithUser {
 		SELECT 
 			jsonb_build_object
 			(
-				'id', r.id, 'name', r."name", 'timestamp', r."timestamp"
+				'id', r.id, 'name', r."name", 'language', r.language, 'region', r.region,
+				'subscriptions', r.subscriptions, 'archived_at', r.archived_at,
+				'timestamp', r."timestamp"
 			) AS room,
 			jsonb_agg
 			(
@@ -178,7 +621,9 @@ func (r *RoomData) GetRoomWithUsersByID(roomID uuid.UUID) RoomWithUser {
 		SELECT 
 			jsonb_build_object
 			(
-				'id', r.id, 'name', r."name", 'timestamp', r."timestamp"
+				'id', r.id, 'name', r."name", 'language', r.language, 'region', r.region,
+				'subscriptions', r.subscriptions, 'archived_at', r.archived_at,
+				'timestamp', r."timestamp"
 			) AS room,
 			jsonb_agg
 			(
@@ -223,22 +668,51 @@ func (r *RoomData) GetAvailableUsers(roomID uuid.UUID, userID uuid.UUID, searchT
 	return users
 }
 
-func (r *RoomData) GetRoomAccess(roomID, userID uuid.UUID) (bool, error) {
-	var room Room
+// GetRoomRole returns the role of userID in the room, empty when the user
+// is not a member.
+func (r *RoomData) GetRoomRole(roomID, userID uuid.UUID) (Role, error) {
+	return roomRole(r.DB, roomID, userID)
+}
 
-	query := r.DB.Model(&room).
-		Join(`JOIN room_users ru ON "room".id = "ru".room_id`).
-		Where(`"room".id = ? AND "ru".user_id = ?`, &roomID, &userID).
-		Select()
+// writableRoomRole is roomRole for writes, which archived rooms refuse.
+func writableRoomRole(db orm.DB, roomID, userID uuid.UUID) (Role, error) {
+	role, err := roomRole(db, roomID, userID)
+	if err != nil || role == "" {
+		return role, err
+	}
 
-	if query == pg.ErrNoRows {
-		return false, nil
+	err = requireWritableRoom(db, roomID)
+	if err != nil {
+		return "", err
 	}
+	return role, nil
+}
 
-	if query != nil {
-		return false, nil
+func requireWritableRoom(db orm.DB, roomID uuid.UUID) error {
+	archived, err := db.Model((*Room)(nil)).
+		Where("id = ? AND archived_at IS NOT NULL", roomID).
+		Exists()
+	if err != nil {
+		return err
 	}
 
-	return true, nil
+	if archived {
+		return ErrRoomArchived
+	}
+	return nil
+}
 
+func roomRole(db orm.DB, roomID, userID uuid.UUID) (Role, error) {
+	var roomUser RoomUser
+	err := db.Model(&roomUser).
+		Column("role").
+		Where("room_id = ? AND user_id = ?", roomID, userID).
+		Select()
+	if err == pg.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return roomUser.Role, nil
 }
@@ -1,34 +1,52 @@
 package data
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/adamelfsborg-code/movie-nest/config"
+	"github.com/adamelfsborg-code/movie-nest/pkg/metadata"
 	"github.com/adamelfsborg-code/movie-nest/pkg/themoviedb"
 	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
 	"github.com/google/uuid"
-	"github.com/nats-io/nats.go"
+)
+
+var (
+	ErrShelfOrder = errors.New("The order has to list every shelf of the room once")
+
+	// ErrUnknownMediaType, ErrDiscoverMediaType and ErrDiscoverUnsupported
+	// reject a MovieSearch before the provider is asked.
+	ErrUnknownMediaType    = errors.New("Unknown media type")
+	ErrDiscoverMediaType   = errors.New("Discover only supports movies")
+	ErrDiscoverUnsupported = errors.New("The movie provider can't discover movies")
 )
 
 type ShelfData struct {
-	DB   pg.DB
-	Env  config.Environments
-	Nats *nats.Conn
+	DB       *pg.DB
+	Env      config.Environments
+	Provider metadata.Provider
+	Metadata *MetadataCache
 }
 
+// Shelf is a list of movies in a room. Shelves are shown by Position, new
+// shelves go last.
 type Shelf struct {
 	ID        uuid.UUID `json:"id" db:"id"`
 	Name      string    `json:"name" db:"name"`
 	RoomID    uuid.UUID `json:"room_id" db:"room_id"`
+	Position  int       `json:"position" db:"position" pg:",use_zero"`
 	Timestamp time.Time `json:"timestamp" db:"timestamp"`
 }
 
 type ShelfMovies struct {
-	ID     uuid.UUID `json:"id" db:"id"`
-	Name   string    `json:"name" db:"name"`
-	Movies []*Movie  `json:"movies" db:"movies"`
+	ID       uuid.UUID `json:"id" db:"id"`
+	Name     string    `json:"name" db:"name"`
+	Position int       `json:"position" db:"position"`
+	Movies   []*Movie  `json:"movies" db:"movies"`
 }
 
 func NewShelf(name string, roomID uuid.UUID) *Shelf {
@@ -38,26 +56,196 @@ func NewShelf(name string, roomID uuid.UUID) *Shelf {
 	}
 }
 
-func (s *ShelfData) CreateShelf(shelf Shelf) error {
-	_, err := s.DB.Model(&shelf).Insert()
-	data, _ := json.Marshal(shelf)
-	s.Nats.Publish(fmt.Sprintf("rooms.%v.shelves.create", &shelf.RoomID), []byte(data))
-	return err
+// NewShelfName trims a shelf name given by a client.
+func NewShelfName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("name must not be empty")
+	}
+	return name, nil
+}
+
+func (s *ShelfData) CreateShelf(shelf Shelf, actorID uuid.UUID) error {
+	return s.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		role, err := writableRoomRole(tx, shelf.RoomID, actorID)
+		if err != nil {
+			return err
+		}
+
+		if !role.Can(PermissionCreateShelf) {
+			return ErrForbidden
+		}
+
+		_, err = tx.QueryOne(pg.Scan(&shelf.Position), `
+			SELECT COALESCE(MAX(position) + 1, 0) FROM shelves WHERE room_id = ?
+		`, shelf.RoomID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Model(&shelf).Insert()
+		if err != nil {
+			return err
+		}
+
+		return enqueueEvent(tx, actorID, shelf.RoomID, shelfCreatedEvent(shelf))
+	})
 }
 
 func (s *ShelfData) GetShelvesByRoomID(roomID uuid.UUID) []Shelf {
 	var shelf []Shelf
-	s.DB.Model(&shelf).Where("room_id = ?", &roomID).Select()
+	s.DB.Model(&shelf).Where("room_id = ?", &roomID).Order("position", "timestamp").Select()
 	return shelf
 }
 
-func (s *ShelfData) GetShelfMoviesByID(shelfID uuid.UUID) []Movie {
+func (s *ShelfData) RenameShelf(shelfID uuid.UUID, name string, actorID uuid.UUID) (*Shelf, error) {
+	var shelf Shelf
+
+	err := s.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		err := tx.Model(&shelf).Where("id = ?", shelfID).For("UPDATE").Select()
+		if err != nil {
+			return err
+		}
+
+		role, err := writableRoomRole(tx, shelf.RoomID, actorID)
+		if err != nil {
+			return err
+		}
+
+		if !role.Can(PermissionCreateShelf) {
+			return ErrForbidden
+		}
+
+		shelf.Name = name
+		_, err = tx.Model(&shelf).Column("name").WherePK().Update()
+		if err != nil {
+			return err
+		}
+
+		return enqueueEvent(tx, actorID, shelf.RoomID, shelfRenamedEvent(shelf))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &shelf, nil
+}
+
+// ReorderShelves puts the shelves of the room in the order of shelfIDs,
+// which has to list each of them once.
+func (s *ShelfData) ReorderShelves(roomID uuid.UUID, shelfIDs []uuid.UUID, actorID uuid.UUID) ([]Shelf, error) {
+	var shelves []Shelf
+
+	err := s.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		role, err := writableRoomRole(tx, roomID, actorID)
+		if err != nil {
+			return err
+		}
+
+		if !role.Can(PermissionCreateShelf) {
+			return ErrForbidden
+		}
+
+		var existing []Shelf
+		err = tx.Model(&existing).Where("room_id = ?", roomID).For("UPDATE").Select()
+		if err != nil {
+			return err
+		}
+
+		shelves, err = orderShelves(existing, shelfIDs)
+		if err != nil {
+			return err
+		}
+
+		for i := range shelves {
+			_, err = tx.Model(&shelves[i]).Column("position").WherePK().Update()
+			if err != nil {
+				return err
+			}
+		}
+
+		return enqueueEvent(tx, actorID, roomID, roomShelvesReorderedEvent(roomID, shelves))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return shelves, nil
+}
+
+// DeleteShelf deletes the shelf with its movies and ratings.
+func (s *ShelfData) DeleteShelf(shelfID, actorID uuid.UUID) error {
+	return s.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		var shelf Shelf
+		err := tx.Model(&shelf).Where("id = ?", shelfID).For("UPDATE").Select()
+		if err != nil {
+			return err
+		}
+
+		role, err := writableRoomRole(tx, shelf.RoomID, actorID)
+		if err != nil {
+			return err
+		}
+
+		if !role.Can(PermissionDelete) {
+			return ErrForbidden
+		}
+
+		_, err = tx.Model(&shelf).WherePK().Delete()
+		if err != nil {
+			return err
+		}
+
+		return enqueueEvent(tx, actorID, shelf.RoomID, shelfDeletedEvent(shelf))
+	})
+}
+
+// orderShelves returns shelves in the order of shelfIDs with their new
+// positions.
+func orderShelves(shelves []Shelf, shelfIDs []uuid.UUID) ([]Shelf, error) {
+	if len(shelfIDs) != len(shelves) {
+		return nil, ErrShelfOrder
+	}
+
+	byID := make(map[uuid.UUID]Shelf, len(shelves))
+	for _, shelf := range shelves {
+		byID[shelf.ID] = shelf
+	}
+
+	ordered := make([]Shelf, 0, len(shelves))
+	for i, shelfID := range shelfIDs {
+		shelf, exists := byID[shelfID]
+		if !exists {
+			return nil, ErrShelfOrder
+		}
+		delete(byID, shelfID)
+
+		shelf.Position = i
+		ordered = append(ordered, shelf)
+	}
+	return ordered, nil
+}
+
+func (s *ShelfData) GetShelfMoviesByID(shelfID, userID uuid.UUID, streamable bool) []Movie {
 	var movies []Movie
 	s.DB.Model(&movies).Where("shelf_id = ?", &shelfID).Select()
-	if len(movies) > 0 {
-		return movies
+	if len(movies) == 0 {
+		return make([]Movie, 0)
 	}
-	return make([]Movie, 0)
+
+	room, err := shelfRoom(s.DB, shelfID)
+	if err != nil {
+		fmt.Println("Failed to get shelf room: ", err)
+	}
+
+	locale := userLocale(s.DB, s.Env, userID, room.ID)
+	s.Metadata.attachMetadata(moviePointers(movies), locale.Language)
+	attachAvailability(moviePointers(movies), s.Env, room)
+
+	if streamable {
+		return streamableMovies(movies)
+	}
+	return movies
 }
 
 func (s *ShelfData) GetShelfInfoByID(shelfID uuid.UUID) Shelf {
@@ -66,10 +254,15 @@ func (s *ShelfData) GetShelfInfoByID(shelfID uuid.UUID) Shelf {
 	return shelf
 }
 
-func (s *ShelfData) GetAvailableMovies(shelfID uuid.UUID, searchTerm string, excludeExisting bool) ([]themoviedb.Movie, error) {
-	movieDB := themoviedb.NewMovieDBOptions(s.Env.MovieDBAuthToken, "")
+func (s *ShelfData) GetAvailableMovies(ctx context.Context, shelfID, userID uuid.UUID, search MovieSearch, excludeExisting bool) (*themoviedb.SearchMovieResp, error) {
+	room, err := shelfRoom(s.DB, shelfID)
+	if err != nil {
+		fmt.Println("Failed to get shelf room: ", err)
+	}
 
-	movies, err := movieDB.SearchMovies(searchTerm)
+	search.withLocale(userLocale(s.DB, s.Env, userID, room.ID))
+
+	resp, err := searchMedia(ctx, s.Provider, search)
 	if err != nil {
 		return nil, err
 	}
@@ -80,41 +273,140 @@ func (s *ShelfData) GetAvailableMovies(shelfID uuid.UUID, searchTerm string, exc
 			return nil, err
 		}
 
-		existingMovieMap := make(map[uint]struct{})
-		for _, movie := range existingMovies {
-			existingMovieMap[movie.MovieID] = struct{}{}
-		}
+		excludeExistingMovies(resp, existingMovies)
+	}
 
-		var availableMovies []themoviedb.Movie
-		for _, movie := range movies {
-			if _, exists := existingMovieMap[movie.ID]; !exists {
-				availableMovies = append(availableMovies, movie)
-			}
-		}
+	return resp, nil
+}
 
-		return availableMovies, nil
+func shelfRoom(db orm.DB, shelfID uuid.UUID) (Room, error) {
+	var room Room
+	err := db.Model(&room).
+		Join(`JOIN shelves s ON "s".room_id = "room".id`).
+		Where(`"s".id = ?`, shelfID).
+		Select()
+	return room, err
+}
+
+// GetShelfRole returns the role of userID in the room the shelf is in.
+func (s *ShelfData) GetShelfRole(shelfID, userID uuid.UUID) (Role, error) {
+	var shelf Shelf
+	err := s.DB.Model(&shelf).Column("room_id").Where("id = ?", shelfID).Select()
+	if err == pg.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
 	}
 
-	return movies, nil
+	return roomRole(s.DB, shelf.RoomID, userID)
 }
 
-func (s *ShelfData) GetShelfAccess(shelfID, userID uuid.UUID) (bool, error) {
-	var room Room
+// MediaAll searches movies and TV series together.
+const MediaAll = metadata.MediaAll
 
-	query := s.DB.Model(&room).
-		Join(`JOIN room_users ru ON "room".id = "ru".room_id`).
-		Join(`JOIN shelves s ON "s".room_id = "ru".room_id`).
-		Where(`"s".id = ? AND "ru".user_id = ?`, &shelfID, &userID).
-		Select()
+// MovieSearch selects what GetAvailableMovies returns. SearchTerm is
+// searched in MediaType, movies when it is empty, unless Discover is set.
+// Discover browses TMDB's discover/movie with the filters instead, Year
+// then stands for YearFrom and YearTo. SortBy and MinVoteCount only apply
+// to Discover.
+type MovieSearch struct {
+	SearchTerm   string
+	MediaType    string
+	Discover     bool
+	Page         uint
+	Year         uint
+	YearFrom     uint
+	YearTo       uint
+	Language     string
+	Region       string
+	IncludeAdult bool
+	Genres       []uint
+	SortBy       string
+	MinVoteCount uint
+}
+
+// withLocale fills the language and region the search didn't set.
+func (s *MovieSearch) withLocale(locale themoviedb.Locale) {
+	s.Language = firstNonEmpty(s.Language, locale.Language)
+	s.Region = firstNonEmpty(s.Region, locale.Region)
+}
+
+// searchMedia searches or discovers one page of movies, TV series or both.
+func searchMedia(ctx context.Context, provider metadata.Provider, search MovieSearch) (*themoviedb.SearchMovieResp, error) {
+	if search.Discover {
+		if search.MediaType != "" && search.MediaType != themoviedb.MediaMovie {
+			return nil, ErrDiscoverMediaType
+		}
 
-	if query == pg.ErrNoRows {
-		return false, nil
+		discoverer, ok := provider.(metadata.Discoverer)
+		if !ok {
+			return nil, fmt.Errorf("%w: %v", ErrDiscoverUnsupported, provider.Name())
+		}
+
+		yearFrom, yearTo := search.YearFrom, search.YearTo
+		if search.Year > 0 && yearFrom == 0 && yearTo == 0 {
+			yearFrom, yearTo = search.Year, search.Year
+		}
+
+		return discoverer.Discover(ctx, themoviedb.DiscoverOptions{
+			Page:         search.Page,
+			Language:     search.Language,
+			Region:       search.Region,
+			IncludeAdult: search.IncludeAdult,
+			Genres:       search.Genres,
+			YearFrom:     yearFrom,
+			YearTo:       yearTo,
+			SortBy:       search.SortBy,
+			MinVoteCount: search.MinVoteCount,
+		})
 	}
 
-	if query != nil {
-		return false, nil
+	switch search.MediaType {
+	case "", themoviedb.MediaMovie, themoviedb.MediaTV, MediaAll:
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownMediaType, search.MediaType)
 	}
 
-	return true, nil
+	return provider.Search(ctx, metadata.Query{
+		MediaType: search.MediaType,
+		SearchOptions: themoviedb.SearchOptions{
+			Query:        search.SearchTerm,
+			Page:         search.Page,
+			Year:         search.Year,
+			Language:     search.Language,
+			Region:       search.Region,
+			IncludeAdult: search.IncludeAdult,
+			Genres:       search.Genres,
+		},
+	})
+}
+
+// excludeExistingMovies drops the movies and series already on the shelf
+// from one page of results. Single seasons don't hide their series. The
+// page keeps the provider's numbering and total_pages, so it can come back
+// short; total_results leaves out what was dropped from this page.
+func excludeExistingMovies(resp *themoviedb.SearchMovieResp, existingMovies []Movie) {
+	existingMovieMap := make(map[MediaKey]struct{})
+	for _, movie := range existingMovies {
+		if movie.SeasonNumber != nil {
+			continue
+		}
+		existingMovieMap[movie.mediaKey("")] = struct{}{}
+	}
+
+	availableMovies := make([]themoviedb.Movie, 0, len(resp.Movies))
+	for _, movie := range resp.Movies {
+		if _, exists := existingMovieMap[MediaKey{MediaType: movie.MediaType, ID: movie.ID}]; !exists {
+			availableMovies = append(availableMovies, movie)
+		}
+	}
+
+	excluded := uint(len(resp.Movies) - len(availableMovies))
+	if excluded > resp.TotalResults {
+		excluded = resp.TotalResults
+	}
 
+	resp.TotalResults -= excluded
+	resp.Movies = availableMovies
 }
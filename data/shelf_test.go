@@ -0,0 +1,47 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/adamelfsborg-code/movie-nest/pkg/themoviedb"
+)
+
+func TestExcludeExistingMovies(t *testing.T) {
+	season := uint(2)
+	existing := []Movie{
+		{MediaType: themoviedb.MediaMovie, MovieID: 603},
+		{MediaType: themoviedb.MediaTV, MovieID: 1399, SeasonNumber: &season},
+		{MediaType: themoviedb.MediaTV, MovieID: 1396},
+	}
+
+	resp := &themoviedb.SearchMovieResp{
+		Page:         1,
+		TotalPages:   3,
+		TotalResults: 45,
+		Movies: []themoviedb.Movie{
+			{MediaType: themoviedb.MediaMovie, ID: 603},
+			{MediaType: themoviedb.MediaTV, ID: 603},
+			{MediaType: themoviedb.MediaTV, ID: 1399},
+			{MediaType: themoviedb.MediaTV, ID: 1396},
+		},
+	}
+
+	excludeExistingMovies(resp, existing)
+
+	var got []MediaKey
+	for _, movie := range resp.Movies {
+		got = append(got, MediaKey{MediaType: movie.MediaType, ID: movie.ID})
+	}
+
+	want := []MediaKey{
+		{MediaType: themoviedb.MediaTV, ID: 603},
+		{MediaType: themoviedb.MediaTV, ID: 1399},
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Results = %v, want %v", got, want)
+	}
+
+	if resp.Page != 1 || resp.TotalPages != 3 || resp.TotalResults != 43 {
+		t.Errorf("Page %v of %v with %v results, want page 1 of 3 with 43 results", resp.Page, resp.TotalPages, resp.TotalResults)
+	}
+}
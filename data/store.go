@@ -0,0 +1,122 @@
+package data
+
+import (
+	"context"
+
+	"github.com/adamelfsborg-code/movie-nest/config"
+	"github.com/adamelfsborg-code/movie-nest/events"
+	"github.com/adamelfsborg-code/movie-nest/pkg/themoviedb"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+)
+
+type RoomStore interface {
+	CreateRoom(room Room, userID uuid.UUID) error
+	ListRooms() []Room
+	GetRoomByID(roomID uuid.UUID) (*Room, error)
+	GetRoomInfoByID(roomID, userID uuid.UUID) (*RoomInfo, error)
+	SetRoomUserRole(roomID, userID uuid.UUID, role Role, actorID uuid.UUID) error
+	UpdateRoom(roomID uuid.UUID, update RoomUpdate, actorID uuid.UUID) (*Room, error)
+	SetRoomArchived(roomID uuid.UUID, archived bool, actorID uuid.UUID) (*Room, error)
+	LeaveRoom(roomID, userID uuid.UUID) error
+	RemoveRoomUser(roomID, userID, actorID uuid.UUID) error
+	TransferRoomOwnership(roomID, userID, actorID uuid.UUID) error
+	DeleteRoom(roomID, actorID uuid.UUID) error
+	ListRoomsWithUsers() []RoomWithUser
+	GetRoomWithUsersByID(roomID uuid.UUID) RoomWithUser
+	GetUserRoomsByID(userID uuid.UUID) []Room
+	GetAvailableUsers(roomID uuid.UUID, userID uuid.UUID, searchTerm string, excludeSelf bool, excludeExisting bool) []User
+	GetRoomRole(roomID, userID uuid.UUID) (Role, error)
+}
+
+type InviteStore interface {
+	CreateInvite(invite RoomInvite, actorID uuid.UUID) (*RoomInvite, error)
+	GetInvitesByRoomID(roomID uuid.UUID) []RoomInvite
+	RevokeInvite(roomID, inviteID, actorID uuid.UUID) error
+	RedeemInvite(code string, userID uuid.UUID) (*Room, error)
+}
+
+type InvitationStore interface {
+	InviteUser(invitation RoomInvitation, actorID uuid.UUID) (*RoomInvitation, error)
+	GetInvitationsByRoomID(roomID uuid.UUID) []RoomInvitationInfo
+	GetInvitationsByUserID(userID uuid.UUID) []RoomInvitationInfo
+	AcceptInvitation(invitationID, userID uuid.UUID) (*Room, error)
+	DeclineInvitation(invitationID, userID uuid.UUID, block bool) error
+	CancelInvitation(roomID, invitationID, actorID uuid.UUID) error
+	GetBlockedUsers(userID uuid.UUID) []User
+	UnblockUser(userID, blockedUserID uuid.UUID) error
+}
+
+type ShelfStore interface {
+	CreateShelf(shelf Shelf, actorID uuid.UUID) error
+	GetShelvesByRoomID(roomID uuid.UUID) []Shelf
+	GetShelfMoviesByID(shelfID, userID uuid.UUID, streamable bool) []Movie
+	GetShelfInfoByID(shelfID uuid.UUID) Shelf
+	GetAvailableMovies(ctx context.Context, shelfID, userID uuid.UUID, search MovieSearch, excludeExisting bool) (*themoviedb.SearchMovieResp, error)
+	GetShelfRole(shelfID, userID uuid.UUID) (Role, error)
+	RenameShelf(shelfID uuid.UUID, name string, actorID uuid.UUID) (*Shelf, error)
+	ReorderShelves(roomID uuid.UUID, shelfIDs []uuid.UUID, actorID uuid.UUID) ([]Shelf, error)
+	DeleteShelf(shelfID, actorID uuid.UUID) error
+}
+
+type MovieStore interface {
+	CreateMovie(movie Movie, actorID uuid.UUID) error
+	GetMovie(ctx context.Context, movieID uint, userID uuid.UUID) (*themoviedb.Movie, error)
+	GetTV(ctx context.Context, tvID uint, userID uuid.UUID) (*themoviedb.Movie, error)
+	FindByIMDbID(ctx context.Context, imdbID string, userID uuid.UUID) (*themoviedb.Movie, error)
+	GetMovieDetails(ctx context.Context, movieID, userID uuid.UUID) (*MovieDetails, error)
+	RateMovie(rating MovieRating) error
+	GetMovieRole(movieID, userID uuid.UUID) (Role, error)
+	RemoveMovie(movieID, actorID uuid.UUID) error
+	MoveMovie(movieID, shelfID, actorID uuid.UUID) (*Movie, error)
+	CopyMovie(movieID, shelfID, actorID uuid.UUID) (*Movie, error)
+}
+
+type UserStore interface {
+	Register(user User) error
+	List() []User
+	Login(name, password string) (string, error)
+	GetUserInfoByID(userID uuid.UUID) User
+	SetUserLocale(userID uuid.UUID, locale themoviedb.Locale) error
+	CheckUserExistsByID(userID uuid.UUID) bool
+	GetUsersInRoom(roomID uuid.UUID, userID uuid.UUID, excludeSelf bool) []User
+}
+
+// Stores groups one implementation of every store so the router can be
+// built against either Postgres or the in-memory backend. Metadata is
+// shared by the stores and its refresh job has to be run by the caller.
+type Stores struct {
+	Rooms       RoomStore
+	Invites     InviteStore
+	Invitations InvitationStore
+	Shelves     ShelfStore
+	Movies      MovieStore
+	Users       UserStore
+	Metadata    *MetadataCache
+}
+
+var (
+	_ RoomStore       = (*RoomData)(nil)
+	_ InviteStore     = (*InviteData)(nil)
+	_ InvitationStore = (*InvitationData)(nil)
+	_ ShelfStore      = (*ShelfData)(nil)
+	_ MovieStore      = (*MovieData)(nil)
+	_ UserStore       = (*UserData)(nil)
+
+	_ MetadataStore = (*MetadataData)(nil)
+)
+
+func NewStores(env config.Environments, db *pg.DB, bus events.Bus) Stores {
+	provider := newProvider(env)
+	metadata := NewMetadataCache(env, provider, &MetadataData{DB: db})
+
+	return Stores{
+		Rooms:       &RoomData{Env: env, DB: db, Bus: bus, Metadata: metadata},
+		Invites:     &InviteData{Env: env, DB: db},
+		Invitations: &InvitationData{DB: db},
+		Shelves:     &ShelfData{Env: env, DB: db, Provider: provider, Metadata: metadata},
+		Movies:      &MovieData{Env: env, DB: db, Provider: provider, Metadata: metadata},
+		Users:       &UserData{Env: env, DB: db},
+		Metadata:    metadata,
+	}
+}
@@ -5,25 +5,26 @@ import (
 	"time"
 
 	"github.com/adamelfsborg-code/movie-nest/config"
+	"github.com/adamelfsborg-code/movie-nest/pkg/themoviedb"
 	"github.com/adamelfsborg-code/movie-nest/shared"
 	"github.com/go-pg/pg/v10"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
-	"github.com/nats-io/nats.go"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type UserData struct {
-	Env  config.Environments
-	DB   pg.DB
-	Nats *nats.Conn
+	Env config.Environments
+	DB  *pg.DB
 }
 
 type User struct {
 	ID        uuid.UUID `json:"id" db:"id"`
 	Name      string    `json:"name" db:"name" validate:"max=20,min=3"`
 	Password  string    `json:"password" db:"password" validate:"max=50,min=10"`
+	Language  string    `json:"language,omitempty" db:"language"`
+	Region    string    `json:"region,omitempty" db:"region"`
 	Timestamp time.Time `json:"timestamp" db:"timestamp"`
 }
 
@@ -75,17 +76,7 @@ func (u *UserData) Login(name, password string) (string, error) {
 		return "", fmt.Errorf("User does not exists")
 	}
 
-	claims := jwt.MapClaims{
-		"sub": user.ID,
-		"exp": time.Now().Add(time.Hour * 24).Unix(),
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(u.Env.SecretKey)
-	if err != nil {
-		return "", err
-	}
-	return tokenString, nil
+	return signUserToken(u.Env, user)
 }
 
 func (u *UserData) GetUserInfoByID(userID uuid.UUID) User {
@@ -94,6 +85,22 @@ func (u *UserData) GetUserInfoByID(userID uuid.UUID) User {
 	return user
 }
 
+func (u *UserData) SetUserLocale(userID uuid.UUID, locale themoviedb.Locale) error {
+	result, err := u.DB.Model((*User)(nil)).
+		Set("language = NULLIF(?, '')", locale.Language).
+		Set("region = NULLIF(?, '')", locale.Region).
+		Where("id = ?", userID).
+		Update()
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return pg.ErrNoRows
+	}
+	return nil
+}
+
 func (u *UserData) CheckUserExistsByID(userID uuid.UUID) bool {
 	var user []User
 	u.DB.Model(&user).Where("id = ?", userID).Select()
@@ -123,3 +130,17 @@ func (u *UserData) getUserByName(name string) User {
 	u.DB.Model(&user).Where("name = ?", name).Select()
 	return user
 }
+
+func signUserToken(env config.Environments, user User) (string, error) {
+	claims := jwt.MapClaims{
+		"sub": user.ID,
+		"exp": time.Now().Add(time.Hour * 24).Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString(env.SecretKey)
+	if err != nil {
+		return "", err
+	}
+	return tokenString, nil
+}
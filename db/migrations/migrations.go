@@ -0,0 +1,335 @@
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+var ErrSchemaBehind = errors.New("database schema is behind")
+
+// lockID is the advisory lock held while migrating, so migrate commands
+// started together apply every migration once.
+const lockID int64 = 7_302_416_113
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+type schemaMigration struct {
+	tableName struct{} `pg:"schema_migrations"`
+
+	Version   int64     `pg:"version,pk"`
+	Name      string    `pg:"name"`
+	AppliedAt time.Time `pg:"applied_at"`
+}
+
+type Migrator struct {
+	DB         *pg.DB
+	Migrations []Migration
+}
+
+func New(db *pg.DB) (*Migrator, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		DB:         db,
+		Migrations: migrations,
+	}, nil
+}
+
+// Load reads the embedded sql files. Every migration is a pair of files
+// named <version>_<name>.up.sql and <version>_<name>.down.sql.
+func Load() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+
+	for _, entry := range entries {
+		fileName := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("Invalid migration file name: %v", fileName)
+		}
+
+		base := strings.TrimSuffix(fileName, fmt.Sprintf(".%v.sql", direction))
+		versionPart, name, found := strings.Cut(base, "_")
+		if !found {
+			return nil, fmt.Errorf("Invalid migration file name: %v", fileName)
+		}
+
+		version, err := strconv.ParseInt(versionPart, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid migration version in %v: %w", fileName, err)
+		}
+
+		content, err := files.ReadFile(path.Join("sql", fileName))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, exists := byVersion[version]
+		if !exists {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		}
+
+		if migration.Name != name {
+			return nil, fmt.Errorf("Migration %v has mismatching names: %v and %v", version, migration.Name, name)
+		}
+
+		if direction == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("Migration %v_%v is missing its up or down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Up applies every pending migration in version order. Each migration
+// runs in its own transaction together with its schema_migrations row.
+// Concurrent calls wait for each other.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	err = m.createTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range m.Migrations {
+		if _, exists := applied[migration.Version]; exists {
+			continue
+		}
+
+		err := m.DB.RunInTransaction(ctx, func(tx *pg.Tx) error {
+			_, err := tx.ExecContext(ctx, migration.Up)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ModelContext(ctx, &schemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now(),
+			}).Insert()
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("Failed to apply migration %v_%v: %w", migration.Version, migration.Name, err)
+		}
+
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// Down reverts the given number of most recently applied migrations.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	err = m.createTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(m.Migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := m.Migrations[i]
+		if _, exists := applied[migration.Version]; !exists {
+			continue
+		}
+
+		err := m.DB.RunInTransaction(ctx, func(tx *pg.Tx) error {
+			_, err := tx.ExecContext(ctx, migration.Down)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ModelContext(ctx, &schemaMigration{Version: migration.Version}).WherePK().Delete()
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("Failed to revert migration %v_%v: %w", migration.Version, migration.Name, err)
+		}
+
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.Migrations))
+	for _, migration := range m.Migrations {
+		status := Status{
+			Version: migration.Version,
+			Name:    migration.Name,
+		}
+
+		if row, exists := applied[migration.Version]; exists {
+			appliedAt := row.AppliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// Check returns ErrSchemaBehind when one or more migrations are pending.
+func (m *Migrator) Check(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	var pending []string
+	for _, status := range statuses {
+		if !status.Applied {
+			pending = append(pending, fmt.Sprintf("%v_%v", status.Version, status.Name))
+		}
+	}
+
+	if len(pending) > 0 {
+		return fmt.Errorf("%w, pending migrations: %v", ErrSchemaBehind, strings.Join(pending, ", "))
+	}
+
+	return nil
+}
+
+// lock takes the migration lock on a connection of its own, a session
+// lock is released with the connection that holds it.
+func (m *Migrator) lock(ctx context.Context) (func(), error) {
+	conn := m.DB.Conn()
+
+	_, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock(?)`, lockID)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("Failed to lock migrations: %w", err)
+	}
+
+	return func() {
+		_, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock(?)`, lockID)
+		if err != nil {
+			fmt.Println("Failed to unlock migrations: ", err)
+		}
+		conn.Close()
+	}, nil
+}
+
+func (m *Migrator) createTable(ctx context.Context) error {
+	_, err := m.DB.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version bigint PRIMARY KEY,
+			"name" text NOT NULL,
+			applied_at timestamptz NOT NULL DEFAULT now()
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("Failed to create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// applied reads schema_migrations without changing the schema, a missing
+// table means nothing has been applied yet.
+func (m *Migrator) applied(ctx context.Context) (map[int64]schemaMigration, error) {
+	var exists bool
+	_, err := m.DB.QueryOneContext(ctx, pg.Scan(&exists), `SELECT to_regclass('schema_migrations') IS NOT NULL`)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to look up schema_migrations: %w", err)
+	}
+
+	applied := make(map[int64]schemaMigration)
+	if !exists {
+		return applied, nil
+	}
+
+	var rows []schemaMigration
+	err = m.DB.ModelContext(ctx, &rows).Order("version ASC").Select()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read schema_migrations: %w", err)
+	}
+
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+
+	return applied, nil
+}
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS movie_ratings;
+DROP TABLE IF EXISTS movies;
+DROP TABLE IF EXISTS shelves;
+DROP TABLE IF EXISTS room_users;
+DROP TABLE IF EXISTS rooms;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,53 @@
+-- Databases created by hand before migrations existed already have these
+-- tables, they adopt migrations by recording this one as applied.
+
+CREATE TABLE IF NOT EXISTS users (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	"name" text NOT NULL,
+	"password" text NOT NULL,
+	"timestamp" timestamptz NOT NULL DEFAULT now(),
+	CONSTRAINT users_name_key UNIQUE ("name")
+);
+
+CREATE TABLE IF NOT EXISTS rooms (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	"name" text NOT NULL,
+	"timestamp" timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS room_users (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	room_id uuid NOT NULL REFERENCES rooms (id) ON DELETE CASCADE,
+	user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	"timestamp" timestamptz NOT NULL DEFAULT now(),
+	CONSTRAINT room_users_room_id_user_id_key UNIQUE (room_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS room_users_user_id_idx ON room_users (user_id);
+
+CREATE TABLE IF NOT EXISTS shelves (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	"name" text NOT NULL,
+	room_id uuid NOT NULL REFERENCES rooms (id) ON DELETE CASCADE,
+	"timestamp" timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS shelves_room_id_idx ON shelves (room_id);
+
+CREATE TABLE IF NOT EXISTS movies (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	movie_id bigint NOT NULL,
+	shelf_id uuid NOT NULL REFERENCES shelves (id) ON DELETE CASCADE,
+	"timestamp" timestamptz NOT NULL DEFAULT now(),
+	CONSTRAINT movies_shelf_id_movie_id_key UNIQUE (shelf_id, movie_id)
+);
+
+CREATE TABLE IF NOT EXISTS movie_ratings (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	"timestamp" timestamptz NOT NULL DEFAULT now(),
+	movie_id uuid NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+	user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	rating double precision NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS movie_ratings_movie_id_idx ON movie_ratings (movie_id);
@@ -0,0 +1,12 @@
+CREATE TABLE outbox_events (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	subject text NOT NULL,
+	payload bytea NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT now(),
+	attempts integer NOT NULL DEFAULT 0,
+	next_attempt_at timestamptz NOT NULL DEFAULT now(),
+	last_error text,
+	published_at timestamptz
+);
+
+CREATE INDEX outbox_events_pending_idx ON outbox_events (next_attempt_at) WHERE published_at IS NULL;
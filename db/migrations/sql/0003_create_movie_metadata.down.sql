@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS movies_movie_id_idx;
+
+DROP TABLE IF EXISTS movie_metadata;
@@ -0,0 +1,11 @@
+CREATE TABLE movie_metadata (
+	movie_id bigint PRIMARY KEY,
+	data jsonb NOT NULL,
+	fetched_at timestamptz NOT NULL DEFAULT now(),
+	last_error text,
+	last_error_at timestamptz
+);
+
+CREATE INDEX movie_metadata_fetched_at_idx ON movie_metadata (fetched_at);
+
+CREATE INDEX movies_movie_id_idx ON movies (movie_id);
@@ -0,0 +1,2 @@
+-- Nothing to revert, the refreshed metadata is still valid.
+SELECT 1;
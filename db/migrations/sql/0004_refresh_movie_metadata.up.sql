@@ -0,0 +1,3 @@
+-- Cached metadata predates genres, runtime, overview and credits, mark it
+-- stale so the background refresh fetches it again.
+UPDATE movie_metadata SET fetched_at = to_timestamp(0);
@@ -0,0 +1,21 @@
+DELETE FROM movie_metadata WHERE media_type <> 'movie';
+
+ALTER TABLE movie_metadata
+	DROP CONSTRAINT movie_metadata_pkey,
+	DROP COLUMN media_type,
+	ADD PRIMARY KEY (movie_id);
+
+DROP INDEX movies_media_type_movie_id_idx;
+
+CREATE INDEX movies_movie_id_idx ON movies (movie_id);
+
+DROP INDEX movies_shelf_id_media_key;
+
+DELETE FROM movies WHERE media_type <> 'movie';
+
+ALTER TABLE movies
+	DROP CONSTRAINT movies_season_number_check,
+	DROP CONSTRAINT movies_media_type_check,
+	DROP COLUMN season_number,
+	DROP COLUMN media_type,
+	ADD CONSTRAINT movies_shelf_id_movie_id_key UNIQUE (shelf_id, movie_id);
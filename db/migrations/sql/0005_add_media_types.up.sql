@@ -0,0 +1,17 @@
+ALTER TABLE movies
+	ADD COLUMN media_type text NOT NULL DEFAULT 'movie',
+	ADD COLUMN season_number integer,
+	ADD CONSTRAINT movies_media_type_check CHECK (media_type IN ('movie', 'tv')),
+	ADD CONSTRAINT movies_season_number_check CHECK (season_number IS NULL OR (media_type = 'tv' AND season_number >= 0)),
+	DROP CONSTRAINT movies_shelf_id_movie_id_key;
+
+CREATE UNIQUE INDEX movies_shelf_id_media_key ON movies (shelf_id, media_type, movie_id, COALESCE(season_number, -1));
+
+DROP INDEX movies_movie_id_idx;
+
+CREATE INDEX movies_media_type_movie_id_idx ON movies (media_type, movie_id);
+
+ALTER TABLE movie_metadata
+	ADD COLUMN media_type text NOT NULL DEFAULT 'movie',
+	DROP CONSTRAINT movie_metadata_pkey,
+	ADD PRIMARY KEY (media_type, movie_id);
@@ -0,0 +1,14 @@
+DELETE FROM movie_metadata WHERE language <> 'en-US';
+
+ALTER TABLE movie_metadata
+	DROP CONSTRAINT movie_metadata_pkey,
+	DROP COLUMN language,
+	ADD PRIMARY KEY (media_type, movie_id);
+
+ALTER TABLE rooms
+	DROP COLUMN region,
+	DROP COLUMN language;
+
+ALTER TABLE users
+	DROP COLUMN region,
+	DROP COLUMN language;
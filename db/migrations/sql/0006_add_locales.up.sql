@@ -0,0 +1,12 @@
+ALTER TABLE users
+	ADD COLUMN language text,
+	ADD COLUMN region text;
+
+ALTER TABLE rooms
+	ADD COLUMN language text,
+	ADD COLUMN region text;
+
+ALTER TABLE movie_metadata
+	ADD COLUMN language text NOT NULL DEFAULT 'en-US',
+	DROP CONSTRAINT movie_metadata_pkey,
+	ADD PRIMARY KEY (media_type, movie_id, language);
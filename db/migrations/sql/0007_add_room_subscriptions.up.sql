@@ -0,0 +1,5 @@
+ALTER TABLE rooms ADD COLUMN subscriptions integer[];
+
+-- Cached metadata predates watch providers, mark it stale so the
+-- background refresh fetches it again.
+UPDATE movie_metadata SET fetched_at = to_timestamp(0);
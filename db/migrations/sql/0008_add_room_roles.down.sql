@@ -0,0 +1,5 @@
+DROP INDEX room_users_owner_idx;
+
+ALTER TABLE room_users
+	DROP CONSTRAINT room_users_role_check,
+	DROP COLUMN "role";
@@ -0,0 +1,14 @@
+ALTER TABLE room_users
+	ADD COLUMN "role" text NOT NULL DEFAULT 'member',
+	ADD CONSTRAINT room_users_role_check CHECK ("role" IN ('owner', 'admin', 'member', 'viewer'));
+
+-- The creator was added first, make the earliest member of every room its
+-- owner.
+UPDATE room_users SET "role" = 'owner'
+WHERE id IN (
+	SELECT DISTINCT ON (room_id) id
+	FROM room_users
+	ORDER BY room_id, "timestamp", id
+);
+
+CREATE UNIQUE INDEX room_users_owner_idx ON room_users (room_id) WHERE "role" = 'owner';
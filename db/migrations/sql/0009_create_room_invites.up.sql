@@ -0,0 +1,17 @@
+CREATE TABLE room_invites (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	room_id uuid NOT NULL REFERENCES rooms (id) ON DELETE CASCADE,
+	code text NOT NULL,
+	"role" text NOT NULL DEFAULT 'member',
+	created_by uuid REFERENCES users (id) ON DELETE SET NULL,
+	expires_at timestamptz,
+	max_uses integer,
+	uses integer NOT NULL DEFAULT 0,
+	revoked_at timestamptz,
+	"timestamp" timestamptz NOT NULL DEFAULT now(),
+	CONSTRAINT room_invites_code_key UNIQUE (code),
+	CONSTRAINT room_invites_role_check CHECK ("role" IN ('admin', 'member', 'viewer')),
+	CONSTRAINT room_invites_max_uses_check CHECK (max_uses > 0)
+);
+
+CREATE INDEX room_invites_room_id_idx ON room_invites (room_id);
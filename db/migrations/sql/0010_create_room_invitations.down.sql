@@ -0,0 +1,2 @@
+DROP TABLE user_blocks;
+DROP TABLE room_invitations;
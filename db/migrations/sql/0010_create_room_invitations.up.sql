@@ -0,0 +1,22 @@
+CREATE TABLE room_invitations (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	room_id uuid NOT NULL REFERENCES rooms (id) ON DELETE CASCADE,
+	user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	invited_by uuid REFERENCES users (id) ON DELETE SET NULL,
+	"role" text NOT NULL DEFAULT 'member',
+	status text NOT NULL DEFAULT 'pending',
+	responded_at timestamptz,
+	"timestamp" timestamptz NOT NULL DEFAULT now(),
+	CONSTRAINT room_invitations_role_check CHECK ("role" IN ('admin', 'member', 'viewer')),
+	CONSTRAINT room_invitations_status_check CHECK (status IN ('pending', 'accepted', 'declined', 'cancelled'))
+);
+
+CREATE UNIQUE INDEX room_invitations_pending_idx ON room_invitations (room_id, user_id) WHERE status = 'pending';
+CREATE INDEX room_invitations_user_id_idx ON room_invitations (user_id);
+
+CREATE TABLE user_blocks (
+	user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	blocked_user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	"timestamp" timestamptz NOT NULL DEFAULT now(),
+	PRIMARY KEY (user_id, blocked_user_id)
+);
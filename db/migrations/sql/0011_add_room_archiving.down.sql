@@ -0,0 +1 @@
+ALTER TABLE rooms DROP COLUMN archived_at;
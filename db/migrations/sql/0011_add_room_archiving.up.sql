@@ -0,0 +1 @@
+ALTER TABLE rooms ADD COLUMN archived_at timestamptz;
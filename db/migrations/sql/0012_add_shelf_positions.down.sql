@@ -0,0 +1,5 @@
+DROP INDEX shelves_room_id_position_idx;
+
+CREATE INDEX shelves_room_id_idx ON shelves (room_id);
+
+ALTER TABLE shelves DROP COLUMN position;
@@ -0,0 +1,13 @@
+ALTER TABLE shelves ADD COLUMN position integer NOT NULL DEFAULT 0;
+
+-- Keep the order shelves were listed in so far, oldest first.
+UPDATE shelves s SET position = o.position
+FROM (
+	SELECT id, ROW_NUMBER() OVER (PARTITION BY room_id ORDER BY "timestamp", id) - 1 AS position
+	FROM shelves
+) o
+WHERE s.id = o.id;
+
+DROP INDEX shelves_room_id_idx;
+
+CREATE INDEX shelves_room_id_position_idx ON shelves (room_id, position);
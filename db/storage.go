@@ -4,11 +4,21 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/adamelfsborg-code/movie-nest/config"
 	"github.com/go-pg/pg/v10"
 )
 
 type QueryLogger struct{}
 
+func Connect(env config.Environments) *pg.DB {
+	return pg.Connect(&pg.Options{
+		Addr:     env.DatabaseAddr,
+		Database: env.DatabaseName,
+		User:     env.DatabaseUser,
+		Password: env.DatabasePassword,
+	})
+}
+
 func (*QueryLogger) BeforeQuery(ctx context.Context, q *pg.QueryEvent) (context.Context, error) {
 	formattedQuery, err := q.FormattedQuery()
 	if err != nil {
@@ -0,0 +1,51 @@
+package events
+
+import (
+	"strings"
+	"time"
+)
+
+// Event is a message on a subject. Subjects follow the NATS conventions,
+// tokens are separated by dots and subscriptions may use the * and >
+// wildcards. ID is optional, when set it identifies the event for
+// deduplication.
+type Event struct {
+	ID      string `json:"id,omitempty"`
+	Subject string `json:"subject"`
+	Data    []byte `json:"data"`
+}
+
+type Handler func(event Event)
+
+type Subscription interface {
+	Unsubscribe() error
+}
+
+type Bus interface {
+	Publish(event Event) error
+	Subscribe(subject string, handler Handler) (Subscription, error)
+	Flush(timeout time.Duration) error
+	Close() error
+}
+
+// MatchSubject reports whether subject matches the subscription pattern.
+func MatchSubject(pattern, subject string) bool {
+	patternTokens := strings.Split(pattern, ".")
+	subjectTokens := strings.Split(subject, ".")
+
+	for i, token := range patternTokens {
+		if token == ">" {
+			return len(subjectTokens) > i
+		}
+
+		if i >= len(subjectTokens) {
+			return false
+		}
+
+		if token != "*" && token != subjectTokens[i] {
+			return false
+		}
+	}
+
+	return len(patternTokens) == len(subjectTokens)
+}
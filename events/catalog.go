@@ -0,0 +1,510 @@
+package events
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TypeRoomCreated              = "room.created"
+	TypeRoomUpdated              = "room.updated"
+	TypeRoomArchived             = "room.archived"
+	TypeRoomUnarchived           = "room.unarchived"
+	TypeRoomOwnershipTransferred = "room.ownership.transferred"
+	TypeRoomDeleted              = "room.deleted"
+	TypeRoomMemberAdded          = "room.member.added"
+	TypeRoomMemberRoleChanged    = "room.member.role_changed"
+	TypeRoomMemberJoined         = "room.member.joined"
+	TypeRoomMemberLeft           = "room.member.left"
+	TypeRoomMemberRemoved        = "room.member.removed"
+	TypeRoomInvitationCreated    = "room.invitation.created"
+	TypeRoomInvitationUpdated    = "room.invitation.updated"
+	TypeRoomShelvesReordered     = "room.shelves.reordered"
+	TypeShelfCreated             = "shelf.created"
+	TypeShelfRenamed             = "shelf.renamed"
+	TypeShelfDeleted             = "shelf.deleted"
+	TypeShelfMovieAdded          = "shelf.movie.added"
+	TypeShelfMovieRated          = "shelf.movie.rated"
+	TypeShelfMovieRemoved        = "shelf.movie.removed"
+	TypeShelfMovieMoved          = "shelf.movie.moved"
+)
+
+// CatalogEntry documents one event type. Subject uses {placeholders} for
+// the ids that are filled in when the event is published.
+type CatalogEntry struct {
+	Type          string `json:"type"`
+	SchemaVersion int    `json:"schema_version"`
+	Subject       string `json:"subject"`
+	Description   string `json:"description"`
+	new           func() Payload
+}
+
+var Catalog = []CatalogEntry{
+	{
+		Type:          TypeRoomCreated,
+		SchemaVersion: 1,
+		Subject:       "rooms.{room_id}.created",
+		Description:   "A room was created, the actor is its creator.",
+		new:           func() Payload { return &RoomCreated{} },
+	},
+	{
+		Type:          TypeRoomUpdated,
+		SchemaVersion: 1,
+		Subject:       "rooms.{room_id}.updated",
+		Description:   "A room was renamed or its settings changed.",
+		new:           func() Payload { return &RoomUpdated{} },
+	},
+	{
+		Type:          TypeRoomArchived,
+		SchemaVersion: 1,
+		Subject:       "rooms.{room_id}.archived",
+		Description:   "A room was archived and became read-only.",
+		new:           func() Payload { return &RoomArchived{} },
+	},
+	{
+		Type:          TypeRoomUnarchived,
+		SchemaVersion: 1,
+		Subject:       "rooms.{room_id}.unarchived",
+		Description:   "An archived room was restored.",
+		new:           func() Payload { return &RoomUnarchived{} },
+	},
+	{
+		Type:          TypeRoomOwnershipTransferred,
+		SchemaVersion: 1,
+		Subject:       "rooms.{room_id}.ownership.transferred",
+		Description:   "The owner handed a room to another member and became an admin.",
+		new:           func() Payload { return &RoomOwnershipTransferred{} },
+	},
+	{
+		Type:          TypeRoomDeleted,
+		SchemaVersion: 1,
+		Subject:       "rooms.{room_id}.deleted",
+		Description:   "A room was deleted with its shelves, movies and ratings.",
+		new:           func() Payload { return &RoomDeleted{} },
+	},
+	{
+		Type:          TypeRoomMemberAdded,
+		SchemaVersion: 1,
+		Subject:       "rooms.{room_id}.members.{user_id}.added",
+		Description:   "A user became a member of a room.",
+		new:           func() Payload { return &RoomMemberAdded{} },
+	},
+	{
+		Type:          TypeRoomMemberRoleChanged,
+		SchemaVersion: 1,
+		Subject:       "rooms.{room_id}.members.{user_id}.role_changed",
+		Description:   "A member was given another role in a room.",
+		new:           func() Payload { return &RoomMemberRoleChanged{} },
+	},
+	{
+		Type:          TypeRoomMemberJoined,
+		SchemaVersion: 1,
+		Subject:       "rooms.{room_id}.members.{user_id}.joined",
+		Description:   "A user joined a room through an invite.",
+		new:           func() Payload { return &RoomMemberJoined{} },
+	},
+	{
+		Type:          TypeRoomMemberLeft,
+		SchemaVersion: 1,
+		Subject:       "rooms.{room_id}.members.{user_id}.left",
+		Description:   "A member left a room.",
+		new:           func() Payload { return &RoomMemberLeft{} },
+	},
+	{
+		Type:          TypeRoomMemberRemoved,
+		SchemaVersion: 1,
+		Subject:       "rooms.{room_id}.members.{user_id}.removed",
+		Description:   "A member was removed from a room.",
+		new:           func() Payload { return &RoomMemberRemoved{} },
+	},
+	{
+		Type:          TypeRoomInvitationCreated,
+		SchemaVersion: 1,
+		Subject:       "rooms.{room_id}.invitations.{user_id}.created",
+		Description:   "A user was invited to a room and has to accept or decline.",
+		new:           func() Payload { return &RoomInvitationCreated{} },
+	},
+	{
+		Type:          TypeRoomInvitationUpdated,
+		SchemaVersion: 1,
+		Subject:       "rooms.{room_id}.invitations.{user_id}.updated",
+		Description:   "An invitation was accepted, declined or cancelled.",
+		new:           func() Payload { return &RoomInvitationUpdated{} },
+	},
+	{
+		Type:          TypeRoomShelvesReordered,
+		SchemaVersion: 1,
+		Subject:       "rooms.{room_id}.shelves.reordered",
+		Description:   "The shelves of a room were put in a new order.",
+		new:           func() Payload { return &RoomShelvesReordered{} },
+	},
+	{
+		Type:          TypeShelfCreated,
+		SchemaVersion: 1,
+		Subject:       "rooms.{room_id}.shelves.{shelf_id}.created",
+		Description:   "A shelf was created in a room.",
+		new:           func() Payload { return &ShelfCreated{} },
+	},
+	{
+		Type:          TypeShelfRenamed,
+		SchemaVersion: 1,
+		Subject:       "rooms.{room_id}.shelves.{shelf_id}.renamed",
+		Description:   "A shelf was renamed.",
+		new:           func() Payload { return &ShelfRenamed{} },
+	},
+	{
+		Type:          TypeShelfDeleted,
+		SchemaVersion: 1,
+		Subject:       "rooms.{room_id}.shelves.{shelf_id}.deleted",
+		Description:   "A shelf was deleted with its movies and ratings.",
+		new:           func() Payload { return &ShelfDeleted{} },
+	},
+	{
+		Type:          TypeShelfMovieAdded,
+		SchemaVersion: 1,
+		Subject:       "rooms.{room_id}.shelves.{shelf_id}.movies.{movie_id}.added",
+		Description:   "A movie was put on a shelf.",
+		new:           func() Payload { return &ShelfMovieAdded{} },
+	},
+	{
+		Type:          TypeShelfMovieRated,
+		SchemaVersion: 1,
+		Subject:       "rooms.{room_id}.shelves.{shelf_id}.movies.{movie_id}.rated",
+		Description:   "A member rated a movie on a shelf.",
+		new:           func() Payload { return &ShelfMovieRated{} },
+	},
+	{
+		Type:          TypeShelfMovieRemoved,
+		SchemaVersion: 1,
+		Subject:       "rooms.{room_id}.shelves.{shelf_id}.movies.{movie_id}.removed",
+		Description:   "A movie was taken off a shelf with its ratings.",
+		new:           func() Payload { return &ShelfMovieRemoved{} },
+	},
+	{
+		Type:          TypeShelfMovieMoved,
+		SchemaVersion: 1,
+		Subject:       "rooms.{room_id}.shelves.{shelf_id}.movies.{movie_id}.moved",
+		Description:   "A movie was moved to the shelf with its ratings, from_shelf_id is where it was.",
+		new:           func() Payload { return &ShelfMovieMoved{} },
+	},
+}
+
+var catalogByType = func() map[string]CatalogEntry {
+	entries := make(map[string]CatalogEntry, len(Catalog))
+	for _, entry := range Catalog {
+		entries[entry.Type] = entry
+	}
+	return entries
+}()
+
+func catalogVersion(eventType string) int {
+	return catalogByType[eventType].SchemaVersion
+}
+
+// RoomSubject matches every event about a room.
+func RoomSubject(roomID uuid.UUID) string {
+	return fmt.Sprintf("rooms.%v.>", roomID)
+}
+
+type RoomCreated struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func (e *RoomCreated) EventType() string  { return TypeRoomCreated }
+func (e *RoomCreated) SchemaVersion() int { return catalogVersion(TypeRoomCreated) }
+func (e *RoomCreated) EventSubject() string {
+	return fmt.Sprintf("rooms.%v.created", e.ID)
+}
+
+type RoomUpdated struct {
+	ID            uuid.UUID `json:"id"`
+	Name          string    `json:"name"`
+	Language      string    `json:"language,omitempty"`
+	Region        string    `json:"region,omitempty"`
+	Subscriptions []uint    `json:"subscriptions,omitempty"`
+}
+
+func (e *RoomUpdated) EventType() string  { return TypeRoomUpdated }
+func (e *RoomUpdated) SchemaVersion() int { return catalogVersion(TypeRoomUpdated) }
+func (e *RoomUpdated) EventSubject() string {
+	return fmt.Sprintf("rooms.%v.updated", e.ID)
+}
+
+type RoomArchived struct {
+	ID         uuid.UUID `json:"id"`
+	Name       string    `json:"name"`
+	ArchivedAt time.Time `json:"archived_at"`
+}
+
+func (e *RoomArchived) EventType() string  { return TypeRoomArchived }
+func (e *RoomArchived) SchemaVersion() int { return catalogVersion(TypeRoomArchived) }
+func (e *RoomArchived) EventSubject() string {
+	return fmt.Sprintf("rooms.%v.archived", e.ID)
+}
+
+type RoomUnarchived struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+}
+
+func (e *RoomUnarchived) EventType() string  { return TypeRoomUnarchived }
+func (e *RoomUnarchived) SchemaVersion() int { return catalogVersion(TypeRoomUnarchived) }
+func (e *RoomUnarchived) EventSubject() string {
+	return fmt.Sprintf("rooms.%v.unarchived", e.ID)
+}
+
+type RoomOwnershipTransferred struct {
+	RoomID          uuid.UUID `json:"room_id"`
+	RoomName        string    `json:"room_name"`
+	PreviousOwnerID uuid.UUID `json:"previous_owner_id"`
+	OwnerID         uuid.UUID `json:"owner_id"`
+	OwnerName       string    `json:"owner_name"`
+}
+
+func (e *RoomOwnershipTransferred) EventType() string { return TypeRoomOwnershipTransferred }
+func (e *RoomOwnershipTransferred) SchemaVersion() int {
+	return catalogVersion(TypeRoomOwnershipTransferred)
+}
+func (e *RoomOwnershipTransferred) EventSubject() string {
+	return fmt.Sprintf("rooms.%v.ownership.transferred", e.RoomID)
+}
+
+type RoomDeleted struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+}
+
+func (e *RoomDeleted) EventType() string  { return TypeRoomDeleted }
+func (e *RoomDeleted) SchemaVersion() int { return catalogVersion(TypeRoomDeleted) }
+func (e *RoomDeleted) EventSubject() string {
+	return fmt.Sprintf("rooms.%v.deleted", e.ID)
+}
+
+// RoomMemberAdded carries the role the member was added with, Role is
+// empty in events published before rooms had roles.
+type RoomMemberAdded struct {
+	RoomID    uuid.UUID `json:"room_id"`
+	RoomName  string    `json:"room_name"`
+	UserID    uuid.UUID `json:"user_id"`
+	UserName  string    `json:"user_name"`
+	Role      string    `json:"role,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func (e *RoomMemberAdded) EventType() string  { return TypeRoomMemberAdded }
+func (e *RoomMemberAdded) SchemaVersion() int { return catalogVersion(TypeRoomMemberAdded) }
+func (e *RoomMemberAdded) EventSubject() string {
+	return fmt.Sprintf("rooms.%v.members.%v.added", e.RoomID, e.UserID)
+}
+
+type RoomMemberRoleChanged struct {
+	RoomID       uuid.UUID `json:"room_id"`
+	RoomName     string    `json:"room_name"`
+	UserID       uuid.UUID `json:"user_id"`
+	UserName     string    `json:"user_name"`
+	PreviousRole string    `json:"previous_role"`
+	Role         string    `json:"role"`
+}
+
+func (e *RoomMemberRoleChanged) EventType() string  { return TypeRoomMemberRoleChanged }
+func (e *RoomMemberRoleChanged) SchemaVersion() int { return catalogVersion(TypeRoomMemberRoleChanged) }
+func (e *RoomMemberRoleChanged) EventSubject() string {
+	return fmt.Sprintf("rooms.%v.members.%v.role_changed", e.RoomID, e.UserID)
+}
+
+type RoomMemberJoined struct {
+	RoomID    uuid.UUID `json:"room_id"`
+	RoomName  string    `json:"room_name"`
+	UserID    uuid.UUID `json:"user_id"`
+	UserName  string    `json:"user_name"`
+	Role      string    `json:"role"`
+	InviteID  uuid.UUID `json:"invite_id"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func (e *RoomMemberJoined) EventType() string  { return TypeRoomMemberJoined }
+func (e *RoomMemberJoined) SchemaVersion() int { return catalogVersion(TypeRoomMemberJoined) }
+func (e *RoomMemberJoined) EventSubject() string {
+	return fmt.Sprintf("rooms.%v.members.%v.joined", e.RoomID, e.UserID)
+}
+
+type RoomMemberLeft struct {
+	RoomID   uuid.UUID `json:"room_id"`
+	RoomName string    `json:"room_name"`
+	UserID   uuid.UUID `json:"user_id"`
+	UserName string    `json:"user_name"`
+}
+
+func (e *RoomMemberLeft) EventType() string  { return TypeRoomMemberLeft }
+func (e *RoomMemberLeft) SchemaVersion() int { return catalogVersion(TypeRoomMemberLeft) }
+func (e *RoomMemberLeft) EventSubject() string {
+	return fmt.Sprintf("rooms.%v.members.%v.left", e.RoomID, e.UserID)
+}
+
+type RoomMemberRemoved struct {
+	RoomID   uuid.UUID `json:"room_id"`
+	RoomName string    `json:"room_name"`
+	UserID   uuid.UUID `json:"user_id"`
+	UserName string    `json:"user_name"`
+}
+
+func (e *RoomMemberRemoved) EventType() string  { return TypeRoomMemberRemoved }
+func (e *RoomMemberRemoved) SchemaVersion() int { return catalogVersion(TypeRoomMemberRemoved) }
+func (e *RoomMemberRemoved) EventSubject() string {
+	return fmt.Sprintf("rooms.%v.members.%v.removed", e.RoomID, e.UserID)
+}
+
+// RoomInvitationCreated is also delivered to the invitee's websocket
+// connections, who is not a member of the room yet.
+type RoomInvitationCreated struct {
+	ID            uuid.UUID `json:"id"`
+	RoomID        uuid.UUID `json:"room_id"`
+	RoomName      string    `json:"room_name"`
+	UserID        uuid.UUID `json:"user_id"`
+	UserName      string    `json:"user_name"`
+	InvitedBy     uuid.UUID `json:"invited_by"`
+	InvitedByName string    `json:"invited_by_name"`
+	Role          string    `json:"role"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+func (e *RoomInvitationCreated) EventType() string  { return TypeRoomInvitationCreated }
+func (e *RoomInvitationCreated) SchemaVersion() int { return catalogVersion(TypeRoomInvitationCreated) }
+func (e *RoomInvitationCreated) EventSubject() string {
+	return fmt.Sprintf("rooms.%v.invitations.%v.created", e.RoomID, e.UserID)
+}
+
+// RoomInvitationUpdated carries the new status, accepted, declined or
+// cancelled.
+type RoomInvitationUpdated struct {
+	ID          uuid.UUID `json:"id"`
+	RoomID      uuid.UUID `json:"room_id"`
+	UserID      uuid.UUID `json:"user_id"`
+	Status      string    `json:"status"`
+	RespondedAt time.Time `json:"responded_at"`
+}
+
+func (e *RoomInvitationUpdated) EventType() string  { return TypeRoomInvitationUpdated }
+func (e *RoomInvitationUpdated) SchemaVersion() int { return catalogVersion(TypeRoomInvitationUpdated) }
+func (e *RoomInvitationUpdated) EventSubject() string {
+	return fmt.Sprintf("rooms.%v.invitations.%v.updated", e.RoomID, e.UserID)
+}
+
+// RoomShelvesReordered lists every shelf of the room in its new order.
+type RoomShelvesReordered struct {
+	RoomID   uuid.UUID   `json:"room_id"`
+	ShelfIDs []uuid.UUID `json:"shelf_ids"`
+}
+
+func (e *RoomShelvesReordered) EventType() string  { return TypeRoomShelvesReordered }
+func (e *RoomShelvesReordered) SchemaVersion() int { return catalogVersion(TypeRoomShelvesReordered) }
+func (e *RoomShelvesReordered) EventSubject() string {
+	return fmt.Sprintf("rooms.%v.shelves.reordered", e.RoomID)
+}
+
+type ShelfCreated struct {
+	ID        uuid.UUID `json:"id"`
+	RoomID    uuid.UUID `json:"room_id"`
+	Name      string    `json:"name"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func (e *ShelfCreated) EventType() string  { return TypeShelfCreated }
+func (e *ShelfCreated) SchemaVersion() int { return catalogVersion(TypeShelfCreated) }
+func (e *ShelfCreated) EventSubject() string {
+	return fmt.Sprintf("rooms.%v.shelves.%v.created", e.RoomID, e.ID)
+}
+
+type ShelfRenamed struct {
+	ID     uuid.UUID `json:"id"`
+	RoomID uuid.UUID `json:"room_id"`
+	Name   string    `json:"name"`
+}
+
+func (e *ShelfRenamed) EventType() string  { return TypeShelfRenamed }
+func (e *ShelfRenamed) SchemaVersion() int { return catalogVersion(TypeShelfRenamed) }
+func (e *ShelfRenamed) EventSubject() string {
+	return fmt.Sprintf("rooms.%v.shelves.%v.renamed", e.RoomID, e.ID)
+}
+
+type ShelfDeleted struct {
+	ID     uuid.UUID `json:"id"`
+	RoomID uuid.UUID `json:"room_id"`
+	Name   string    `json:"name"`
+}
+
+func (e *ShelfDeleted) EventType() string  { return TypeShelfDeleted }
+func (e *ShelfDeleted) SchemaVersion() int { return catalogVersion(TypeShelfDeleted) }
+func (e *ShelfDeleted) EventSubject() string {
+	return fmt.Sprintf("rooms.%v.shelves.%v.deleted", e.RoomID, e.ID)
+}
+
+// ShelfMovieAdded is published for movies and TV series, MediaType is
+// empty in events published before TV series were supported.
+type ShelfMovieAdded struct {
+	ID           uuid.UUID `json:"id"`
+	RoomID       uuid.UUID `json:"room_id"`
+	ShelfID      uuid.UUID `json:"shelf_id"`
+	MediaType    string    `json:"media_type,omitempty"`
+	MovieID      uint      `json:"movie_id"`
+	SeasonNumber *uint     `json:"season_number,omitempty"`
+}
+
+func (e *ShelfMovieAdded) EventType() string  { return TypeShelfMovieAdded }
+func (e *ShelfMovieAdded) SchemaVersion() int { return catalogVersion(TypeShelfMovieAdded) }
+func (e *ShelfMovieAdded) EventSubject() string {
+	return fmt.Sprintf("rooms.%v.shelves.%v.movies.%v.added", e.RoomID, e.ShelfID, e.ID)
+}
+
+type ShelfMovieRated struct {
+	ID        uuid.UUID `json:"id"`
+	RoomID    uuid.UUID `json:"room_id"`
+	ShelfID   uuid.UUID `json:"shelf_id"`
+	MovieID   uuid.UUID `json:"movie_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Rating    float64   `json:"rating"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func (e *ShelfMovieRated) EventType() string  { return TypeShelfMovieRated }
+func (e *ShelfMovieRated) SchemaVersion() int { return catalogVersion(TypeShelfMovieRated) }
+func (e *ShelfMovieRated) EventSubject() string {
+	return fmt.Sprintf("rooms.%v.shelves.%v.movies.%v.rated", e.RoomID, e.ShelfID, e.MovieID)
+}
+
+type ShelfMovieRemoved struct {
+	ID           uuid.UUID `json:"id"`
+	RoomID       uuid.UUID `json:"room_id"`
+	ShelfID      uuid.UUID `json:"shelf_id"`
+	MediaType    string    `json:"media_type"`
+	MovieID      uint      `json:"movie_id"`
+	SeasonNumber *uint     `json:"season_number,omitempty"`
+}
+
+func (e *ShelfMovieRemoved) EventType() string  { return TypeShelfMovieRemoved }
+func (e *ShelfMovieRemoved) SchemaVersion() int { return catalogVersion(TypeShelfMovieRemoved) }
+func (e *ShelfMovieRemoved) EventSubject() string {
+	return fmt.Sprintf("rooms.%v.shelves.%v.movies.%v.removed", e.RoomID, e.ShelfID, e.ID)
+}
+
+// ShelfMovieMoved is published under the shelf the movie was moved to. The
+// websocket gateway also delivers it to subscribers of FromShelfID.
+type ShelfMovieMoved struct {
+	ID           uuid.UUID `json:"id"`
+	RoomID       uuid.UUID `json:"room_id"`
+	ShelfID      uuid.UUID `json:"shelf_id"`
+	FromShelfID  uuid.UUID `json:"from_shelf_id"`
+	MediaType    string    `json:"media_type"`
+	MovieID      uint      `json:"movie_id"`
+	SeasonNumber *uint     `json:"season_number,omitempty"`
+}
+
+func (e *ShelfMovieMoved) EventType() string  { return TypeShelfMovieMoved }
+func (e *ShelfMovieMoved) SchemaVersion() int { return catalogVersion(TypeShelfMovieMoved) }
+func (e *ShelfMovieMoved) EventSubject() string {
+	return fmt.Sprintf("rooms.%v.shelves.%v.movies.%v.moved", e.RoomID, e.ShelfID, e.ID)
+}
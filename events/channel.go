@@ -0,0 +1,138 @@
+package events
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const channelBufferSize = 256
+
+// ChannelBus delivers events in-process. Every subscription has its own
+// buffered channel and goroutine, so a slow handler only drops its own
+// events, the same way NATS treats slow consumers.
+type ChannelBus struct {
+	mu            sync.RWMutex
+	subscriptions map[*channelSubscription]struct{}
+	closed        bool
+}
+
+type channelSubscription struct {
+	bus     *ChannelBus
+	subject string
+	events  chan Event
+	done    chan struct{}
+	once    sync.Once
+}
+
+func NewChannelBus() *ChannelBus {
+	return &ChannelBus{
+		subscriptions: make(map[*channelSubscription]struct{}),
+	}
+}
+
+func (c *ChannelBus) Publish(event Event) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return fmt.Errorf("Bus is closed")
+	}
+
+	for subscription := range c.subscriptions {
+		if !MatchSubject(subscription.subject, event.Subject) {
+			continue
+		}
+
+		select {
+		case subscription.events <- event:
+		default:
+			fmt.Printf("Slow consumer on %v, dropping event %v\n", subscription.subject, event.Subject)
+		}
+	}
+
+	return nil
+}
+
+func (c *ChannelBus) Subscribe(subject string, handler Handler) (Subscription, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, fmt.Errorf("Bus is closed")
+	}
+
+	subscription := &channelSubscription{
+		bus:     c,
+		subject: subject,
+		events:  make(chan Event, channelBufferSize),
+		done:    make(chan struct{}),
+	}
+
+	c.subscriptions[subscription] = struct{}{}
+
+	go func() {
+		for {
+			select {
+			case <-subscription.done:
+				return
+			case event := <-subscription.events:
+				handler(event)
+			}
+		}
+	}()
+
+	return subscription, nil
+}
+
+// Flush waits until every subscription has picked up its buffered events.
+func (c *ChannelBus) Flush(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		c.mu.RLock()
+		pending := 0
+		for subscription := range c.subscriptions {
+			pending += len(subscription.events)
+		}
+		c.mu.RUnlock()
+
+		if pending == 0 {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Flush timed out with %v pending events", pending)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (c *ChannelBus) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	for subscription := range c.subscriptions {
+		subscription.stop()
+	}
+	c.subscriptions = make(map[*channelSubscription]struct{})
+
+	return nil
+}
+
+func (s *channelSubscription) Unsubscribe() error {
+	s.bus.mu.Lock()
+	delete(s.bus.subscriptions, s)
+	s.bus.mu.Unlock()
+
+	s.stop()
+	return nil
+}
+
+func (s *channelSubscription) stop() {
+	s.once.Do(func() {
+		close(s.done)
+	})
+}
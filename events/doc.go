@@ -0,0 +1,35 @@
+// Package events carries the domain events the API publishes and the bus
+// implementations that deliver them.
+//
+// Every event is a JSON Envelope compatible with CloudEvents 1.0. The type
+// names the event, schemaversion is bumped on breaking payload changes,
+// actorid is the user who caused it and roomid is the room it belongs to.
+// Consumers decode messages with Decode and switch on the payload type.
+//
+// All subjects live under rooms.{room_id} and end with a past tense verb,
+// so rooms.{room_id}.> follows everything that happens in a room:
+//
+//	room.created                v1  rooms.{room_id}.created
+//	room.updated                v1  rooms.{room_id}.updated
+//	room.archived               v1  rooms.{room_id}.archived
+//	room.unarchived             v1  rooms.{room_id}.unarchived
+//	room.ownership.transferred  v1  rooms.{room_id}.ownership.transferred
+//	room.deleted                v1  rooms.{room_id}.deleted
+//	room.member.added           v1  rooms.{room_id}.members.{user_id}.added
+//	room.member.role_changed    v1  rooms.{room_id}.members.{user_id}.role_changed
+//	room.member.joined          v1  rooms.{room_id}.members.{user_id}.joined
+//	room.member.left            v1  rooms.{room_id}.members.{user_id}.left
+//	room.member.removed         v1  rooms.{room_id}.members.{user_id}.removed
+//	room.invitation.created     v1  rooms.{room_id}.invitations.{user_id}.created
+//	room.invitation.updated     v1  rooms.{room_id}.invitations.{user_id}.updated
+//	room.shelves.reordered      v1  rooms.{room_id}.shelves.reordered
+//	shelf.created               v1  rooms.{room_id}.shelves.{shelf_id}.created
+//	shelf.renamed               v1  rooms.{room_id}.shelves.{shelf_id}.renamed
+//	shelf.deleted               v1  rooms.{room_id}.shelves.{shelf_id}.deleted
+//	shelf.movie.added           v1  rooms.{room_id}.shelves.{shelf_id}.movies.{movie_id}.added
+//	shelf.movie.rated           v1  rooms.{room_id}.shelves.{shelf_id}.movies.{movie_id}.rated
+//	shelf.movie.removed         v1  rooms.{room_id}.shelves.{shelf_id}.movies.{movie_id}.removed
+//	shelf.movie.moved           v1  rooms.{room_id}.shelves.{shelf_id}.movies.{movie_id}.moved
+//
+// Catalog holds the same table for programmatic use.
+package events
@@ -0,0 +1,132 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	SpecVersion     = "1.0"
+	Source          = "/movie-nest"
+	DataContentType = "application/json"
+)
+
+// Envelope wraps every published event. It follows the CloudEvents 1.0
+// JSON format, actorid, roomid and schemaversion are extension attributes.
+type Envelope struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	SchemaVersion   int             `json:"schemaversion"`
+	ActorID         uuid.UUID       `json:"actorid"`
+	RoomID          uuid.UUID       `json:"roomid"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// Payload is implemented by every event in the catalogue.
+type Payload interface {
+	EventType() string
+	SchemaVersion() int
+	EventSubject() string
+}
+
+func NewEnvelope(actorID, roomID uuid.UUID, payload Payload) (*Envelope, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode %v: %w", payload.EventType(), err)
+	}
+
+	return &Envelope{
+		SpecVersion:     SpecVersion,
+		ID:              uuid.New().String(),
+		Source:          Source,
+		Type:            payload.EventType(),
+		Subject:         payload.EventSubject(),
+		Time:            time.Now().UTC(),
+		DataContentType: DataContentType,
+		SchemaVersion:   payload.SchemaVersion(),
+		ActorID:         actorID,
+		RoomID:          roomID,
+		Data:            data,
+	}, nil
+}
+
+// NewEnvelopeEvent builds the envelope and encodes it for the bus.
+func NewEnvelopeEvent(actorID, roomID uuid.UUID, payload Payload) (Event, error) {
+	envelope, err := NewEnvelope(actorID, roomID, payload)
+	if err != nil {
+		return Event{}, err
+	}
+
+	return envelope.Event()
+}
+
+func (e *Envelope) Event() (Event, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return Event{}, fmt.Errorf("Failed to encode envelope %v: %w", e.Type, err)
+	}
+
+	return Event{
+		ID:      e.ID,
+		Subject: e.Subject,
+		Data:    data,
+	}, nil
+}
+
+func DecodeEnvelope(data []byte) (*Envelope, error) {
+	envelope := &Envelope{}
+	err := json.Unmarshal(data, envelope)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode envelope: %w", err)
+	}
+
+	if envelope.SpecVersion != SpecVersion {
+		return nil, fmt.Errorf("Unsupported specversion: %q", envelope.SpecVersion)
+	}
+
+	return envelope, nil
+}
+
+// Decode returns the envelope together with its typed payload, for example
+// a *RoomCreated. Unknown event types and newer schema versions than this
+// package knows about return an error.
+func Decode(data []byte) (*Envelope, Payload, error) {
+	envelope, err := DecodeEnvelope(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	payload, err := envelope.Payload()
+	if err != nil {
+		return envelope, nil, err
+	}
+
+	return envelope, payload, nil
+}
+
+func (e *Envelope) Payload() (Payload, error) {
+	entry, exists := catalogByType[e.Type]
+	if !exists {
+		return nil, fmt.Errorf("Unknown event type: %q", e.Type)
+	}
+
+	if e.SchemaVersion > entry.SchemaVersion {
+		return nil, fmt.Errorf("Unsupported schema version %v for %v", e.SchemaVersion, e.Type)
+	}
+
+	payload := entry.new()
+	err := json.Unmarshal(e.Data, payload)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode %v: %w", e.Type, err)
+	}
+
+	return payload, nil
+}
@@ -0,0 +1,224 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	RoomsStream         = "ROOMS"
+	DefaultHistoryLimit = 500
+	MaxHistoryLimit     = 1000
+)
+
+// History replays the events of a room, oldest first.
+type History interface {
+	RoomHistory(ctx context.Context, roomID uuid.UUID, query HistoryQuery) (*HistoryPage, error)
+}
+
+// HistoryQuery selects the events published at or after Since, or after
+// the event with the sequence After when it is set. At most Limit events
+// are returned.
+type HistoryQuery struct {
+	Since time.Time
+	After uint64
+	Limit int
+}
+
+// HistoryPage holds the oldest matching events. HasMore tells that more
+// events follow, they are read by passing Next as the After of the next
+// query. Next is the sequence of the last event on the page, or the After
+// of the query when the page is empty.
+type HistoryPage struct {
+	Events  []Event
+	HasMore bool
+	Next    uint64
+}
+
+// JetStreamHistory keeps every rooms.> event in a JetStream stream. The
+// stream drops events older than MaxAge and deduplicates on the envelope
+// id, so outbox retries are stored once.
+type JetStreamHistory struct {
+	JS     nats.JetStreamContext
+	Stream string
+}
+
+func NewJetStreamHistory(conn *nats.Conn, maxAge time.Duration) (*JetStreamHistory, error) {
+	js, err := conn.JetStream()
+	if err != nil {
+		return nil, err
+	}
+
+	config := &nats.StreamConfig{
+		Name:       RoomsStream,
+		Subjects:   []string{"rooms.>"},
+		Retention:  nats.LimitsPolicy,
+		Storage:    nats.FileStorage,
+		MaxAge:     maxAge,
+		Duplicates: time.Minute * 10,
+	}
+
+	_, err = js.StreamInfo(RoomsStream)
+	switch {
+	case errors.Is(err, nats.ErrStreamNotFound):
+		_, err = js.AddStream(config)
+	case err == nil:
+		_, err = js.UpdateStream(config)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to set up %v stream: %w", RoomsStream, err)
+	}
+
+	return &JetStreamHistory{
+		JS:     js,
+		Stream: RoomsStream,
+	}, nil
+}
+
+func (j *JetStreamHistory) RoomHistory(ctx context.Context, roomID uuid.UUID, query HistoryQuery) (*HistoryPage, error) {
+	options := []nats.SubOpt{
+		nats.BindStream(j.Stream),
+		nats.OrderedConsumer(),
+	}
+
+	switch {
+	case query.After > 0:
+		options = append(options, nats.StartSequence(query.After+1))
+	case !query.Since.IsZero():
+		options = append(options, nats.StartTime(query.Since))
+	default:
+		options = append(options, nats.DeliverAll())
+	}
+
+	subscription, err := j.JS.SubscribeSync(RoomSubject(roomID), options...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read room history: %w", err)
+	}
+	defer subscription.Unsubscribe()
+
+	info, err := subscription.ConsumerInfo()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read room history: %w", err)
+	}
+
+	page := &HistoryPage{Next: query.After}
+	if info.NumPending+info.Delivered.Consumer == 0 {
+		return page, nil
+	}
+
+	// Only the page is read, the consumer tells whether more follow.
+	for len(page.Events) < query.Limit {
+		msg, err := subscription.NextMsgWithContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read room history: %w", err)
+		}
+
+		metadata, err := msg.Metadata()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read room history: %w", err)
+		}
+
+		page.Events = append(page.Events, Event{
+			ID:      msg.Header.Get(nats.MsgIdHdr),
+			Subject: msg.Subject,
+			Data:    msg.Data,
+		})
+		page.Next = metadata.Sequence.Stream
+
+		if metadata.NumPending == 0 {
+			return page, nil
+		}
+	}
+
+	page.HasMore = true
+	return page, nil
+}
+
+// MemoryHistory records room events from any bus in memory. It is used
+// when JetStream is not available, history is lost on restart.
+type MemoryHistory struct {
+	MaxAge     time.Duration
+	MaxPerRoom int
+
+	mu           sync.RWMutex
+	rooms        map[uuid.UUID][]recordedEvent
+	sequence     uint64
+	subscription Subscription
+}
+
+type recordedEvent struct {
+	Event
+	Time     time.Time
+	Sequence uint64
+}
+
+func NewMemoryHistory(bus Bus, maxAge time.Duration) (*MemoryHistory, error) {
+	history := &MemoryHistory{
+		MaxAge:     maxAge,
+		MaxPerRoom: MaxHistoryLimit,
+		rooms:      make(map[uuid.UUID][]recordedEvent),
+	}
+
+	subscription, err := bus.Subscribe("rooms.>", history.record)
+	if err != nil {
+		return nil, err
+	}
+	history.subscription = subscription
+
+	return history, nil
+}
+
+func (m *MemoryHistory) RoomHistory(ctx context.Context, roomID uuid.UUID, query HistoryQuery) (*HistoryPage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	since := query.Since
+	cutoff := time.Now().Add(-m.MaxAge)
+	if since.Before(cutoff) {
+		since = cutoff
+	}
+
+	page := &HistoryPage{Next: query.After}
+	for _, recorded := range m.rooms[roomID] {
+		if recorded.Sequence <= query.After || (query.After == 0 && recorded.Time.Before(since)) || recorded.Time.Before(cutoff) {
+			continue
+		}
+
+		if len(page.Events) == query.Limit {
+			page.HasMore = true
+			break
+		}
+
+		page.Events = append(page.Events, recorded.Event)
+		page.Next = recorded.Sequence
+	}
+
+	return page, nil
+}
+
+func (m *MemoryHistory) Close() error {
+	return m.subscription.Unsubscribe()
+}
+
+func (m *MemoryHistory) record(event Event) {
+	envelope, err := DecodeEnvelope(event.Data)
+	if err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sequence++
+	recorded := append(m.rooms[envelope.RoomID], recordedEvent{Event: event, Time: envelope.Time, Sequence: m.sequence})
+	if len(recorded) > m.MaxPerRoom {
+		recorded = recorded[len(recorded)-m.MaxPerRoom:]
+	}
+	m.rooms[envelope.RoomID] = recorded
+}
@@ -0,0 +1,52 @@
+package events
+
+import (
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+type NatsBus struct {
+	Conn *nats.Conn
+}
+
+func NewNatsBus(conn *nats.Conn) *NatsBus {
+	return &NatsBus{
+		Conn: conn,
+	}
+}
+
+// Publish sets the event id as Nats-Msg-Id so JetStream stores an event
+// only once when it is published again.
+func (n *NatsBus) Publish(event Event) error {
+	msg := nats.NewMsg(event.Subject)
+	msg.Data = event.Data
+	if event.ID != "" {
+		msg.Header.Set(nats.MsgIdHdr, event.ID)
+	}
+
+	return n.Conn.PublishMsg(msg)
+}
+
+func (n *NatsBus) Subscribe(subject string, handler Handler) (Subscription, error) {
+	subscription, err := n.Conn.Subscribe(subject, func(msg *nats.Msg) {
+		handler(Event{
+			ID:      msg.Header.Get(nats.MsgIdHdr),
+			Subject: msg.Subject,
+			Data:    msg.Data,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+func (n *NatsBus) Flush(timeout time.Duration) error {
+	return n.Conn.FlushTimeout(timeout)
+}
+
+func (n *NatsBus) Close() error {
+	return n.Conn.Drain()
+}
@@ -0,0 +1,287 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func runJetStream(t *testing.T) *nats.Conn {
+	t.Helper()
+
+	ns, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create nats server: %v", err)
+	}
+
+	go ns.Start()
+	if !ns.ReadyForConnections(time.Second * 5) {
+		t.Fatal("Nats server did not start")
+	}
+	t.Cleanup(ns.Shutdown)
+
+	conn, err := nats.Connect(ns.ClientURL())
+	if err != nil {
+		t.Fatalf("Failed to connect to nats: %v", err)
+	}
+	t.Cleanup(conn.Close)
+
+	return conn
+}
+
+func newRoomEvent(t *testing.T, roomID uuid.UUID, name string) Event {
+	t.Helper()
+
+	event, err := NewEnvelopeEvent(uuid.New(), roomID, &ShelfCreated{ID: uuid.New(), RoomID: roomID, Name: name})
+	if err != nil {
+		t.Fatalf("NewEnvelopeEvent: %v", err)
+	}
+	return event
+}
+
+// waitStored waits until the stream holds count messages, publishing on
+// the bus is not acknowledged by JetStream.
+func waitStored(t *testing.T, history *JetStreamHistory, count uint64) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second * 5)
+	for time.Now().Before(deadline) {
+		info, err := history.JS.StreamInfo(history.Stream)
+		if err == nil && info.State.Msgs == count {
+			return
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	t.Fatalf("Stream did not reach %v messages", count)
+}
+
+func eventIDs(events []Event) []string {
+	ids := make([]string, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+	return ids
+}
+
+func equalIDs(got []Event, want []Event) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i].ID != want[i].ID {
+			return false
+		}
+	}
+	return true
+}
+
+func TestNatsBusPublish(t *testing.T) {
+	bus := NewNatsBus(runJetStream(t))
+
+	received := make(chan Event, 1)
+	_, err := bus.Subscribe("rooms.>", func(event Event) {
+		received <- event
+	})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	event := newRoomEvent(t, uuid.New(), "Favourites")
+	err = bus.Publish(event)
+	if err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	select {
+	case got := <-received:
+		if got.ID != event.ID || got.Subject != event.Subject || string(got.Data) != string(event.Data) {
+			t.Errorf("Received %+v, want %+v", got, event)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out waiting for the event")
+	}
+}
+
+func TestJetStreamHistory(t *testing.T) {
+	conn := runJetStream(t)
+	bus := NewNatsBus(conn)
+
+	history, err := NewJetStreamHistory(conn, time.Hour)
+	if err != nil {
+		t.Fatalf("NewJetStreamHistory: %v", err)
+	}
+
+	roomID := uuid.New()
+	var published []Event
+	var since time.Time
+	for i := 0; i < 5; i++ {
+		if i == 3 {
+			since = time.Now()
+		}
+
+		event := newRoomEvent(t, roomID, "Shelf")
+		err := bus.Publish(event)
+		if err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+		published = append(published, event)
+
+		// StartTime is matched against the time the stream stored the
+		// message, keep the events apart.
+		time.Sleep(time.Millisecond * 20)
+	}
+
+	err = bus.Publish(newRoomEvent(t, uuid.New(), "Other room"))
+	if err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	waitStored(t, history, 6)
+
+	testHistoryPages(t, history, roomID, since, published)
+
+	empty, err := history.RoomHistory(context.Background(), uuid.New(), HistoryQuery{Limit: DefaultHistoryLimit})
+	if err != nil || len(empty.Events) != 0 || empty.HasMore {
+		t.Errorf("RoomHistory of a room without events = %+v, %v, want none", empty, err)
+	}
+}
+
+// testHistoryPages checks the pages of five published events, the last two
+// of them at or after since.
+func testHistoryPages(t *testing.T, history History, roomID uuid.UUID, since time.Time, published []Event) {
+	t.Helper()
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		query   HistoryQuery
+		want    []Event
+		hasMore bool
+	}{
+		{"all", HistoryQuery{Limit: DefaultHistoryLimit}, published, false},
+		{"oldest", HistoryQuery{Limit: 2}, published[:2], true},
+		{"exact", HistoryQuery{Limit: 5}, published, false},
+		{"since", HistoryQuery{Since: since, Limit: DefaultHistoryLimit}, published[3:], false},
+		{"since oldest", HistoryQuery{Since: since, Limit: 1}, published[3:4], true},
+	}
+
+	for _, test := range tests {
+		page, err := history.RoomHistory(ctx, roomID, test.query)
+		if err != nil {
+			t.Fatalf("%v: RoomHistory: %v", test.name, err)
+		}
+
+		if !equalIDs(page.Events, test.want) || page.HasMore != test.hasMore {
+			t.Errorf("%v: RoomHistory = %v (has more %v), want %v (has more %v)", test.name, eventIDs(page.Events), page.HasMore, eventIDs(test.want), test.hasMore)
+		}
+	}
+
+	// Following the cursor replays every event once.
+	var replayed []Event
+	query := HistoryQuery{Limit: 2}
+	for {
+		page, err := history.RoomHistory(ctx, roomID, query)
+		if err != nil {
+			t.Fatalf("RoomHistory(%+v): %v", query, err)
+		}
+		replayed = append(replayed, page.Events...)
+
+		query.After = page.Next
+		if !page.HasMore {
+			break
+		}
+	}
+
+	if !equalIDs(replayed, published) {
+		t.Errorf("Paged RoomHistory = %v, want %v", eventIDs(replayed), eventIDs(published))
+	}
+
+	// Polling with the last cursor finds nothing new.
+	page, err := history.RoomHistory(ctx, roomID, query)
+	if err != nil || len(page.Events) != 0 || page.HasMore || page.Next != query.After {
+		t.Errorf("RoomHistory after the last event = %v, %v (next %v), want none (next %v)", eventIDs(page.Events), err, page.Next, query.After)
+	}
+}
+
+func TestJetStreamHistoryRedelivery(t *testing.T) {
+	conn := runJetStream(t)
+	bus := NewNatsBus(conn)
+
+	history, err := NewJetStreamHistory(conn, time.Hour)
+	if err != nil {
+		t.Fatalf("NewJetStreamHistory: %v", err)
+	}
+
+	roomID := uuid.New()
+	event := newRoomEvent(t, roomID, "Shelf")
+	next := newRoomEvent(t, roomID, "Next shelf")
+
+	// The outbox publishes an event again when it could not mark it as
+	// sent, the stream has to keep one copy.
+	for _, published := range []Event{event, event, next, event} {
+		err := bus.Publish(published)
+		if err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+
+	err = conn.Flush()
+	if err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	waitStored(t, history, 2)
+
+	page, err := history.RoomHistory(context.Background(), roomID, HistoryQuery{Limit: DefaultHistoryLimit})
+	if err != nil {
+		t.Fatalf("RoomHistory: %v", err)
+	}
+
+	if !equalIDs(page.Events, []Event{event, next}) {
+		t.Errorf("RoomHistory = %v, want %v", eventIDs(page.Events), []string{event.ID, next.ID})
+	}
+}
+
+func TestMemoryHistory(t *testing.T) {
+	bus := NewChannelBus()
+	defer bus.Close()
+
+	history, err := NewMemoryHistory(bus, time.Hour)
+	if err != nil {
+		t.Fatalf("NewMemoryHistory: %v", err)
+	}
+	defer history.Close()
+
+	roomID := uuid.New()
+	var published []Event
+	var since time.Time
+	for i := 0; i < 5; i++ {
+		if i == 3 {
+			since = time.Now()
+		}
+
+		event := newRoomEvent(t, roomID, "Shelf")
+		bus.Publish(event)
+		published = append(published, event)
+
+		// Keep the envelope times apart.
+		time.Sleep(time.Millisecond * 2)
+	}
+	bus.Publish(newRoomEvent(t, uuid.New(), "Other room"))
+
+	err = bus.Flush(time.Second)
+	if err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	testHistoryPages(t, history, roomID, since, published)
+}
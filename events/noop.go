@@ -0,0 +1,32 @@
+package events
+
+import "time"
+
+// NoopBus drops every event. It is used when eventing is disabled.
+type NoopBus struct{}
+
+type noopSubscription struct{}
+
+func NewNoopBus() *NoopBus {
+	return &NoopBus{}
+}
+
+func (*NoopBus) Publish(event Event) error {
+	return nil
+}
+
+func (*NoopBus) Subscribe(subject string, handler Handler) (Subscription, error) {
+	return noopSubscription{}, nil
+}
+
+func (*NoopBus) Flush(timeout time.Duration) error {
+	return nil
+}
+
+func (*NoopBus) Close() error {
+	return nil
+}
+
+func (noopSubscription) Unsubscribe() error {
+	return nil
+}
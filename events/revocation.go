@@ -0,0 +1,29 @@
+package events
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// RevocationSubjects matches every revocation. Revocations tell the
+// gateways that a user lost access to a room. Stores publish them as soon
+// as the change is committed instead of through the outbox, so they carry
+// no payload, are not stored in the history and may be lost.
+const RevocationSubjects = "revocations.>"
+
+func RevocationSubject(roomID, userID uuid.UUID) string {
+	return fmt.Sprintf("revocations.rooms.%v.users.%v", roomID, userID)
+}
+
+// PublishRevocation publishes a revocation for every user in userIDs.
+func PublishRevocation(bus Bus, roomID uuid.UUID, userIDs ...uuid.UUID) error {
+	for _, userID := range userIDs {
+		err := bus.Publish(Event{Subject: RevocationSubject(roomID, userID)})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
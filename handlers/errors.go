@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/adamelfsborg-code/movie-nest/data"
+	"github.com/adamelfsborg-code/movie-nest/pkg/images"
+	"github.com/adamelfsborg-code/movie-nest/pkg/omdb"
+	"github.com/adamelfsborg-code/movie-nest/pkg/themoviedb"
+	"github.com/go-pg/pg/v10"
+)
+
+// movieDBStatus maps TMDB, OMDb and image proxy errors to the status we
+// answer with. Any other error gets fallback.
+func movieDBStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, themoviedb.ErrNotFound), errors.Is(err, omdb.ErrNotFound),
+		errors.Is(err, images.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, themoviedb.ErrRateLimited):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, themoviedb.ErrUnauthorized), errors.Is(err, themoviedb.ErrUnavailable),
+		errors.Is(err, omdb.ErrUnauthorized), errors.Is(err, omdb.ErrUnavailable),
+		errors.Is(err, images.ErrUnavailable):
+		return http.StatusBadGateway
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	}
+	return fallback
+}
+
+// storeStatus maps store errors to the status we answer with. Any other
+// error gets fallback.
+func storeStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, data.ErrShelfOrder), errors.Is(err, data.ErrOtherRoom),
+		errors.Is(err, data.ErrUnknownMediaType), errors.Is(err, data.ErrDiscoverMediaType),
+		errors.Is(err, data.ErrDiscoverUnsupported):
+		return http.StatusBadRequest
+	case errors.Is(err, data.ErrForbidden), errors.Is(err, data.ErrBlocked):
+		return http.StatusForbidden
+	case errors.Is(err, pg.ErrNoRows):
+		return http.StatusNotFound
+	case errors.Is(err, data.ErrInviteExpired), errors.Is(err, data.ErrInviteRevoked),
+		errors.Is(err, data.ErrInviteUsedUp), errors.Is(err, data.ErrInvitationClosed):
+		return http.StatusGone
+	case errors.Is(err, data.ErrAlreadyMember), errors.Is(err, data.ErrAlreadyInvited),
+		errors.Is(err, data.ErrRoomArchived), errors.Is(err, data.ErrOwnerCannotLeave),
+		errors.Is(err, data.ErrMovieOnShelf):
+		return http.StatusConflict
+	}
+	return fallback
+}
+
+// storeMessage answers with the error itself when it tells the client why
+// the request cannot be done, like a used up invite or an archived room,
+// and with fallback otherwise.
+func storeMessage(err error, fallback string) string {
+	switch storeStatus(err, 0) {
+	case http.StatusBadRequest, http.StatusGone, http.StatusConflict:
+		return err.Error()
+	}
+	if errors.Is(err, data.ErrBlocked) {
+		return err.Error()
+	}
+	return fallback
+}
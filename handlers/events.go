@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/adamelfsborg-code/movie-nest/realtime"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type EventsHandler struct {
+	Hub       *realtime.Hub
+	Heartbeat time.Duration
+}
+
+const sseWriteTimeout = time.Second * 10
+
+func (e *EventsHandler) StreamRoomEvents(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "room_id")
+
+	roomID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := uuid.Parse(r.Header.Get("X-UserID"))
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := w.(http.Flusher); !ok {
+		fmt.Println("Streaming not supported")
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// EventSource only sends Last-Event-ID on reconnects, the query
+	// parameter lets a fresh page load resume as well.
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+
+	client, backlog, resync := e.Hub.Subscribe(roomID, userID, lastEventID)
+	defer e.Hub.Unsubscribe(client)
+
+	controller := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	write := func(format string, args ...interface{}) bool {
+		controller.SetWriteDeadline(time.Now().Add(sseWriteTimeout))
+		_, err := fmt.Fprintf(w, format, args...)
+		if err == nil {
+			err = controller.Flush()
+		}
+		if err != nil {
+			fmt.Println("Failed to write event stream: ", err)
+			return false
+		}
+		return true
+	}
+
+	if !write("retry: 3000\n\n") {
+		return
+	}
+
+	if resync && !write("event: resync\ndata: {}\n\n") {
+		return
+	}
+
+	for _, message := range backlog {
+		if !write("id: %s\nevent: %s\ndata: %s\n\n", message.ID, message.Type, message.Data) {
+			return
+		}
+	}
+
+	heartbeat := e.Heartbeat
+	if heartbeat <= 0 {
+		heartbeat = time.Second * 15
+	}
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			if !write(": heartbeat\n\n") {
+				return
+			}
+		case message, ok := <-client.Messages:
+			if !ok {
+				if client.Dropped() {
+					write("event: overflow\ndata: {}\n\n")
+				}
+				return
+			}
+
+			if !write("id: %s\nevent: %s\ndata: %s\n\n", message.ID, message.Type, message.Data) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/adamelfsborg-code/movie-nest/realtime"
+	"github.com/adamelfsborg-code/movie-nest/shared"
+	"github.com/google/uuid"
+	"golang.org/x/net/websocket"
+)
+
+// GatewayHandler serves the websocket gateway. Browsers connect with a
+// ticket and only from AllowedOrigins, clients that send no Origin, such
+// as native apps, are not restricted.
+type GatewayHandler struct {
+	Gateway        *realtime.Gateway
+	SecretKey      []byte
+	AllowedOrigins []string
+}
+
+const (
+	wsReadTimeout  = time.Second * 90
+	wsWriteTimeout = time.Second * 10
+)
+
+func (g *GatewayHandler) CreateTicket(w http.ResponseWriter, r *http.Request) {
+	idParam := r.Header.Get("X-UserID")
+
+	userID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	ticketString, ticket, err := shared.NewTicket(g.SecretKey, userID)
+	if err != nil {
+		fmt.Println("Failed to create ticket: ", err)
+		http.Error(w, "Failed to create ticket", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"ticket":     ticketString,
+		"expires_at": ticket.ExpiresAt,
+	}
+	jsonResponse, err := json.Marshal(response)
+	if err != nil {
+		fmt.Println("Failed to encode ticket: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(jsonResponse)
+}
+
+func (g *GatewayHandler) Connect(w http.ResponseWriter, r *http.Request) {
+	idParam := r.Header.Get("X-UserID")
+
+	userID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	server := websocket.Server{
+		Handshake: g.checkOrigin,
+		Handler: func(ws *websocket.Conn) {
+			g.serve(ws, userID)
+		},
+	}
+
+	server.ServeHTTP(w, r)
+}
+
+func (g *GatewayHandler) checkOrigin(config *websocket.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+
+	for _, allowed := range g.AllowedOrigins {
+		if strings.EqualFold(origin, allowed) {
+			return nil
+		}
+	}
+
+	fmt.Println("Refusing websocket from origin: ", origin)
+	return fmt.Errorf("Origin %v is not allowed", origin)
+}
+
+func (g *GatewayHandler) serve(ws *websocket.Conn, userID uuid.UUID) {
+	conn := g.Gateway.Connect(userID)
+	defer g.Gateway.Disconnect(conn)
+
+	go func() {
+		defer ws.Close()
+
+		for message := range conn.Send {
+			ws.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			err := websocket.JSON.Send(ws, message)
+			if err != nil {
+				fmt.Println("Failed to write websocket message: ", err)
+				return
+			}
+		}
+	}()
+
+	for {
+		// Clients keep the connection open by sending pings.
+		ws.SetReadDeadline(time.Now().Add(wsReadTimeout))
+
+		var message realtime.ClientMessage
+		err := websocket.JSON.Receive(ws, &message)
+		if err != nil {
+			return
+		}
+
+		g.Gateway.Handle(conn, message)
+	}
+}
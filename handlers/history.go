@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/adamelfsborg-code/movie-nest/events"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type HistoryHandler struct {
+	History events.History
+}
+
+// GetRoomHistory returns the envelopes of the room events published at or
+// after ?since= (RFC 3339), oldest first, up to ?limit= of them. Without
+// since the whole retained history is replayed. When has_more is set the
+// next page is read with ?cursor= set to next_cursor.
+func (h *HistoryHandler) GetRoomHistory(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "room_id")
+
+	roomID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	var since time.Time
+	sinceParam := r.URL.Query().Get("since")
+	if sinceParam != "" {
+		since, err = time.Parse(time.RFC3339, sinceParam)
+		if err != nil {
+			fmt.Println("Failed to parse since: ", err)
+			http.Error(w, "Failed to parse since", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var after uint64
+	cursorParam := r.URL.Query().Get("cursor")
+	if cursorParam != "" {
+		after, err = strconv.ParseUint(cursorParam, 10, 64)
+		if err != nil {
+			fmt.Println("Failed to parse cursor: ", err)
+			http.Error(w, "Failed to parse cursor", http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit := events.DefaultHistoryLimit
+	limitParam := r.URL.Query().Get("limit")
+	if limitParam != "" {
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > events.MaxHistoryLimit {
+			fmt.Println("Failed to parse limit: ", limitParam)
+			http.Error(w, "Failed to parse limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := h.History.RoomHistory(r.Context(), roomID, events.HistoryQuery{Since: since, After: after, Limit: limit})
+	if err != nil {
+		fmt.Println("Failed to get room history: ", err)
+		http.Error(w, "Failed to get room history", http.StatusInternalServerError)
+		return
+	}
+
+	envelopes := make([]json.RawMessage, len(page.Events))
+	for i, event := range page.Events {
+		envelopes[i] = event.Data
+	}
+
+	jsonBytes, err := json.Marshal(struct {
+		Events     []json.RawMessage `json:"events"`
+		HasMore    bool              `json:"has_more"`
+		NextCursor string            `json:"next_cursor"`
+	}{
+		Events:     envelopes,
+		HasMore:    page.HasMore,
+		NextCursor: strconv.FormatUint(page.Next, 10),
+	})
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
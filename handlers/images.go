@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/adamelfsborg-code/movie-nest/pkg/images"
+	"github.com/go-chi/chi/v5"
+)
+
+type ImageHandler struct {
+	Images *images.Proxy
+}
+
+// GetImage serves a TMDB poster or backdrop in the requested size, such as
+// /images/w342/abc.jpg. Images never change under the same path, so
+// clients may cache them for a year. They are served from the API origin,
+// so the content type is enforced and SVG logos opened directly run
+// sandboxed, without scripts.
+func (h *ImageHandler) GetImage(w http.ResponseWriter, r *http.Request) {
+	size := chi.URLParam(r, "size")
+	name := chi.URLParam(r, "path")
+
+	image, err := h.Images.Get(r.Context(), size, name)
+	if err != nil {
+		fmt.Println("Failed to get image: ", err)
+		status := movieDBStatus(err, http.StatusInternalServerError)
+		if errors.Is(err, images.ErrInvalidSize) || errors.Is(err, images.ErrInvalidPath) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, "Failed to get image", status)
+		return
+	}
+
+	w.Header().Set("Content-Type", image.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", image.ETag)
+
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(image.Data))
+}
@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/adamelfsborg-code/movie-nest/data"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type InvitationHandler struct {
+	Data data.InvitationStore
+}
+
+func (i *InvitationHandler) InviteUser(w http.ResponseWriter, r *http.Request) {
+	idParam := r.Header.Get("X-UserID")
+
+	userID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		RoomID uuid.UUID `json:"room_id"`
+		UserID uuid.UUID `json:"user_id"`
+		Role   string    `json:"role"`
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	role, err := data.NewRole(body.Role)
+	if err != nil {
+		fmt.Println("Failed to invite user: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	invitation := data.NewRoomInvitation(body.RoomID, body.UserID, role)
+	created, err := i.Data.InviteUser(*invitation, userID)
+	if err != nil {
+		fmt.Println("Failed to invite user: ", err)
+		http.Error(w, storeMessage(err, "Failed to invite user"), storeStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(created)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(jsonBytes)
+}
+
+func (i *InvitationHandler) GetInvitationsByRoomID(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "room_id")
+
+	roomID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	invitations := i.Data.GetInvitationsByRoomID(roomID)
+
+	jsonBytes, err := json.Marshal(invitations)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (i *InvitationHandler) CancelInvitation(w http.ResponseWriter, r *http.Request) {
+	idParam := r.Header.Get("X-UserID")
+
+	actorID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	idParam = chi.URLParam(r, "room_id")
+
+	roomID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	idParam = chi.URLParam(r, "invitation_id")
+
+	invitationID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	err = i.Data.CancelInvitation(roomID, invitationID, actorID)
+	if err != nil {
+		fmt.Println("Failed to cancel invitation: ", err)
+		http.Error(w, storeMessage(err, "Failed to cancel invitation"), storeStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(map[string]string{"message": "Invitation cancelled"})
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (i *InvitationHandler) GetUserInvitations(w http.ResponseWriter, r *http.Request) {
+	idParam := r.Header.Get("X-UserID")
+
+	userID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	invitations := i.Data.GetInvitationsByUserID(userID)
+
+	jsonBytes, err := json.Marshal(invitations)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (i *InvitationHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	idParam := r.Header.Get("X-UserID")
+
+	userID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	idParam = chi.URLParam(r, "invitation_id")
+
+	invitationID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	room, err := i.Data.AcceptInvitation(invitationID, userID)
+	if err != nil {
+		fmt.Println("Failed to accept invitation: ", err)
+		http.Error(w, storeMessage(err, "Failed to accept invitation"), storeStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(room)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (i *InvitationHandler) DeclineInvitation(w http.ResponseWriter, r *http.Request) {
+	idParam := r.Header.Get("X-UserID")
+
+	userID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	idParam = chi.URLParam(r, "invitation_id")
+
+	invitationID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Block bool `json:"block"`
+	}
+
+	if r.ContentLength != 0 {
+		err = json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			fmt.Println("Failed to decode json: ", err)
+			http.Error(w, "Failed to decode json", http.StatusBadRequest)
+			return
+		}
+	}
+
+	err = i.Data.DeclineInvitation(invitationID, userID, body.Block)
+	if err != nil {
+		fmt.Println("Failed to decline invitation: ", err)
+		http.Error(w, storeMessage(err, "Failed to decline invitation"), storeStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(map[string]string{"message": "Invitation declined"})
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (i *InvitationHandler) GetBlockedUsers(w http.ResponseWriter, r *http.Request) {
+	idParam := r.Header.Get("X-UserID")
+
+	userID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	users := i.Data.GetBlockedUsers(userID)
+
+	jsonBytes, err := json.Marshal(users)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (i *InvitationHandler) UnblockUser(w http.ResponseWriter, r *http.Request) {
+	idParam := r.Header.Get("X-UserID")
+
+	userID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	idParam = chi.URLParam(r, "user_id")
+
+	blockedUserID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	err = i.Data.UnblockUser(userID, blockedUserID)
+	if err != nil {
+		fmt.Println("Failed to unblock user: ", err)
+		http.Error(w, "Failed to unblock user", storeStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(map[string]string{"message": "User unblocked"})
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/adamelfsborg-code/movie-nest/data"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type InviteHandler struct {
+	Data data.InviteStore
+}
+
+func (i *InviteHandler) CreateInvite(w http.ResponseWriter, r *http.Request) {
+	idParam := r.Header.Get("X-UserID")
+
+	actorID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	idParam = chi.URLParam(r, "room_id")
+
+	roomID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Role      string     `json:"role"`
+		ExpiresAt *time.Time `json:"expires_at"`
+		MaxUses   *int       `json:"max_uses"`
+	}
+
+	if r.ContentLength != 0 {
+		err = json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			fmt.Println("Failed to decode json: ", err)
+			http.Error(w, "Failed to decode json", http.StatusBadRequest)
+			return
+		}
+	}
+
+	role, err := data.NewRole(body.Role)
+	if err != nil {
+		fmt.Println("Failed to create invite: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	invite, err := data.NewRoomInvite(roomID, role, body.ExpiresAt, body.MaxUses)
+	if err != nil {
+		fmt.Println("Failed to create invite: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	created, err := i.Data.CreateInvite(*invite, actorID)
+	if err != nil {
+		fmt.Println("Failed to create invite: ", err)
+		http.Error(w, "Failed to create invite", storeStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(created)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(jsonBytes)
+}
+
+func (i *InviteHandler) GetInvitesByRoomID(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "room_id")
+
+	roomID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	invites := i.Data.GetInvitesByRoomID(roomID)
+
+	jsonBytes, err := json.Marshal(invites)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (i *InviteHandler) RevokeInvite(w http.ResponseWriter, r *http.Request) {
+	idParam := r.Header.Get("X-UserID")
+
+	actorID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	idParam = chi.URLParam(r, "room_id")
+
+	roomID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	idParam = chi.URLParam(r, "invite_id")
+
+	inviteID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	err = i.Data.RevokeInvite(roomID, inviteID, actorID)
+	if err != nil {
+		fmt.Println("Failed to revoke invite: ", err)
+		http.Error(w, "Failed to revoke invite", storeStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(map[string]string{"message": "Invite revoked"})
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (i *InviteHandler) RedeemInvite(w http.ResponseWriter, r *http.Request) {
+	idParam := r.Header.Get("X-UserID")
+
+	userID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	code := chi.URLParam(r, "code")
+
+	room, err := i.Data.RedeemInvite(code, userID)
+	if err != nil {
+		fmt.Println("Failed to redeem invite: ", err)
+		http.Error(w, storeMessage(err, "Failed to redeem invite"), storeStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(room)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
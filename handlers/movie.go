@@ -12,27 +12,44 @@ import (
 )
 
 type MovieHandler struct {
-	Data data.MovieData
+	Data data.MovieStore
 }
 
 func (m *MovieHandler) CreateMovie(w http.ResponseWriter, r *http.Request) {
+	idParam := r.Header.Get("X-UserID")
+
+	userID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
 	var body struct {
-		MovieID uint      `json:"movie_id"`
-		ShelfID uuid.UUID `json:"shelf_id"`
+		MediaType    string    `json:"media_type"`
+		MovieID      uint      `json:"movie_id"`
+		SeasonNumber *uint     `json:"season_number"`
+		ShelfID      uuid.UUID `json:"shelf_id"`
 	}
 
-	err := json.NewDecoder(r.Body).Decode(&body)
+	err = json.NewDecoder(r.Body).Decode(&body)
 	if err != nil {
 		fmt.Println("Failed to decode json: ", err)
 		http.Error(w, "Failed to decode json", http.StatusBadRequest)
 		return
 	}
 
-	movie := data.NewMovie(body.MovieID, body.ShelfID)
-	err = m.Data.CreateMovie(*movie)
+	movie, err := data.NewMediaItem(body.MediaType, body.MovieID, body.SeasonNumber, body.ShelfID)
 	if err != nil {
 		fmt.Println("Failed to create movie: ", err)
-		http.Error(w, "Failed to create movie", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = m.Data.CreateMovie(*movie, userID)
+	if err != nil {
+		fmt.Println("Failed to create movie: ", err)
+		http.Error(w, storeMessage(err, "Failed to create movie"), storeStatus(err, http.StatusInternalServerError))
 		return
 	}
 
@@ -58,10 +75,19 @@ func (m *MovieHandler) GetMovie(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	movie, err := m.Data.GetMovie(uint(movieID))
+	idParam = r.Header.Get("X-UserID")
+
+	userID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	movie, err := m.Data.GetMovie(r.Context(), uint(movieID), userID)
 	if err != nil {
 		fmt.Println("Failed to get movie: ", err)
-		http.Error(w, "Failed to get movie", http.StatusBadRequest)
+		http.Error(w, "Failed to get movie", movieDBStatus(err, http.StatusBadRequest))
 		return
 	}
 
@@ -78,6 +104,75 @@ func (m *MovieHandler) GetMovie(w http.ResponseWriter, r *http.Request) {
 	w.Write(jsonBytes)
 }
 
+func (m *MovieHandler) GetTV(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "tv_id")
+
+	tvID, err := strconv.ParseUint(idParam, 10, 64)
+	if err != nil {
+		fmt.Println("Failed to convert param to uint: ", err)
+		http.Error(w, "Failed to convert param to uint", http.StatusBadRequest)
+		return
+	}
+
+	idParam = r.Header.Get("X-UserID")
+
+	userID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	tv, err := m.Data.GetTV(r.Context(), uint(tvID), userID)
+	if err != nil {
+		fmt.Println("Failed to get tv series: ", err)
+		http.Error(w, "Failed to get tv series", movieDBStatus(err, http.StatusBadRequest))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(tv)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (m *MovieHandler) FindByIMDbID(w http.ResponseWriter, r *http.Request) {
+	imdbID := chi.URLParam(r, "imdb_id")
+
+	idParam := r.Header.Get("X-UserID")
+
+	userID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	movie, err := m.Data.FindByIMDbID(r.Context(), imdbID, userID)
+	if err != nil {
+		fmt.Println("Failed to find movie: ", err)
+		http.Error(w, "Failed to find movie", movieDBStatus(err, http.StatusBadRequest))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(movie)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
 func (m *MovieHandler) GetMovieDetails(w http.ResponseWriter, r *http.Request) {
 	idParam := chi.URLParam(r, "movie_id")
 
@@ -89,10 +184,19 @@ func (m *MovieHandler) GetMovieDetails(w http.ResponseWriter, r *http.Request) {
 
 	}
 
-	movie, err := m.Data.GetMovieDetails(movieID)
+	idParam = r.Header.Get("X-UserID")
+
+	userID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	movie, err := m.Data.GetMovieDetails(r.Context(), movieID, userID)
 	if err != nil {
 		fmt.Println("Failed to get movie: ", err)
-		http.Error(w, "Failed to get movie", http.StatusInternalServerError)
+		http.Error(w, "Failed to get movie", movieDBStatus(err, http.StatusInternalServerError))
 		return
 	}
 
@@ -107,6 +211,7 @@ func (m *MovieHandler) GetMovieDetails(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write(jsonBytes)
 }
+
 func (m *MovieHandler) RateMovie(w http.ResponseWriter, r *http.Request) {
 	var body struct {
 		Rating float64 `json:"rating"`
@@ -147,7 +252,7 @@ func (m *MovieHandler) RateMovie(w http.ResponseWriter, r *http.Request) {
 	err = m.Data.RateMovie(*movieRating)
 	if err != nil {
 		fmt.Println("Failed to rate movie: ", err)
-		http.Error(w, "Failed to rate movie", http.StatusInternalServerError)
+		http.Error(w, storeMessage(err, "Failed to rate movie"), storeStatus(err, http.StatusInternalServerError))
 		return
 
 	}
@@ -163,3 +268,105 @@ func (m *MovieHandler) RateMovie(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusCreated)
 	w.Write(jsonBytes)
 }
+
+func (m *MovieHandler) RemoveMovie(w http.ResponseWriter, r *http.Request) {
+	idParam := r.Header.Get("X-UserID")
+
+	userID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	idParam = chi.URLParam(r, "movie_id")
+
+	movieID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	err = m.Data.RemoveMovie(movieID, userID)
+	if err != nil {
+		fmt.Println("Failed to remove movie: ", err)
+		http.Error(w, storeMessage(err, "Failed to remove movie"), storeStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(map[string]string{"message": "Movie removed"})
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+// MoveMovie moves the movie to the shelf in a body like {"shelf_id": "..."}.
+func (m *MovieHandler) MoveMovie(w http.ResponseWriter, r *http.Request) {
+	m.placeMovie(w, r, false)
+}
+
+// CopyMovie copies the movie to the shelf in a body like {"shelf_id": "..."}.
+func (m *MovieHandler) CopyMovie(w http.ResponseWriter, r *http.Request) {
+	m.placeMovie(w, r, true)
+}
+
+func (m *MovieHandler) placeMovie(w http.ResponseWriter, r *http.Request, keepOriginal bool) {
+	idParam := r.Header.Get("X-UserID")
+
+	userID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	idParam = chi.URLParam(r, "movie_id")
+
+	movieID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		ShelfID uuid.UUID `json:"shelf_id"`
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	move, status := m.Data.MoveMovie, http.StatusOK
+	if keepOriginal {
+		move, status = m.Data.CopyMovie, http.StatusCreated
+	}
+
+	movie, err := move(movieID, body.ShelfID, userID)
+	if err != nil {
+		fmt.Println("Failed to place movie: ", err)
+		http.Error(w, storeMessage(err, "Failed to place movie"), storeStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(movie)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(jsonBytes)
+}
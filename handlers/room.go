@@ -12,7 +12,7 @@ import (
 )
 
 type RoomHandler struct {
-	Data data.RoomData
+	Data data.RoomStore
 }
 
 func (u *RoomHandler) SelectRooms(w http.ResponseWriter, r *http.Request) {
@@ -72,7 +72,16 @@ func (u *RoomHandler) GetRoomInfoByID(w http.ResponseWriter, r *http.Request) {
 
 	}
 
-	room, err := u.Data.GetRoomInfoByID(roomID)
+	idParam = r.Header.Get("X-UserID")
+
+	userID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	room, err := u.Data.GetRoomInfoByID(roomID, userID)
 	if err != nil {
 		fmt.Println("Failed to get user: ", err)
 		http.Error(w, "Failed to get user", http.StatusBadRequest)
@@ -186,28 +195,153 @@ func (u *RoomHandler) CreateRoom(w http.ResponseWriter, r *http.Request) {
 	w.Write(jsonBytes)
 }
 
-func (u *RoomHandler) AddUserToRoom(w http.ResponseWriter, r *http.Request) {
+func (u *RoomHandler) ListRoomsWithUsers(w http.ResponseWriter, r *http.Request) {
+	rooms := u.Data.ListRoomsWithUsers()
+
+	jsonBytes, err := json.Marshal(rooms)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (u *RoomHandler) GetRoomWithUsersByID(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "room_id")
+
+	roomID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	room := u.Data.GetRoomWithUsersByID(roomID)
+
+	jsonBytes, err := json.Marshal(room)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (u *RoomHandler) GetUserRoomsByID(w http.ResponseWriter, r *http.Request) {
+	idParam := r.Header.Get("X-UserID")
+
+	userID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	rooms := u.Data.GetUserRoomsByID(userID)
+
+	jsonBytes, err := json.Marshal(rooms)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (u *RoomHandler) GetRoomAccess(w http.ResponseWriter, r *http.Request) {
+	idParam := r.Header.Get("X-UserID")
+
+	userID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	idParam = chi.URLParam(r, "room_id")
+
+	roomID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	role, err := u.Data.GetRoomRole(roomID, userID)
+	if err != nil {
+		fmt.Println("Failed to get access: ", err)
+		http.Error(w, "Failed to get access", http.StatusBadRequest)
+		return
+	}
+
+	jsonBytes, err := json.Marshal(role.Can(data.PermissionView))
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (u *RoomHandler) SetRoomLocale(w http.ResponseWriter, r *http.Request) {
+	idParam := r.Header.Get("X-UserID")
+
+	actorID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	idParam = chi.URLParam(r, "room_id")
+
+	roomID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
 	var body struct {
-		RoomID uuid.UUID `json:"room_id"`
-		UserID uuid.UUID `json:"user_id"`
+		Language string `json:"language"`
+		Region   string `json:"region"`
 	}
 
-	err := json.NewDecoder(r.Body).Decode(&body)
+	err = json.NewDecoder(r.Body).Decode(&body)
 	if err != nil {
 		fmt.Println("Failed to decode json: ", err)
 		http.Error(w, "Failed to decode json", http.StatusBadRequest)
 		return
 	}
 
-	room := data.NewRoomUser(body.RoomID, body.UserID)
-	err = u.Data.AddUserToRoom(*room)
+	update, err := data.NewRoomUpdate(nil, &body.Language, &body.Region, nil)
 	if err != nil {
-		fmt.Println("Failed to add user to room: ", err)
-		http.Error(w, "Failed to add user to room", http.StatusInternalServerError)
+		fmt.Println("Failed to set locale: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	jsonBytes, err := json.Marshal(map[string]string{"message": "User added to room"})
+	_, err = u.Data.UpdateRoom(roomID, *update, actorID)
+	if err != nil {
+		fmt.Println("Failed to set locale: ", err)
+		http.Error(w, storeMessage(err, "Failed to set locale"), storeStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(map[string]string{"message": "Locale updated"})
 	if err != nil {
 		fmt.Println("Failed to decode json: ", err)
 		http.Error(w, "Failed to decode json", http.StatusBadRequest)
@@ -215,14 +349,55 @@ func (u *RoomHandler) AddUserToRoom(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Add("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(http.StatusOK)
 	w.Write(jsonBytes)
 }
 
-func (u *RoomHandler) ListRoomsWithUsers(w http.ResponseWriter, r *http.Request) {
-	rooms := u.Data.ListRoomsWithUsers()
+func (u *RoomHandler) SetRoomSubscriptions(w http.ResponseWriter, r *http.Request) {
+	idParam := r.Header.Get("X-UserID")
 
-	jsonBytes, err := json.Marshal(rooms)
+	actorID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	idParam = chi.URLParam(r, "room_id")
+
+	roomID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		ProviderIDs []uint `json:"provider_ids"`
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	update, err := data.NewRoomUpdate(nil, nil, nil, &body.ProviderIDs)
+	if err != nil {
+		fmt.Println("Failed to set subscriptions: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	_, err = u.Data.UpdateRoom(roomID, *update, actorID)
+	if err != nil {
+		fmt.Println("Failed to set subscriptions: ", err)
+		http.Error(w, storeMessage(err, "Failed to set subscriptions"), storeStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(map[string]string{"message": "Subscriptions updated"})
 	if err != nil {
 		fmt.Println("Failed to decode json: ", err)
 		http.Error(w, "Failed to decode json", http.StatusBadRequest)
@@ -234,8 +409,18 @@ func (u *RoomHandler) ListRoomsWithUsers(w http.ResponseWriter, r *http.Request)
 	w.Write(jsonBytes)
 }
 
-func (u *RoomHandler) GetRoomWithUsersByID(w http.ResponseWriter, r *http.Request) {
-	idParam := chi.URLParam(r, "room_id")
+// GetRoomRole returns the caller's role in the room and what it allows.
+func (u *RoomHandler) GetRoomRole(w http.ResponseWriter, r *http.Request) {
+	idParam := r.Header.Get("X-UserID")
+
+	userID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	idParam = chi.URLParam(r, "room_id")
 
 	roomID, err := uuid.Parse(idParam)
 	if err != nil {
@@ -244,7 +429,154 @@ func (u *RoomHandler) GetRoomWithUsersByID(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	room := u.Data.GetRoomWithUsersByID(roomID)
+	role, err := u.Data.GetRoomRole(roomID, userID)
+	if err != nil {
+		fmt.Println("Failed to get role: ", err)
+		http.Error(w, "Failed to get role", http.StatusInternalServerError)
+		return
+	}
+
+	jsonBytes, err := json.Marshal(data.NewRoomRole(role))
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (u *RoomHandler) SetRoomUserRole(w http.ResponseWriter, r *http.Request) {
+	idParam := r.Header.Get("X-UserID")
+
+	actorID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	idParam = chi.URLParam(r, "room_id")
+
+	roomID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	idParam = chi.URLParam(r, "user_id")
+
+	userID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Role string `json:"role"`
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	role, err := data.NewRole(body.Role)
+	if err != nil {
+		fmt.Println("Failed to set role: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = u.Data.SetRoomUserRole(roomID, userID, role, actorID)
+	if err != nil {
+		fmt.Println("Failed to set role: ", err)
+		http.Error(w, storeMessage(err, "Failed to set role"), storeStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(map[string]string{"message": "Role updated"})
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+// UpdateRoom renames the room and changes its settings. PUT replaces the
+// name and settings, PATCH only changes the fields in the body.
+func (u *RoomHandler) UpdateRoom(w http.ResponseWriter, r *http.Request) {
+	idParam := r.Header.Get("X-UserID")
+
+	actorID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	idParam = chi.URLParam(r, "room_id")
+
+	roomID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Name          *string `json:"name"`
+		Language      *string `json:"language"`
+		Region        *string `json:"region"`
+		Subscriptions *[]uint `json:"subscriptions"`
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		if body.Name == nil {
+			http.Error(w, "name is required", http.StatusBadRequest)
+			return
+		}
+		if body.Language == nil {
+			body.Language = new(string)
+		}
+		if body.Region == nil {
+			body.Region = new(string)
+		}
+		if body.Subscriptions == nil {
+			body.Subscriptions = &[]uint{}
+		}
+	}
+
+	update, err := data.NewRoomUpdate(body.Name, body.Language, body.Region, body.Subscriptions)
+	if err != nil {
+		fmt.Println("Failed to update room: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	room, err := u.Data.UpdateRoom(roomID, *update, actorID)
+	if err != nil {
+		fmt.Println("Failed to update room: ", err)
+		http.Error(w, storeMessage(err, "Failed to update room"), storeStatus(err, http.StatusInternalServerError))
+		return
+	}
 
 	jsonBytes, err := json.Marshal(room)
 	if err != nil {
@@ -258,7 +590,53 @@ func (u *RoomHandler) GetRoomWithUsersByID(w http.ResponseWriter, r *http.Reques
 	w.Write(jsonBytes)
 }
 
-func (u *RoomHandler) GetUserRoomsByID(w http.ResponseWriter, r *http.Request) {
+func (u *RoomHandler) ArchiveRoom(w http.ResponseWriter, r *http.Request) {
+	u.setRoomArchived(w, r, true)
+}
+
+func (u *RoomHandler) UnarchiveRoom(w http.ResponseWriter, r *http.Request) {
+	u.setRoomArchived(w, r, false)
+}
+
+func (u *RoomHandler) setRoomArchived(w http.ResponseWriter, r *http.Request, archived bool) {
+	idParam := r.Header.Get("X-UserID")
+
+	actorID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	idParam = chi.URLParam(r, "room_id")
+
+	roomID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	room, err := u.Data.SetRoomArchived(roomID, archived, actorID)
+	if err != nil {
+		fmt.Println("Failed to archive room: ", err)
+		http.Error(w, storeMessage(err, "Failed to archive room"), storeStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(room)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (u *RoomHandler) LeaveRoom(w http.ResponseWriter, r *http.Request) {
 	idParam := r.Header.Get("X-UserID")
 
 	userID, err := uuid.Parse(idParam)
@@ -268,9 +646,23 @@ func (u *RoomHandler) GetUserRoomsByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rooms := u.Data.GetUserRoomsByID(userID)
+	idParam = chi.URLParam(r, "room_id")
 
-	jsonBytes, err := json.Marshal(rooms)
+	roomID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	err = u.Data.LeaveRoom(roomID, userID)
+	if err != nil {
+		fmt.Println("Failed to leave room: ", err)
+		http.Error(w, storeMessage(err, "Failed to leave room"), storeStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(map[string]string{"message": "Left room"})
 	if err != nil {
 		fmt.Println("Failed to decode json: ", err)
 		http.Error(w, "Failed to decode json", http.StatusBadRequest)
@@ -282,9 +674,27 @@ func (u *RoomHandler) GetUserRoomsByID(w http.ResponseWriter, r *http.Request) {
 	w.Write(jsonBytes)
 }
 
-func (u *RoomHandler) GetRoomAccess(w http.ResponseWriter, r *http.Request) {
+func (u *RoomHandler) RemoveRoomUser(w http.ResponseWriter, r *http.Request) {
 	idParam := r.Header.Get("X-UserID")
 
+	actorID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	idParam = chi.URLParam(r, "room_id")
+
+	roomID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	idParam = chi.URLParam(r, "user_id")
+
 	userID, err := uuid.Parse(idParam)
 	if err != nil {
 		fmt.Println("Failed to parse id: ", err)
@@ -292,6 +702,35 @@ func (u *RoomHandler) GetRoomAccess(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = u.Data.RemoveRoomUser(roomID, userID, actorID)
+	if err != nil {
+		fmt.Println("Failed to remove user: ", err)
+		http.Error(w, storeMessage(err, "Failed to remove user"), storeStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(map[string]string{"message": "User removed"})
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (u *RoomHandler) TransferRoomOwnership(w http.ResponseWriter, r *http.Request) {
+	idParam := r.Header.Get("X-UserID")
+
+	actorID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
 	idParam = chi.URLParam(r, "room_id")
 
 	roomID, err := uuid.Parse(idParam)
@@ -301,14 +740,63 @@ func (u *RoomHandler) GetRoomAccess(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	access, err := u.Data.GetRoomAccess(roomID, userID)
+	var body struct {
+		UserID uuid.UUID `json:"user_id"`
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&body)
 	if err != nil {
-		fmt.Println("Failed to get access: ", err)
-		http.Error(w, "Failed to get access", http.StatusBadRequest)
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	err = u.Data.TransferRoomOwnership(roomID, body.UserID, actorID)
+	if err != nil {
+		fmt.Println("Failed to transfer room: ", err)
+		http.Error(w, storeMessage(err, "Failed to transfer room"), storeStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(map[string]string{"message": "Ownership transferred"})
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (u *RoomHandler) DeleteRoom(w http.ResponseWriter, r *http.Request) {
+	idParam := r.Header.Get("X-UserID")
+
+	actorID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	idParam = chi.URLParam(r, "room_id")
+
+	roomID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	err = u.Data.DeleteRoom(roomID, actorID)
+	if err != nil {
+		fmt.Println("Failed to delete room: ", err)
+		http.Error(w, storeMessage(err, "Failed to delete room"), storeStatus(err, http.StatusInternalServerError))
 		return
 	}
 
-	jsonBytes, err := json.Marshal(access)
+	jsonBytes, err := json.Marshal(map[string]string{"message": "Room deleted"})
 	if err != nil {
 		fmt.Println("Failed to decode json: ", err)
 		http.Error(w, "Failed to decode json", http.StatusBadRequest)
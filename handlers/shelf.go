@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/adamelfsborg-code/movie-nest/data"
 	"github.com/go-chi/chi/v5"
@@ -12,16 +14,25 @@ import (
 )
 
 type ShelfHandler struct {
-	Data data.ShelfData
+	Data data.ShelfStore
 }
 
 func (s *ShelfHandler) CreateShelf(w http.ResponseWriter, r *http.Request) {
+	idParam := r.Header.Get("X-UserID")
+
+	userID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
 	var body struct {
 		Name   string    `json:"name"`
 		RoomID uuid.UUID `json:"room_id"`
 	}
 
-	err := json.NewDecoder(r.Body).Decode(&body)
+	err = json.NewDecoder(r.Body).Decode(&body)
 	if err != nil {
 		fmt.Println("Failed to decode json: ", err)
 		http.Error(w, "Failed to decode json", http.StatusBadRequest)
@@ -29,10 +40,10 @@ func (s *ShelfHandler) CreateShelf(w http.ResponseWriter, r *http.Request) {
 	}
 
 	shelf := data.NewShelf(body.Name, body.RoomID)
-	err = s.Data.CreateShelf(*shelf)
+	err = s.Data.CreateShelf(*shelf, userID)
 	if err != nil {
 		fmt.Println("Failed to create shelf: ", err)
-		http.Error(w, "Failed to create shelf", http.StatusInternalServerError)
+		http.Error(w, storeMessage(err, "Failed to create shelf"), storeStatus(err, http.StatusInternalServerError))
 		return
 	}
 
@@ -87,7 +98,27 @@ func (s *ShelfHandler) GetShelfMoviesByID(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	shelf := s.Data.GetShelfMoviesByID(shelfID)
+	idParam = r.Header.Get("X-UserID")
+
+	userID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	streamable := false
+	streamableParam := r.URL.Query().Get("streamable")
+	if streamableParam != "" {
+		streamable, err = strconv.ParseBool(streamableParam)
+		if err != nil {
+			fmt.Println("Failed to parse streamable: ", err)
+			http.Error(w, "Failed to parse streamable", http.StatusBadRequest)
+			return
+		}
+	}
+
+	shelf := s.Data.GetShelfMoviesByID(shelfID, userID, streamable)
 	if err != nil {
 		fmt.Println("Failed to get shelf: ", err)
 		http.Error(w, "Failed to get shelf", http.StatusInternalServerError)
@@ -146,7 +177,13 @@ func (s *ShelfHandler) GetAvailableMovies(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	searchTerm := r.URL.Query().Get("searchTerm")
+	search, err := parseMovieSearch(r.URL.Query())
+	if err != nil {
+		fmt.Println("Failed to parse search: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	excludeExistingParam := r.URL.Query().Get("excludeExisting")
 
 	excludeExisting, err := strconv.ParseBool(excludeExistingParam)
@@ -156,10 +193,19 @@ func (s *ShelfHandler) GetAvailableMovies(w http.ResponseWriter, r *http.Request
 		excludeExisting = true
 	}
 
-	availableMovies, err := s.Data.GetAvailableMovies(shelfID, searchTerm, excludeExisting)
+	idParam = r.Header.Get("X-UserID")
+
+	userID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	availableMovies, err := s.Data.GetAvailableMovies(r.Context(), shelfID, userID, search, excludeExisting)
 	if err != nil {
 		fmt.Println("Failed to search movies: ", err)
-		http.Error(w, "Failed to search movies", http.StatusBadRequest)
+		http.Error(w, storeMessage(err, "Failed to search movies"), movieDBStatus(err, storeStatus(err, http.StatusInternalServerError)))
 		return
 	}
 
@@ -174,3 +220,208 @@ func (s *ShelfHandler) GetAvailableMovies(w http.ResponseWriter, r *http.Request
 	w.WriteHeader(http.StatusOK)
 	w.Write(jsonBytes)
 }
+
+func (s *ShelfHandler) RenameShelf(w http.ResponseWriter, r *http.Request) {
+	idParam := r.Header.Get("X-UserID")
+
+	userID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	idParam = chi.URLParam(r, "shelf_id")
+
+	shelfID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Name string `json:"name"`
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	name, err := data.NewShelfName(body.Name)
+	if err != nil {
+		fmt.Println("Failed to rename shelf: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	shelf, err := s.Data.RenameShelf(shelfID, name, userID)
+	if err != nil {
+		fmt.Println("Failed to rename shelf: ", err)
+		http.Error(w, storeMessage(err, "Failed to rename shelf"), storeStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(shelf)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+// ReorderShelves takes every shelf of the room in the new order, like
+// {"shelf_ids": ["...", "..."]}.
+func (s *ShelfHandler) ReorderShelves(w http.ResponseWriter, r *http.Request) {
+	idParam := r.Header.Get("X-UserID")
+
+	userID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	idParam = chi.URLParam(r, "room_id")
+
+	roomID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		ShelfIDs []uuid.UUID `json:"shelf_ids"`
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	shelves, err := s.Data.ReorderShelves(roomID, body.ShelfIDs, userID)
+	if err != nil {
+		fmt.Println("Failed to reorder shelves: ", err)
+		http.Error(w, storeMessage(err, "Failed to reorder shelves"), storeStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(shelves)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (s *ShelfHandler) DeleteShelf(w http.ResponseWriter, r *http.Request) {
+	idParam := r.Header.Get("X-UserID")
+
+	userID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	idParam = chi.URLParam(r, "shelf_id")
+
+	shelfID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	err = s.Data.DeleteShelf(shelfID, userID)
+	if err != nil {
+		fmt.Println("Failed to delete shelf: ", err)
+		http.Error(w, storeMessage(err, "Failed to delete shelf"), storeStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(map[string]string{"message": "Shelf deleted"})
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+// parseMovieSearch reads the search and discover filters of
+// available-movies. Numbers that don't parse are rejected rather than
+// ignored, so a typo doesn't silently widen the search.
+func parseMovieSearch(query url.Values) (data.MovieSearch, error) {
+	search := data.MovieSearch{
+		SearchTerm: query.Get("searchTerm"),
+		MediaType:  query.Get("mediaType"),
+		Language:   query.Get("language"),
+		Region:     query.Get("region"),
+		SortBy:     query.Get("sortBy"),
+	}
+
+	flags := map[string]*bool{
+		"discover":     &search.Discover,
+		"includeAdult": &search.IncludeAdult,
+	}
+	for name, flag := range flags {
+		if value := query.Get(name); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return search, fmt.Errorf("Invalid %v", name)
+			}
+			*flag = parsed
+		}
+	}
+
+	numbers := map[string]*uint{
+		"page":         &search.Page,
+		"year":         &search.Year,
+		"yearFrom":     &search.YearFrom,
+		"yearTo":       &search.YearTo,
+		"minVoteCount": &search.MinVoteCount,
+	}
+	for name, number := range numbers {
+		if value := query.Get(name); value != "" {
+			parsed, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				return search, fmt.Errorf("Invalid %v", name)
+			}
+			*number = uint(parsed)
+		}
+	}
+
+	if search.Page > 500 {
+		return search, fmt.Errorf("Invalid page, TMDB serves pages 1 to 500")
+	}
+
+	if value := query.Get("genres"); value != "" {
+		for _, genre := range strings.Split(value, ",") {
+			parsed, err := strconv.ParseUint(strings.TrimSpace(genre), 10, 32)
+			if err != nil {
+				return search, fmt.Errorf("Invalid genres")
+			}
+			search.Genres = append(search.Genres, uint(parsed))
+		}
+	}
+
+	return search, nil
+}
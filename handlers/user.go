@@ -5,14 +5,16 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/adamelfsborg-code/movie-nest/data"
+	"github.com/adamelfsborg-code/movie-nest/shared"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 )
 
 type UserHandler struct {
-	Data data.UserData
+	Data data.UserStore
 }
 
 func (u *UserHandler) SelectUsers(w http.ResponseWriter, r *http.Request) {
@@ -97,6 +99,16 @@ func (u *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	http.SetCookie(w, &http.Cookie{
+		Name:     shared.TokenCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  time.Now().Add(time.Hour * 24),
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
 	w.Header().Add("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(jsonResponse)
@@ -202,3 +214,51 @@ func (u *UserHandler) GetUsersInRoom(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write(jsonBytes)
 }
+
+func (u *UserHandler) SetUserLocale(w http.ResponseWriter, r *http.Request) {
+	idParam := r.Header.Get("X-UserID")
+
+	userID, err := uuid.Parse(idParam)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Language string `json:"language"`
+		Region   string `json:"region"`
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	locale, err := data.NewLocale(body.Language, body.Region)
+	if err != nil {
+		fmt.Println("Failed to set locale: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = u.Data.SetUserLocale(userID, *locale)
+	if err != nil {
+		fmt.Println("Failed to set locale: ", err)
+		http.Error(w, "Failed to set locale", http.StatusInternalServerError)
+		return
+	}
+
+	jsonBytes, err := json.Marshal(map[string]string{"message": "Locale updated"})
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, "Failed to decode json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
@@ -0,0 +1,226 @@
+package images
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultMaxBytes = 512 << 20
+
+const tempPrefix = ".tmp-"
+
+// Cache keeps images on disk under Dir. When the files take more than
+// MaxBytes the least recently used ones are removed. The access order is
+// kept in the file modification times, so it survives restarts.
+type Cache struct {
+	Dir      string
+	MaxBytes int64
+
+	mu      sync.Mutex
+	size    int64
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	name string
+	size int64
+}
+
+// NewCache creates dir when it is missing and indexes the images already
+// in it.
+func NewCache(dir string, maxBytes int64) (*Cache, error) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("images: create cache dir: %w", err)
+	}
+
+	c := &Cache{
+		Dir:      dir,
+		MaxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+
+	err = c.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *Cache) load() error {
+	dirEntries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return fmt.Errorf("images: read cache dir: %w", err)
+	}
+
+	type file struct {
+		name    string
+		size    int64
+		modTime time.Time
+	}
+
+	var files []file
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() {
+			continue
+		}
+
+		if strings.HasPrefix(dirEntry.Name(), tempPrefix) {
+			os.Remove(filepath.Join(c.Dir, dirEntry.Name()))
+			continue
+		}
+
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+
+		files = append(files, file{name: dirEntry.Name(), size: info.Size(), modTime: info.ModTime()})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.After(files[j].modTime)
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, f := range files {
+		c.entries[f.name] = c.order.PushBack(&cacheEntry{name: f.name, size: f.size})
+		c.size += f.size
+	}
+	c.evict()
+
+	return nil
+}
+
+// Get returns the cached data for key and marks it as recently used.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	name := fileName(key)
+
+	c.mu.Lock()
+	element, ok := c.entries[name]
+	if ok {
+		c.order.MoveToFront(element)
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	path := filepath.Join(c.Dir, name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			fmt.Println("Failed to read cached image: ", err)
+		}
+		c.remove(name)
+		return nil, false
+	}
+
+	now := time.Now()
+	os.Chtimes(path, now, now)
+
+	return data, true
+}
+
+// Put stores data under key and removes the least recently used images
+// until the cache fits in MaxBytes again.
+func (c *Cache) Put(key string, data []byte) error {
+	name := fileName(key)
+
+	tmp, err := os.CreateTemp(c.Dir, tempPrefix+"*")
+	if err != nil {
+		return fmt.Errorf("images: create cache file: %w", err)
+	}
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("images: write cache file: %w", err)
+	}
+
+	err = os.Rename(tmp.Name(), filepath.Join(c.Dir, name))
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("images: write cache file: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[name]; ok {
+		c.size -= element.Value.(*cacheEntry).size
+		c.order.Remove(element)
+	}
+
+	c.entries[name] = c.order.PushFront(&cacheEntry{name: name, size: int64(len(data))})
+	c.size += int64(len(data))
+	c.evict()
+
+	return nil
+}
+
+// Size returns the bytes taken by the cached images.
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+func (c *Cache) remove(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[name]
+	if !ok {
+		return
+	}
+
+	c.size -= element.Value.(*cacheEntry).size
+	c.order.Remove(element)
+	delete(c.entries, name)
+}
+
+// evict expects c.mu to be held.
+func (c *Cache) evict() {
+	for c.size > c.MaxBytes && c.order.Len() > 0 {
+		element := c.order.Back()
+		entry := element.Value.(*cacheEntry)
+
+		err := os.Remove(filepath.Join(c.Dir, entry.name))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			fmt.Println("Failed to evict cached image: ", err)
+		}
+
+		c.size -= entry.size
+		c.order.Remove(element)
+		delete(c.entries, entry.name)
+	}
+}
+
+func fileName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
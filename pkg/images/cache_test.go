@@ -0,0 +1,110 @@
+package images
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache, err := NewCache(t.TempDir(), 10)
+	if err != nil {
+		t.Fatalf("NewCache: %v", err)
+	}
+
+	for _, key := range []string{"a", "b"} {
+		err = cache.Put(key, []byte("01234"))
+		if err != nil {
+			t.Fatalf("Put(%v): %v", key, err)
+		}
+	}
+
+	// a is used again, so b is the least recently used.
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatalf("Get(a) missed")
+	}
+
+	err = cache.Put("c", []byte("01234"))
+	if err != nil {
+		t.Fatalf("Put(c): %v", err)
+	}
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok := cache.Get(key); ok != want {
+			t.Errorf("Get(%v) hit = %v, want %v", key, ok, want)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(cache.Dir, fileName("b"))); !os.IsNotExist(err) {
+		t.Errorf("Evicted file still exists: %v", err)
+	}
+
+	if got := cache.Size(); got != 10 {
+		t.Errorf("Size = %v, want 10", got)
+	}
+}
+
+func TestCacheReplacesEntries(t *testing.T) {
+	cache, err := NewCache(t.TempDir(), 100)
+	if err != nil {
+		t.Fatalf("NewCache: %v", err)
+	}
+
+	cache.Put("a", []byte("0123456789"))
+	cache.Put("a", []byte("01234"))
+
+	data, ok := cache.Get("a")
+	if !ok || string(data) != "01234" {
+		t.Errorf("Get(a) = %q, %v, want the second put", data, ok)
+	}
+	if got := cache.Size(); got != 5 {
+		t.Errorf("Size = %v, want 5", got)
+	}
+}
+
+func TestCacheLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	// The files were used in the order a, b, c before the restart.
+	now := time.Now()
+	for i, key := range []string{"a", "b", "c"} {
+		path := filepath.Join(dir, fileName(key))
+		err := os.WriteFile(path, []byte("01234"), 0o644)
+		if err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+
+		modTime := now.Add(time.Duration(i-3) * time.Hour)
+		err = os.Chtimes(path, modTime, modTime)
+		if err != nil {
+			t.Fatalf("Chtimes: %v", err)
+		}
+	}
+
+	// A put interrupted by the restart leaves a temp file behind.
+	tmp := filepath.Join(dir, tempPrefix+"123")
+	err := os.WriteFile(tmp, []byte("partial"), 0o644)
+	if err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	cache, err := NewCache(dir, 10)
+	if err != nil {
+		t.Fatalf("NewCache: %v", err)
+	}
+
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Errorf("Temp file still exists: %v", err)
+	}
+
+	if got := cache.Size(); got != 10 {
+		t.Errorf("Size = %v, want 10", got)
+	}
+
+	for key, want := range map[string]bool{"a": false, "b": true, "c": true} {
+		if _, ok := cache.Get(key); ok != want {
+			t.Errorf("Get(%v) hit = %v, want %v", key, ok, want)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package images
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adamelfsborg-code/movie-nest/pkg/themoviedb"
+)
+
+const (
+	DefaultBaseURL = "https://image.tmdb.org/t/p"
+	DefaultTimeout = time.Second * 20
+
+	SizeOriginal = "original"
+
+	maxImageBytes = 20 << 20
+)
+
+var (
+	ErrInvalidSize = errors.New("images: invalid size")
+	ErrInvalidPath = errors.New("images: invalid path")
+	ErrNotFound    = errors.New("images: not found")
+	ErrUnavailable = errors.New("images: unavailable")
+)
+
+// DefaultSizes are the widths served by default. Besides TMDB's own sizes
+// they hold the thumbnails used by the UI.
+var DefaultSizes = []int{45, 64, 92, 128, 154, 185, 256, 342, 500, 780, 1280}
+
+// upstreamSizes are the widths TMDB serves for posters and backdrops.
+// Other sizes are resized from the next larger one.
+var upstreamSizes = []int{92, 154, 185, 342, 500, 780, 1280}
+
+var imagePath = regexp.MustCompile(`^[A-Za-z0-9_-]+\.(jpg|jpeg|png|svg)$`)
+
+// Image is a cached image. ETag is derived from the content.
+type Image struct {
+	Data        []byte
+	ContentType string
+	ETag        string
+}
+
+// Proxy fetches TMDB images from BaseURL and keeps them in Cache. Sizes
+// are written like TMDB's, w342 or original.
+type Proxy struct {
+	BaseURL    string
+	Cache      *Cache
+	Sizes      []int
+	HTTPClient *http.Client
+	Metrics    *themoviedb.Metrics
+
+	// Timeout bounds a shared fetch. It does not follow the context of the
+	// request that started it, so one client going away does not fail the
+	// others waiting on the same image.
+	Timeout time.Duration
+
+	mu       sync.Mutex
+	inflight map[string]*call
+}
+
+type call struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+func NewProxy(baseURL string, cache *Cache) *Proxy {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	return &Proxy{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Cache:      cache,
+		Sizes:      DefaultSizes,
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+		Metrics:    themoviedb.DefaultMetrics,
+		Timeout:    DefaultTimeout,
+		inflight:   make(map[string]*call),
+	}
+}
+
+// Get returns the image called name in size. Concurrent requests for the
+// same image share one fetch.
+func (p *Proxy) Get(ctx context.Context, size, name string) (*Image, error) {
+	width, err := p.parseSize(size)
+	if err != nil {
+		return nil, err
+	}
+
+	if !imagePath.MatchString(name) {
+		return nil, ErrInvalidPath
+	}
+
+	data, err := p.get(ctx, width, name)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return &Image{
+		Data:        data,
+		ContentType: contentType(name),
+		ETag:        `"` + hex.EncodeToString(sum[:16]) + `"`,
+	}, nil
+}
+
+func (p *Proxy) parseSize(size string) (int, error) {
+	if size == SizeOriginal {
+		return 0, nil
+	}
+
+	if !strings.HasPrefix(size, "w") {
+		return 0, ErrInvalidSize
+	}
+
+	width, err := strconv.Atoi(size[1:])
+	if err != nil {
+		return 0, ErrInvalidSize
+	}
+
+	for _, allowed := range p.Sizes {
+		if width == allowed {
+			return width, nil
+		}
+	}
+	return 0, ErrInvalidSize
+}
+
+// get returns the image data for width, 0 being the original.
+func (p *Proxy) get(ctx context.Context, width int, name string) ([]byte, error) {
+	key := sizeName(width) + "/" + name
+
+	data, ok := p.Cache.Get(key)
+	if p.Metrics != nil {
+		p.Metrics.ObserveCache("images", ok)
+	}
+	if ok {
+		return data, nil
+	}
+
+	p.mu.Lock()
+	if p.inflight == nil {
+		p.inflight = make(map[string]*call)
+	}
+	c, ok := p.inflight[key]
+	if !ok {
+		c = &call{done: make(chan struct{})}
+		p.inflight[key] = c
+	}
+	p.mu.Unlock()
+
+	if !ok {
+		go p.run(context.WithoutCancel(ctx), c, key, width, name)
+	}
+
+	select {
+	case <-c.done:
+		if c.err != nil {
+			return nil, c.err
+		}
+		return c.data, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// run loads the image for c and caches it.
+func (p *Proxy) run(ctx context.Context, c *call, key string, width int, name string) {
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	data, err := p.load(ctx, width, name)
+	if err == nil {
+		err = p.Cache.Put(key, data)
+		if err != nil {
+			fmt.Println("Failed to cache image: ", err)
+			err = nil
+		}
+	}
+
+	c.data, c.err = data, err
+	close(c.done)
+
+	p.mu.Lock()
+	delete(p.inflight, key)
+	p.mu.Unlock()
+}
+
+// load fetches sizes TMDB serves and resizes the next larger one for the
+// others. Images that cannot be decoded, such as SVG logos, are served in
+// the upstream size.
+func (p *Proxy) load(ctx context.Context, width int, name string) ([]byte, error) {
+	upstream := upstreamSize(width)
+	if upstream == width {
+		return p.fetch(ctx, sizeName(width), name)
+	}
+
+	data, err := p.get(ctx, upstream, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if path.Ext(name) == ".svg" {
+		return data, nil
+	}
+
+	resized, err := resizeData(data, width)
+	if err != nil {
+		fmt.Println("Failed to resize image: ", err)
+		return data, nil
+	}
+
+	return resized, nil
+}
+
+func (p *Proxy) fetch(ctx context.Context, size, name string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%v/%v/%v", p.BaseURL, size, name), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client := p.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(request)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%w: %v %v", ErrUnavailable, resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	if len(data) > maxImageBytes {
+		return nil, fmt.Errorf("%w: image larger than %v bytes", ErrUnavailable, maxImageBytes)
+	}
+
+	return data, nil
+}
+
+// upstreamSize returns the smallest TMDB size at least width wide, 0 for
+// the original.
+func upstreamSize(width int) int {
+	if width == 0 {
+		return 0
+	}
+
+	i := sort.SearchInts(upstreamSizes, width)
+	if i == len(upstreamSizes) {
+		return 0
+	}
+	return upstreamSizes[i]
+}
+
+func sizeName(width int) string {
+	if width == 0 {
+		return SizeOriginal
+	}
+	return fmt.Sprintf("w%v", width)
+}
+
+func contentType(name string) string {
+	switch path.Ext(name) {
+	case ".png":
+		return "image/png"
+	case ".svg":
+		return "image/svg+xml"
+	}
+	return "image/jpeg"
+}
@@ -0,0 +1,66 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProxyLeaderCancelled(t *testing.T) {
+	release := make(chan struct{})
+	var requests atomic.Int32
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release
+		w.Write([]byte("poster"))
+	}))
+	defer upstream.Close()
+
+	cache, err := NewCache(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatalf("NewCache: %v", err)
+	}
+
+	proxy := NewProxy(upstream.URL, cache)
+	proxy.Metrics = nil
+
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error, 1)
+	go func() {
+		_, err := proxy.Get(leaderCtx, "w342", "poster.jpg")
+		leader <- err
+	}()
+
+	for requests.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	waiter := make(chan error, 1)
+	go func() {
+		image, err := proxy.Get(context.Background(), "w342", "poster.jpg")
+		if err == nil && string(image.Data) != "poster" {
+			err = errors.New("unexpected image data " + string(image.Data))
+		}
+		waiter <- err
+	}()
+
+	// The leader's client goes away while the fetch is running.
+	cancel()
+	if err := <-leader; !errors.Is(err, context.Canceled) {
+		t.Errorf("Get of the cancelled leader = %v, want %v", err, context.Canceled)
+	}
+
+	close(release)
+	if err := <-waiter; err != nil {
+		t.Errorf("Get of the waiter = %v, want the image", err)
+	}
+
+	if got := requests.Load(); got != 1 {
+		t.Errorf("Upstream requests = %v, want 1", got)
+	}
+}
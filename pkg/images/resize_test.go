@@ -0,0 +1,69 @@
+package images
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestResize(t *testing.T) {
+	// Four columns, black and white in turns, two rows high.
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 4; x++ {
+			c := color.RGBA{A: 255}
+			if x%2 == 1 {
+				c = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+			}
+			src.Set(x, y, c)
+		}
+	}
+
+	dst := Resize(src, 2)
+	if got := dst.Bounds(); got.Dx() != 2 || got.Dy() != 1 {
+		t.Fatalf("Bounds = %v, want 2x1", got)
+	}
+
+	// Every destination pixel averages one black and one white column.
+	for x := 0; x < 2; x++ {
+		r, g, b, a := dst.At(x, 0).RGBA()
+		if r>>8 != 127 || g>>8 != 127 || b>>8 != 127 || a>>8 != 255 {
+			t.Errorf("Pixel %v = %v %v %v %v, want grey", x, r>>8, g>>8, b>>8, a>>8)
+		}
+	}
+
+	if got := Resize(src, 4); got != image.Image(src) {
+		t.Errorf("Resize to the same width returned a new image")
+	}
+	if got := Resize(src, 8); got != image.Image(src) {
+		t.Errorf("Resize to a larger width returned a new image")
+	}
+}
+
+func TestResizeData(t *testing.T) {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 300, 450)))
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	data, err := resizeData(buf.Bytes(), 200)
+	if err != nil {
+		t.Fatalf("resizeData: %v", err)
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got := img.Bounds(); format != "png" || got.Dx() != 200 || got.Dy() != 300 {
+		t.Errorf("Resized to a %v %v, want a 200x300 png", format, got)
+	}
+
+	_, err = resizeData([]byte("<svg/>"), 200)
+	if err == nil {
+		t.Errorf("resizeData of an svg succeeded, want an error")
+	}
+}
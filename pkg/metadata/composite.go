@@ -0,0 +1,181 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/adamelfsborg-code/movie-nest/pkg/themoviedb"
+)
+
+// Composite asks its providers in order. A lookup falls back to the next
+// provider when one fails or doesn't support it. The title found first is
+// completed with the fields it lacks from the providers after it, matched
+// by IMDb id, and keeps the ratings of all of them.
+type Composite struct {
+	Providers []Provider
+}
+
+func NewComposite(providers ...Provider) *Composite {
+	return &Composite{Providers: providers}
+}
+
+func (c *Composite) Name() string {
+	names := make([]string, len(c.Providers))
+	for i, provider := range c.Providers {
+		names[i] = provider.Name()
+	}
+	return strings.Join(names, "+")
+}
+
+func (c *Composite) Search(ctx context.Context, query Query) (*themoviedb.SearchMovieResp, error) {
+	err := ErrNotSupported
+	for _, provider := range c.Providers {
+		resp, searchErr := provider.Search(ctx, query)
+		if searchErr == nil {
+			return resp, nil
+		}
+
+		err = lastError(err, searchErr)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
+
+func (c *Composite) Get(ctx context.Context, key Key) (*themoviedb.Movie, error) {
+	err := ErrNotSupported
+	for i, provider := range c.Providers {
+		movie, getErr := provider.Get(ctx, key)
+		if getErr == nil {
+			c.complete(ctx, movie, i, key.Language)
+			return movie, nil
+		}
+
+		err = lastError(err, getErr)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
+
+func (c *Composite) FindByExternalID(ctx context.Context, id ExternalID, language string) (*themoviedb.Movie, error) {
+	err := ErrNotSupported
+	for i, provider := range c.Providers {
+		movie, findErr := provider.FindByExternalID(ctx, id, language)
+		if findErr == nil {
+			c.complete(ctx, movie, i, language)
+			return movie, nil
+		}
+
+		err = lastError(err, findErr)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
+
+func (c *Composite) Discover(ctx context.Context, opts themoviedb.DiscoverOptions) (*themoviedb.SearchMovieResp, error) {
+	err := ErrNotSupported
+	for _, provider := range c.Providers {
+		discoverer, ok := provider.(Discoverer)
+		if !ok {
+			continue
+		}
+
+		resp, discoverErr := discoverer.Discover(ctx, opts)
+		if discoverErr == nil {
+			return resp, nil
+		}
+
+		err = lastError(err, discoverErr)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
+
+// complete merges what the providers after found know about movie into it,
+// the ones before it already failed. Providers that fail are skipped, the
+// title is still served without them.
+func (c *Composite) complete(ctx context.Context, movie *themoviedb.Movie, found int, language string) {
+	if movie.IMDbID == "" {
+		return
+	}
+
+	for _, provider := range c.Providers[found+1:] {
+		other, err := provider.FindByExternalID(ctx, ExternalID{Source: SourceIMDb, ID: movie.IMDbID}, language)
+		if err != nil {
+			if !errors.Is(err, ErrNotSupported) {
+				fmt.Println("Failed to complete metadata from "+provider.Name()+": ", err)
+			}
+			continue
+		}
+
+		merge(movie, other)
+	}
+}
+
+// merge fills the empty fields of movie from other and adds the ratings of
+// sources movie has none of. Images are left alone, as their paths are
+// only valid on their own provider.
+func merge(movie, other *themoviedb.Movie) {
+	if movie.ID == 0 {
+		movie.ID = other.ID
+	}
+	if movie.Overview == "" {
+		movie.Overview = other.Overview
+	}
+	if movie.ReleaseDate == "" {
+		movie.ReleaseDate = other.ReleaseDate
+	}
+	if movie.Runtime == 0 {
+		movie.Runtime = other.Runtime
+	}
+	if len(movie.Genres) == 0 {
+		movie.Genres = other.Genres
+	}
+	if len(movie.Cast) == 0 {
+		movie.Cast = other.Cast
+	}
+	if len(movie.Directors) == 0 {
+		movie.Directors = other.Directors
+	}
+	if len(movie.Creators) == 0 {
+		movie.Creators = other.Creators
+	}
+	if len(movie.Seasons) == 0 {
+		movie.Seasons = other.Seasons
+	}
+	if movie.WatchProviders == nil {
+		movie.WatchProviders = other.WatchProviders
+	}
+
+	for _, rating := range other.Ratings {
+		exists := false
+		for _, existing := range movie.Ratings {
+			if existing.Source == rating.Source {
+				exists = true
+				break
+			}
+		}
+
+		if !exists {
+			movie.Ratings = append(movie.Ratings, rating)
+		}
+	}
+}
+
+// lastError keeps the error of the last provider that supported a lookup,
+// so a title TMDB doesn't know is still ErrNotFound after OMDb passed.
+func lastError(err, next error) error {
+	if errors.Is(next, ErrNotSupported) {
+		return err
+	}
+	return next
+}
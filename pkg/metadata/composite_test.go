@@ -0,0 +1,145 @@
+package metadata_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/adamelfsborg-code/movie-nest/pkg/metadata"
+	"github.com/adamelfsborg-code/movie-nest/pkg/omdb"
+	omdbfake "github.com/adamelfsborg-code/movie-nest/pkg/omdb/fake"
+	"github.com/adamelfsborg-code/movie-nest/pkg/themoviedb"
+	tmdbfake "github.com/adamelfsborg-code/movie-nest/pkg/themoviedb/fake"
+)
+
+type fakes struct {
+	tmdb *tmdbfake.Server
+	omdb *omdbfake.Server
+}
+
+func newComposite(t *testing.T) (*metadata.Composite, fakes) {
+	t.Helper()
+
+	servers := fakes{
+		tmdb: tmdbfake.NewServer(),
+		omdb: omdbfake.NewServer(),
+	}
+	t.Cleanup(servers.tmdb.Close)
+	t.Cleanup(servers.omdb.Close)
+
+	movieDB := themoviedb.NewMovieDBOptions("token", "")
+	movieDB.BaseURL = servers.tmdb.URL
+	movieDB.MaxRetries = 0
+	movieDB.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	movieDB.Metrics = nil
+
+	client := omdb.NewClient("key")
+	client.BaseURL = servers.omdb.URL
+	client.Timeout = time.Second
+
+	return metadata.NewComposite(metadata.NewTMDB(movieDB), metadata.NewOMDb(client)), servers
+}
+
+func ratingSources(movie *themoviedb.Movie) map[string]bool {
+	sources := make(map[string]bool)
+	for _, rating := range movie.Ratings {
+		sources[rating.Source] = true
+	}
+	return sources
+}
+
+func TestCompositeMerge(t *testing.T) {
+	composite, _ := newComposite(t)
+
+	movie, err := composite.Get(context.Background(), metadata.Key{MediaType: themoviedb.MediaMovie, ID: 603, Language: "en-US"})
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	if movie.ID != 603 || movie.IMDbID != "tt0133093" {
+		t.Errorf("Get = %v (%v), want TMDB's 603 (tt0133093)", movie.ID, movie.IMDbID)
+	}
+
+	sources := ratingSources(movie)
+	for _, source := range []string{metadata.RatingIMDb, metadata.RatingRottenTomatoes, metadata.RatingMetacritic} {
+		if !sources[source] {
+			t.Errorf("Get has no %v rating from OMDb, got %v", source, movie.Ratings)
+		}
+	}
+
+	if movie.Poster == "" || movie.Poster[0] != '/' {
+		t.Errorf("Poster = %q, want TMDB's path rather than OMDb's url", movie.Poster)
+	}
+}
+
+func TestCompositeFallback(t *testing.T) {
+	composite, servers := newComposite(t)
+	ctx := context.Background()
+
+	// TMDB is down, OMDb still knows the title by its IMDb id.
+	servers.tmdb.FailNext(http.StatusServiceUnavailable, 1)
+
+	movie, err := composite.FindByExternalID(ctx, metadata.ExternalID{Source: metadata.SourceIMDb, ID: "tt0133093"}, "en-US")
+	if err != nil {
+		t.Fatalf("FindByExternalID: %v", err)
+	}
+	if movie.Title != "The Matrix" || movie.ID != 0 || !ratingSources(movie)[metadata.RatingRottenTomatoes] {
+		t.Errorf("FindByExternalID = %+v, want OMDb's The Matrix", movie)
+	}
+
+	// OMDb can't look up TMDB ids, so TMDB's error is kept.
+	servers.tmdb.FailNext(http.StatusServiceUnavailable, 1)
+
+	_, err = composite.Get(ctx, metadata.Key{MediaType: themoviedb.MediaMovie, ID: 603})
+	if !errors.Is(err, themoviedb.ErrUnavailable) {
+		t.Errorf("Get while TMDB is down = %v, want %v", err, themoviedb.ErrUnavailable)
+	}
+
+	_, err = composite.FindByExternalID(ctx, metadata.ExternalID{Source: metadata.SourceIMDb, ID: "tt9999999"}, "")
+	if !errors.Is(err, omdb.ErrNotFound) {
+		t.Errorf("FindByExternalID of an unknown title = %v, want %v", err, omdb.ErrNotFound)
+	}
+}
+
+func TestCompositeWithoutSecondary(t *testing.T) {
+	composite, servers := newComposite(t)
+
+	// A failing OMDb only costs the ratings it adds.
+	servers.omdb.FailNext(1)
+
+	movie, err := composite.Get(context.Background(), metadata.Key{MediaType: themoviedb.MediaMovie, ID: 603})
+	if err != nil {
+		t.Fatalf("Get while OMDb is down: %v", err)
+	}
+
+	if movie.ID != 603 || ratingSources(movie)[metadata.RatingRottenTomatoes] {
+		t.Errorf("Get while OMDb is down = %v with %v, want TMDB's movie without OMDb ratings", movie.ID, movie.Ratings)
+	}
+}
+
+func TestCompositeSearch(t *testing.T) {
+	composite, servers := newComposite(t)
+	ctx := context.Background()
+
+	resp, err := composite.Search(ctx, metadata.Query{SearchOptions: themoviedb.SearchOptions{Query: "matrix"}})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(resp.Movies) == 0 || resp.Movies[0].ID == 0 {
+		t.Errorf("Search = %+v, want TMDB's results", resp.Movies)
+	}
+
+	servers.tmdb.FailNext(http.StatusServiceUnavailable, 1)
+
+	resp, err = composite.Search(ctx, metadata.Query{SearchOptions: themoviedb.SearchOptions{Query: "matrix"}})
+	if err != nil {
+		t.Fatalf("Search while TMDB is down: %v", err)
+	}
+	if len(resp.Movies) != 1 || resp.Movies[0].IMDbID != "tt0133093" {
+		t.Errorf("Search while TMDB is down = %+v, want OMDb's The Matrix", resp.Movies)
+	}
+}
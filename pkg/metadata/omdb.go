@@ -0,0 +1,229 @@
+package metadata
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adamelfsborg-code/movie-nest/pkg/omdb"
+	"github.com/adamelfsborg-code/movie-nest/pkg/themoviedb"
+)
+
+// Rating sources, as Movie.Ratings names them.
+const (
+	RatingIMDb           = "imdb"
+	RatingRottenTomatoes = "rotten_tomatoes"
+	RatingMetacritic     = "metacritic"
+)
+
+// OMDb is the Provider of an OMDb compatible API. OMDb knows titles by
+// IMDb id only, so Get is not supported and search results have no TMDB
+// id. Posters are full URLs rather than TMDB paths.
+type OMDb struct {
+	Client *omdb.Client
+}
+
+func NewOMDb(client *omdb.Client) *OMDb {
+	return &OMDb{Client: client}
+}
+
+func (o *OMDb) Name() string {
+	return "omdb"
+}
+
+func (o *OMDb) Search(ctx context.Context, query Query) (*themoviedb.SearchMovieResp, error) {
+	opts := omdb.SearchOptions{
+		Query: query.Query,
+		Year:  query.Year,
+		Page:  query.Page,
+	}
+
+	switch query.MediaType {
+	case "", themoviedb.MediaMovie:
+		opts.Type = omdb.TypeMovie
+	case themoviedb.MediaTV:
+		opts.Type = omdb.TypeSeries
+	case MediaAll:
+	default:
+		return nil, ErrNotSupported
+	}
+
+	// OMDb can't filter on genres and its results don't list them.
+	if len(query.Genres) > 0 {
+		return nil, ErrNotSupported
+	}
+
+	resp, err := o.Client.Search(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	page := query.Page
+	if page == 0 {
+		page = 1
+	}
+
+	movies := &themoviedb.SearchMovieResp{
+		Page:         page,
+		TotalResults: resp.TotalResults,
+		TotalPages:   (resp.TotalResults + 9) / 10,
+		Movies:       make([]themoviedb.Movie, 0, len(resp.Results)),
+	}
+
+	for _, result := range resp.Results {
+		mediaType := mediaType(result.Type)
+		if mediaType == "" {
+			continue
+		}
+
+		movies.Movies = append(movies.Movies, themoviedb.Movie{
+			MediaType:      mediaType,
+			Title:          result.Title,
+			LocalizedTitle: result.Title,
+			Poster:         available(result.Poster),
+			ReleaseDate:    firstYear(result.Year),
+			IMDbID:         result.IMDbID,
+		})
+	}
+
+	return movies, nil
+}
+
+func (o *OMDb) Get(ctx context.Context, key Key) (*themoviedb.Movie, error) {
+	return nil, ErrNotSupported
+}
+
+func (o *OMDb) FindByExternalID(ctx context.Context, id ExternalID, language string) (*themoviedb.Movie, error) {
+	if id.Source != SourceIMDb {
+		return nil, ErrNotSupported
+	}
+
+	title, err := o.Client.Get(ctx, id.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return convertTitle(title), nil
+}
+
+func convertTitle(title *omdb.Title) *themoviedb.Movie {
+	movie := &themoviedb.Movie{
+		MediaType:      mediaType(title.Type),
+		Title:          title.Title,
+		LocalizedTitle: title.Title,
+		Overview:       available(title.Plot),
+		Poster:         available(title.Poster),
+		ReleaseDate:    releaseDate(title.Released, title.Year),
+		IMDbID:         title.IMDbID,
+	}
+
+	runtime, _ := strconv.ParseUint(strings.TrimSuffix(available(title.Runtime), " min"), 10, 64)
+	movie.Runtime = uint(runtime)
+
+	for _, name := range splitList(title.Genre) {
+		movie.Genres = append(movie.Genres, themoviedb.Genre{Name: name})
+	}
+
+	for i, name := range splitList(title.Actors) {
+		movie.Cast = append(movie.Cast, themoviedb.CastMember{Name: name, Order: i})
+	}
+
+	for _, name := range splitList(title.Director) {
+		movie.Directors = append(movie.Directors, themoviedb.CrewMember{Name: name, Job: "Director", Department: "Directing"})
+	}
+
+	for _, rating := range title.Ratings {
+		converted, ok := convertRating(rating)
+		if !ok {
+			continue
+		}
+
+		if converted.Source == RatingIMDb {
+			votes, _ := strconv.ParseUint(strings.ReplaceAll(title.IMDbVotes, ",", ""), 10, 64)
+			converted.Votes = uint(votes)
+		}
+		movie.Ratings = append(movie.Ratings, converted)
+	}
+
+	return movie
+}
+
+// convertRating parses OMDb's 8.7/10, 83% and 73/100 scores.
+func convertRating(rating omdb.Rating) (themoviedb.Rating, bool) {
+	var source string
+	switch rating.Source {
+	case "Internet Movie Database":
+		source = RatingIMDb
+	case "Rotten Tomatoes":
+		source = RatingRottenTomatoes
+	case "Metacritic":
+		source = RatingMetacritic
+	default:
+		return themoviedb.Rating{}, false
+	}
+
+	value, max := rating.Value, "100"
+	if strings.HasSuffix(value, "%") {
+		value = strings.TrimSuffix(value, "%")
+	} else if parts := strings.SplitN(value, "/", 2); len(parts) == 2 {
+		value, max = parts[0], parts[1]
+	}
+
+	parsedValue, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return themoviedb.Rating{}, false
+	}
+	parsedMax, err := strconv.ParseFloat(max, 64)
+	if err != nil {
+		return themoviedb.Rating{}, false
+	}
+
+	return themoviedb.Rating{Source: source, Value: parsedValue, Max: parsedMax}, true
+}
+
+func mediaType(omdbType string) string {
+	switch omdbType {
+	case omdb.TypeMovie:
+		return themoviedb.MediaMovie
+	case omdb.TypeSeries:
+		return themoviedb.MediaTV
+	}
+	return ""
+}
+
+// releaseDate formats OMDb's 31 Mar 1999 like TMDB does, or falls back to
+// the first year.
+func releaseDate(released, year string) string {
+	date, err := time.Parse("02 Jan 2006", released)
+	if err != nil {
+		return firstYear(year)
+	}
+	return date.Format("2006-01-02")
+}
+
+// firstYear is 2011 of a 2011–2019 series.
+func firstYear(year string) string {
+	if len(year) < 4 {
+		return ""
+	}
+	return year[:4]
+}
+
+func available(value string) string {
+	if value == "N/A" {
+		return ""
+	}
+	return value
+}
+
+func splitList(value string) []string {
+	var names []string
+	for _, name := range strings.Split(available(value), ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,55 @@
+// Package metadata looks up movies and TV series in one or more sources
+// behind the Provider interface. Records are themoviedb.Movie values and
+// titles are identified by TMDB id, other sources are reached through
+// external ids such as IMDb ids.
+package metadata
+
+import (
+	"context"
+	"errors"
+
+	"github.com/adamelfsborg-code/movie-nest/pkg/themoviedb"
+)
+
+// ErrNotSupported is returned by providers for lookups their source can't
+// answer, such as TMDB ids on OMDb.
+var ErrNotSupported = errors.New("metadata: not supported")
+
+// MediaAll searches movies and TV series together.
+const MediaAll = "all"
+
+// SourceIMDb is the source of IMDb ids such as tt0133093.
+const SourceIMDb = "imdb"
+
+// Key identifies a movie or TV series by TMDB id, in Language.
+type Key struct {
+	MediaType string
+	ID        uint
+	Language  string
+}
+
+// ExternalID is the id of a title on another site.
+type ExternalID struct {
+	Source string
+	ID     string
+}
+
+// Query is a search for MediaType, themoviedb.MediaMovie when it is
+// empty, themoviedb.MediaTV or MediaAll.
+type Query struct {
+	themoviedb.SearchOptions
+	MediaType string
+}
+
+type Provider interface {
+	Name() string
+	Search(ctx context.Context, query Query) (*themoviedb.SearchMovieResp, error)
+	Get(ctx context.Context, key Key) (*themoviedb.Movie, error)
+	FindByExternalID(ctx context.Context, id ExternalID, language string) (*themoviedb.Movie, error)
+}
+
+// Discoverer is implemented by providers that can browse titles by
+// filters instead of a search term.
+type Discoverer interface {
+	Discover(ctx context.Context, opts themoviedb.DiscoverOptions) (*themoviedb.SearchMovieResp, error)
+}
@@ -0,0 +1,70 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/adamelfsborg-code/movie-nest/pkg/themoviedb"
+)
+
+// TMDB is the Provider of TMDB, the primary source.
+type TMDB struct {
+	Client *themoviedb.MovieDBOptions
+}
+
+func NewTMDB(client *themoviedb.MovieDBOptions) *TMDB {
+	return &TMDB{Client: client}
+}
+
+func (t *TMDB) Name() string {
+	return "tmdb"
+}
+
+func (t *TMDB) Search(ctx context.Context, query Query) (*themoviedb.SearchMovieResp, error) {
+	switch query.MediaType {
+	case "", themoviedb.MediaMovie:
+		return t.Client.SearchMoviesPage(ctx, query.SearchOptions)
+	case themoviedb.MediaTV, MediaAll:
+	default:
+		return nil, fmt.Errorf("Unknown media type %q", query.MediaType)
+	}
+
+	resp, err := t.Client.SearchMultiPage(ctx, query.SearchOptions)
+	if err != nil || query.MediaType == MediaAll {
+		return resp, err
+	}
+
+	series := make([]themoviedb.Movie, 0, len(resp.Movies))
+	for _, result := range resp.Movies {
+		if result.MediaType == themoviedb.MediaTV {
+			series = append(series, result)
+		}
+	}
+	resp.Movies = series
+
+	return resp, nil
+}
+
+func (t *TMDB) Get(ctx context.Context, key Key) (*themoviedb.Movie, error) {
+	if key.MediaType == themoviedb.MediaTV {
+		return t.Client.GetTV(ctx, key.ID, key.Language)
+	}
+	return t.Client.GetMovie(ctx, key.ID, key.Language)
+}
+
+func (t *TMDB) FindByExternalID(ctx context.Context, id ExternalID, language string) (*themoviedb.Movie, error) {
+	if id.Source != SourceIMDb {
+		return nil, ErrNotSupported
+	}
+
+	found, err := t.Client.Find(ctx, id.ID, themoviedb.ExternalIMDb, language)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.Get(ctx, Key{MediaType: found.MediaType, ID: found.ID, Language: language})
+}
+
+func (t *TMDB) Discover(ctx context.Context, opts themoviedb.DiscoverOptions) (*themoviedb.SearchMovieResp, error) {
+	return t.Client.DiscoverMovies(ctx, opts)
+}
@@ -0,0 +1,217 @@
+// Package fake serves recorded OMDb responses, a local stand-in for the
+// omdb client and the metadata providers built on it.
+//
+//	server := fake.NewServer()
+//	defer server.Close()
+//
+//	client := omdb.NewClient("key")
+//	client.BaseURL = server.URL
+package fake
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//go:embed fixtures/*.json
+var fixtures embed.FS
+
+const pageSize = 10
+
+// Server is an httptest server with the OMDb API.
+type Server struct {
+	*httptest.Server
+	*Handler
+}
+
+func NewServer() *Server {
+	handler := NewHandler()
+
+	return &Server{
+		Server:  httptest.NewServer(handler),
+		Handler: handler,
+	}
+}
+
+// Handler serves the fixtures. It can be mounted on any listener, see
+// cmd/fakeomdb.
+type Handler struct {
+	mu       sync.Mutex
+	failures int
+	requests []string
+}
+
+func NewHandler() *Handler {
+	return &Handler{}
+}
+
+// FailNext answers the next count requests with a 503.
+func (h *Handler) FailNext(count int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.failures = count
+}
+
+// Requests returns the query of every request served so far.
+func (h *Handler) Requests() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	requests := make([]string, len(h.requests))
+	copy(requests, h.requests)
+	return requests
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	h.requests = append(h.requests, r.URL.RawQuery)
+	fail := h.failures > 0
+	if fail {
+		h.failures--
+	}
+	h.mu.Unlock()
+
+	if fail {
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("apikey") == "" {
+		writeFixture(w, http.StatusUnauthorized, "error_unauthorized.json")
+		return
+	}
+
+	switch {
+	case query.Get("i") != "":
+		h.get(w, query.Get("i"))
+	case query.Get("s") != "":
+		h.search(w, r)
+	default:
+		writeFixture(w, http.StatusOK, "error_no_results.json")
+	}
+}
+
+func (h *Handler) get(w http.ResponseWriter, imdbID string) {
+	_, err := fixtures.ReadFile("fixtures/" + imdbID + ".json")
+	if err != nil || !strings.HasPrefix(imdbID, "tt") {
+		writeFixture(w, http.StatusOK, "error_not_found.json")
+		return
+	}
+
+	writeFixture(w, http.StatusOK, imdbID+".json")
+}
+
+// search matches the query against any part of the recorded titles, and
+// filters on type and the first year.
+func (h *Handler) search(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	text := strings.ToLower(query.Get("s"))
+
+	titles, err := readTitles()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	results := make([]map[string]string, 0)
+	for _, title := range titles {
+		if !strings.Contains(strings.ToLower(title["Title"]), text) {
+			continue
+		}
+		if query.Get("type") != "" && title["Type"] != query.Get("type") {
+			continue
+		}
+		if query.Get("y") != "" && !strings.HasPrefix(title["Year"], query.Get("y")) {
+			continue
+		}
+
+		results = append(results, map[string]string{
+			"Title":  title["Title"],
+			"Year":   title["Year"],
+			"imdbID": title["imdbID"],
+			"Type":   title["Type"],
+			"Poster": title["Poster"],
+		})
+	}
+
+	if len(results) == 0 {
+		writeFixture(w, http.StatusOK, "error_no_results.json")
+		return
+	}
+
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	start := (page - 1) * pageSize
+	if start > len(results) {
+		start = len(results)
+	}
+	end := start + pageSize
+	if end > len(results) {
+		end = len(results)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"Search":       results[start:end],
+		"totalResults": strconv.Itoa(len(results)),
+		"Response":     "True",
+	})
+}
+
+// readTitles reads the string fields of every recorded title, in IMDb id
+// order.
+func readTitles() ([]map[string]string, error) {
+	names, err := fs.Glob(fixtures, "fixtures/tt*.json")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	titles := make([]map[string]string, 0, len(names))
+	for _, name := range names {
+		body, err := fixtures.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		var fields map[string]interface{}
+		err = json.Unmarshal(body, &fields)
+		if err != nil {
+			return nil, err
+		}
+
+		title := make(map[string]string)
+		for key, value := range fields {
+			if text, ok := value.(string); ok {
+				title[key] = text
+			}
+		}
+		titles = append(titles, title)
+	}
+	return titles, nil
+}
+
+func writeFixture(w http.ResponseWriter, status int, name string) {
+	body, err := fixtures.ReadFile("fixtures/" + name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(body)
+}
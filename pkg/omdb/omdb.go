@@ -0,0 +1,250 @@
+// Package omdb is a client for the OMDb API and servers compatible with
+// it. Titles are identified by their IMDb id.
+package omdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultBaseURL = "https://www.omdbapi.com"
+	DefaultTimeout = time.Second * 10
+)
+
+// Types of titles, as OMDb names them.
+const (
+	TypeMovie  = "movie"
+	TypeSeries = "series"
+)
+
+var (
+	ErrNotFound     = errors.New("omdb: not found")
+	ErrUnauthorized = errors.New("omdb: unauthorized")
+	ErrUnavailable  = errors.New("omdb: unavailable")
+)
+
+// APIError is a failed response. OMDb answers most failures with a 200
+// and "Response": "False", Message is its Error field then. It matches
+// ErrNotFound, ErrUnauthorized or ErrUnavailable with errors.Is.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("omdb: %v %v", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("omdb: %v", e.Message)
+}
+
+func (e *APIError) Unwrap() error {
+	message := strings.ToLower(e.Message)
+	switch {
+	case e.StatusCode == http.StatusUnauthorized, strings.Contains(message, "api key"):
+		return ErrUnauthorized
+	case e.StatusCode == http.StatusNotFound, strings.Contains(message, "not found"), strings.Contains(message, "incorrect imdb id"):
+		return ErrNotFound
+	case e.StatusCode >= 500, strings.Contains(message, "limit reached"):
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// Client requests BaseURL with APIKey. HTTPClient replaces the default
+// client, Timeout is only used by the default one.
+type Client struct {
+	APIKey     string
+	BaseURL    string
+	Timeout    time.Duration
+	HTTPClient *http.Client
+
+	once       sync.Once
+	httpClient *http.Client
+}
+
+func NewClient(apiKey string) *Client {
+	return &Client{
+		APIKey:  apiKey,
+		BaseURL: DefaultBaseURL,
+		Timeout: DefaultTimeout,
+	}
+}
+
+// Title is a movie or series looked up by IMDb id. OMDb sends every
+// value as a string, "N/A" when it is missing.
+type Title struct {
+	Title        string   `json:"Title"`
+	Year         string   `json:"Year"`
+	Rated        string   `json:"Rated"`
+	Released     string   `json:"Released"`
+	Runtime      string   `json:"Runtime"`
+	Genre        string   `json:"Genre"`
+	Director     string   `json:"Director"`
+	Writer       string   `json:"Writer"`
+	Actors       string   `json:"Actors"`
+	Plot         string   `json:"Plot"`
+	Language     string   `json:"Language"`
+	Country      string   `json:"Country"`
+	Poster       string   `json:"Poster"`
+	Ratings      []Rating `json:"Ratings"`
+	Metascore    string   `json:"Metascore"`
+	IMDbRating   string   `json:"imdbRating"`
+	IMDbVotes    string   `json:"imdbVotes"`
+	IMDbID       string   `json:"imdbID"`
+	Type         string   `json:"Type"`
+	TotalSeasons string   `json:"totalSeasons"`
+}
+
+// Rating is a score as OMDb formats it, such as 8.7/10, 83% or 73/100.
+type Rating struct {
+	Source string `json:"Source"`
+	Value  string `json:"Value"`
+}
+
+type SearchResult struct {
+	Title  string `json:"Title"`
+	Year   string `json:"Year"`
+	IMDbID string `json:"imdbID"`
+	Type   string `json:"Type"`
+	Poster string `json:"Poster"`
+}
+
+// SearchOptions filter a search. Type is TypeMovie or TypeSeries, zero
+// values leave a filter out. OMDb pages hold 10 results.
+type SearchOptions struct {
+	Query string
+	Type  string
+	Year  uint
+	Page  uint
+}
+
+type SearchResp struct {
+	Results      []SearchResult
+	TotalResults uint
+}
+
+// Get looks up a title by IMDb id with its full plot.
+func (c *Client) Get(ctx context.Context, imdbID string) (*Title, error) {
+	values := url.Values{}
+	values.Set("i", imdbID)
+	values.Set("plot", "full")
+
+	body, err := c.get(ctx, values)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get %v: %w", imdbID, err)
+	}
+
+	title := &Title{}
+	err = json.Unmarshal(body, title)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode title: %w", err)
+	}
+
+	return title, nil
+}
+
+// Search searches titles. Searches without results are not an error.
+func (c *Client) Search(ctx context.Context, opts SearchOptions) (*SearchResp, error) {
+	values := url.Values{}
+	values.Set("s", opts.Query)
+	if opts.Type != "" {
+		values.Set("type", opts.Type)
+	}
+	if opts.Year > 0 {
+		values.Set("y", strconv.FormatUint(uint64(opts.Year), 10))
+	}
+	if opts.Page > 0 {
+		values.Set("page", strconv.FormatUint(uint64(opts.Page), 10))
+	}
+
+	body, err := c.get(ctx, values)
+	if errors.Is(err, ErrNotFound) {
+		return &SearchResp{Results: make([]SearchResult, 0)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to search: %w", err)
+	}
+
+	var page struct {
+		Results      []SearchResult `json:"Search"`
+		TotalResults string         `json:"totalResults"`
+	}
+	err = json.Unmarshal(body, &page)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode search results: %w", err)
+	}
+
+	total, _ := strconv.ParseUint(page.TotalResults, 10, 64)
+
+	return &SearchResp{Results: page.Results, TotalResults: uint(total)}, nil
+}
+
+func (c *Client) init() {
+	c.once.Do(func() {
+		c.httpClient = c.HTTPClient
+		if c.httpClient == nil {
+			c.httpClient = &http.Client{Timeout: c.Timeout}
+		}
+	})
+}
+
+// get requests the API with values and returns the body of a successful
+// response. Failures are returned as an *APIError, failed connections as
+// ErrUnavailable without the url, it carries the api key.
+func (c *Client) get(ctx context.Context, values url.Values) ([]byte, error) {
+	c.init()
+
+	if c.APIKey == "" {
+		return nil, &APIError{StatusCode: http.StatusUnauthorized, Message: "No API key provided."}
+	}
+	values.Set("apikey", c.APIKey)
+
+	baseURL := strings.TrimSuffix(c.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "GET", baseURL+"/?"+values.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("accept", "application/json")
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var status struct {
+		Response string `json:"Response"`
+		Error    string `json:"Error"`
+	}
+	json.Unmarshal(body, &status)
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 || status.Response == "False" {
+		return nil, &APIError{StatusCode: response.StatusCode, Message: status.Error}
+	}
+
+	return body, nil
+}
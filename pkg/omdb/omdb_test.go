@@ -0,0 +1,127 @@
+package omdb_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/adamelfsborg-code/movie-nest/pkg/omdb"
+	"github.com/adamelfsborg-code/movie-nest/pkg/omdb/fake"
+)
+
+func newClient(t *testing.T) (*omdb.Client, *fake.Server) {
+	t.Helper()
+
+	server := fake.NewServer()
+	t.Cleanup(server.Close)
+
+	client := omdb.NewClient("key")
+	client.BaseURL = server.URL
+	return client, server
+}
+
+func TestGet(t *testing.T) {
+	client, server := newClient(t)
+
+	title, err := client.Get(context.Background(), "tt0133093")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	if title.Title != "The Matrix" || title.Year != "1999" || title.Type != omdb.TypeMovie {
+		t.Errorf("Get = %v (%v, %v), want The Matrix (1999, movie)", title.Title, title.Year, title.Type)
+	}
+	if len(title.Ratings) == 0 {
+		t.Error("Get returned no ratings")
+	}
+
+	requests := server.Requests()
+	if len(requests) != 1 || !strings.Contains(requests[0], "plot=full") || !strings.Contains(requests[0], "apikey=key") {
+		t.Errorf("Requests = %v, want one with the full plot and the api key", requests)
+	}
+}
+
+func TestGetErrors(t *testing.T) {
+	client, server := newClient(t)
+	ctx := context.Background()
+
+	_, err := client.Get(ctx, "tt9999999")
+	if !errors.Is(err, omdb.ErrNotFound) {
+		t.Errorf("Get of an unknown id = %v, want %v", err, omdb.ErrNotFound)
+	}
+
+	_, err = client.Get(ctx, "matrix")
+	if !errors.Is(err, omdb.ErrNotFound) {
+		t.Errorf("Get of an invalid id = %v, want %v", err, omdb.ErrNotFound)
+	}
+
+	server.FailNext(1)
+	_, err = client.Get(ctx, "tt0133093")
+	if !errors.Is(err, omdb.ErrUnavailable) {
+		t.Errorf("Get while unavailable = %v, want %v", err, omdb.ErrUnavailable)
+	}
+
+	var apiError *omdb.APIError
+	if !errors.As(err, &apiError) || apiError.StatusCode != 503 {
+		t.Errorf("Get while unavailable = %#v, want an *APIError with status 503", err)
+	}
+
+	client.APIKey = ""
+	_, err = client.Get(ctx, "tt0133093")
+	if !errors.Is(err, omdb.ErrUnauthorized) {
+		t.Errorf("Get without api key = %v, want %v", err, omdb.ErrUnauthorized)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	client, _ := newClient(t)
+	ctx := context.Background()
+
+	resp, err := client.Search(ctx, omdb.SearchOptions{Query: "matrix"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if resp.TotalResults != 1 || len(resp.Results) != 1 || resp.Results[0].IMDbID != "tt0133093" {
+		t.Errorf("Search(matrix) = %+v, want The Matrix", resp)
+	}
+
+	resp, err = client.Search(ctx, omdb.SearchOptions{Query: "o", Type: omdb.TypeSeries})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	for _, result := range resp.Results {
+		if result.Type != omdb.TypeSeries {
+			t.Errorf("Search for series returned a %v", result.Type)
+		}
+	}
+	if len(resp.Results) == 0 {
+		t.Error("Search for series returned nothing")
+	}
+
+	resp, err = client.Search(ctx, omdb.SearchOptions{Query: "no such title"})
+	if err != nil {
+		t.Fatalf("Search without results: %v", err)
+	}
+	if resp.TotalResults != 0 || len(resp.Results) != 0 {
+		t.Errorf("Search without results = %+v, want none", resp)
+	}
+}
+
+func TestGetConnectionFailed(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	client := omdb.NewClient("secret-key")
+	client.BaseURL = server.URL
+
+	_, err := client.Get(context.Background(), "tt0133093")
+	if !errors.Is(err, omdb.ErrUnavailable) {
+		t.Errorf("Get of a closed server = %v, want %v", err, omdb.ErrUnavailable)
+	}
+	if err != nil && strings.Contains(err.Error(), "secret-key") {
+		t.Errorf("Get of a closed server = %v, want the api key left out", err)
+	}
+}
@@ -0,0 +1,156 @@
+package themoviedb_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/adamelfsborg-code/movie-nest/pkg/themoviedb"
+	"github.com/adamelfsborg-code/movie-nest/pkg/themoviedb/fake"
+)
+
+func newClient(t *testing.T) (*themoviedb.MovieDBOptions, *fake.Server) {
+	t.Helper()
+
+	server := fake.NewServer()
+	t.Cleanup(server.Close)
+
+	client := themoviedb.NewMovieDBOptions("token", "")
+	client.BaseURL = server.URL
+	client.RetryBackoff = time.Millisecond
+	client.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	client.Metrics = nil
+	return client, server
+}
+
+func TestGetMovie(t *testing.T) {
+	client, _ := newClient(t)
+
+	movie, err := client.GetMovie(context.Background(), 603, "en-US")
+	if err != nil {
+		t.Fatalf("GetMovie: %v", err)
+	}
+
+	if movie.ID != 603 || movie.MediaType != themoviedb.MediaMovie || movie.IMDbID != "tt0133093" {
+		t.Errorf("GetMovie = %v %v (%v), want movie 603 (tt0133093)", movie.MediaType, movie.ID, movie.IMDbID)
+	}
+	if len(movie.Cast) == 0 || len(movie.Directors) == 0 {
+		t.Error("GetMovie returned no credits")
+	}
+	if movie.WatchProviders == nil {
+		t.Error("GetMovie returned no watch providers")
+	}
+}
+
+func TestRetries(t *testing.T) {
+	client, server := newClient(t)
+	ctx := context.Background()
+
+	server.FailNext(http.StatusServiceUnavailable, 2)
+	_, err := client.GetMovie(ctx, 603, "")
+	if err != nil {
+		t.Fatalf("GetMovie after two failures: %v", err)
+	}
+	if requests := len(server.Requests()); requests != 3 {
+		t.Errorf("Made %v requests, want 3", requests)
+	}
+
+	server.SetRetryAfter(0)
+	server.FailNext(http.StatusTooManyRequests, 1)
+	_, err = client.GetMovie(ctx, 603, "")
+	if err != nil {
+		t.Fatalf("GetMovie after a 429: %v", err)
+	}
+
+	server.FailNext(http.StatusInternalServerError, themoviedb.DefaultMaxRetries+1)
+	before := len(server.Requests())
+	_, err = client.GetMovie(ctx, 603, "")
+	if !errors.Is(err, themoviedb.ErrUnavailable) {
+		t.Errorf("GetMovie after all retries = %v, want %v", err, themoviedb.ErrUnavailable)
+	}
+	if requests := len(server.Requests()) - before; requests != themoviedb.DefaultMaxRetries+1 {
+		t.Errorf("Made %v requests, want %v", requests, themoviedb.DefaultMaxRetries+1)
+	}
+}
+
+func TestRetryAfterTooLong(t *testing.T) {
+	client, server := newClient(t)
+	client.MaxRetryWait = time.Second
+
+	server.SetRetryAfter(60)
+	server.FailNext(http.StatusTooManyRequests, 1)
+
+	_, err := client.GetMovie(context.Background(), 603, "")
+	if !errors.Is(err, themoviedb.ErrRateLimited) {
+		t.Errorf("GetMovie = %v, want %v", err, themoviedb.ErrRateLimited)
+	}
+
+	var apiError *themoviedb.APIError
+	if !errors.As(err, &apiError) || apiError.RetryAfter != time.Minute {
+		t.Errorf("GetMovie = %#v, want an *APIError with a minute Retry-After", err)
+	}
+	if requests := len(server.Requests()); requests != 1 {
+		t.Errorf("Made %v requests, want 1", requests)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	client, server := newClient(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusNotFound, themoviedb.ErrNotFound},
+		{http.StatusUnauthorized, themoviedb.ErrUnauthorized},
+	}
+
+	for _, test := range tests {
+		before := len(server.Requests())
+		server.FailNext(test.status, 1)
+
+		_, err := client.GetMovie(ctx, 603, "")
+		if !errors.Is(err, test.want) {
+			t.Errorf("GetMovie with a %v = %v, want %v", test.status, err, test.want)
+		}
+
+		var apiError *themoviedb.APIError
+		if !errors.As(err, &apiError) || apiError.StatusCode != test.status || apiError.StatusMessage == "" {
+			t.Errorf("GetMovie with a %v = %#v, want an *APIError with TMDB's message", test.status, err)
+		}
+
+		if requests := len(server.Requests()) - before; requests != 1 {
+			t.Errorf("GetMovie with a %v made %v requests, want no retries", test.status, requests)
+		}
+	}
+
+	_, err := client.GetMovie(ctx, 1, "")
+	if !errors.Is(err, themoviedb.ErrNotFound) {
+		t.Errorf("GetMovie of an unknown movie = %v, want %v", err, themoviedb.ErrNotFound)
+	}
+
+	_, err = client.Find(ctx, "tt9999999", themoviedb.ExternalIMDb, "")
+	if !errors.Is(err, themoviedb.ErrNotFound) {
+		t.Errorf("Find of an unknown id = %v, want %v", err, themoviedb.ErrNotFound)
+	}
+}
+
+func TestCancelledRetry(t *testing.T) {
+	client, server := newClient(t)
+	client.RetryBackoff = time.Second
+
+	server.FailNext(http.StatusServiceUnavailable, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	_, err := client.GetMovie(ctx, 603, "")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetMovie = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -1,48 +1,170 @@
 package themoviedb
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"sort"
 )
 
+// TopCastSize is how many of the top billed cast members are kept.
+const TopCastSize = 10
+
+// DefaultLanguage is the language TMDB answers in when none is asked for.
+const DefaultLanguage = "en-US"
+
+// Locale is a TMDB language such as sv-SE and an ISO 3166-1 region such
+// as SE. Language picks the language of titles and overviews, Region
+// filters searches on release region.
+type Locale struct {
+	Language string `json:"language,omitempty"`
+	Region   string `json:"region,omitempty"`
+}
+
+// Media types, as TMDB names them in search/multi results.
+const (
+	MediaMovie = "movie"
+	MediaTV    = "tv"
+)
+
+// Movie is a TMDB movie or TV series, see MediaType. Title is the original
+// title, LocalizedTitle the title in the requested language. TV series
+// use the first air date as ReleaseDate and the episode run time as
+// Runtime, and list their seasons and creators. Search results have no
+// runtime, IMDb id, cast, directors, seasons or watch providers.
 type Movie struct {
-	ID          uint   `json:"id"`
-	Title       string `json:"original_title"`
-	Poster      string `json:"poster_path"`
-	ReleaseDate string `json:"release_date"`
+	ID             uint           `json:"id"`
+	MediaType      string         `json:"media_type,omitempty"`
+	Title          string         `json:"original_title"`
+	LocalizedTitle string         `json:"title"`
+	Overview       string         `json:"overview"`
+	Poster         string         `json:"poster_path"`
+	Backdrop       string         `json:"backdrop_path"`
+	ReleaseDate    string         `json:"release_date"`
+	Genres         []Genre        `json:"genres,omitempty"`
+	GenreIDs       []uint         `json:"genre_ids,omitempty"`
+	Runtime        uint           `json:"runtime,omitempty"`
+	VoteAverage    float64        `json:"vote_average"`
+	IMDbID         string         `json:"imdb_id,omitempty"`
+	Cast           []CastMember   `json:"cast,omitempty"`
+	Directors      []CrewMember   `json:"directors,omitempty"`
+	Creators       []CrewMember   `json:"creators,omitempty"`
+	Seasons        []Season       `json:"seasons,omitempty"`
+	WatchProviders WatchProviders `json:"watch_providers,omitempty"`
+	Ratings        []Rating       `json:"ratings,omitempty"`
+}
+
+// Season returns the season with the given number of a TV series.
+func (m *Movie) Season(seasonNumber uint) (*Season, bool) {
+	for i := range m.Seasons {
+		if m.Seasons[i].SeasonNumber == seasonNumber {
+			return &m.Seasons[i], true
+		}
+	}
+	return nil, false
+}
+
+// Rating is a score from another source, such as IMDb, merged in by
+// metadata.Composite. Value is out of Max.
+type Rating struct {
+	Source string  `json:"source"`
+	Value  float64 `json:"value"`
+	Max    float64 `json:"max"`
+	Votes  uint    `json:"votes,omitempty"`
+}
+
+type Genre struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+type CastMember struct {
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	Character string `json:"character"`
+	Profile   string `json:"profile_path"`
+	Order     int    `json:"order"`
+}
+
+type CrewMember struct {
+	ID         uint   `json:"id"`
+	Name       string `json:"name"`
+	Job        string `json:"job"`
+	Department string `json:"department"`
+	Profile    string `json:"profile_path"`
+}
+
+type Credits struct {
+	Cast []CastMember `json:"cast"`
+	Crew []CrewMember `json:"crew"`
+}
+
+// movieResp is a movie requested with
+// append_to_response=credits,watch/providers.
+type movieResp struct {
+	Movie
+	Credits        Credits            `json:"credits"`
+	WatchProviders watchProvidersResp `json:"watch/providers"`
 }
 
+// SearchMovieResp is one page of search or discover results.
 type SearchMovieResp struct {
-	Page   uint    `json:"page"`
-	Movies []Movie `json:"results"`
+	Page         uint    `json:"page"`
+	TotalPages   uint    `json:"total_pages"`
+	TotalResults uint    `json:"total_results"`
+	Movies       []Movie `json:"results"`
 }
 
-func (m *MovieDBOptions) GetMovie(movieID uint) (*Movie, error) {
-	byteMovie, err := m.get(fmt.Sprintf("movie/%v", movieID))
+// GetMovie looks up a movie in language, TMDB's default when it is empty.
+func (m *MovieDBOptions) GetMovie(ctx context.Context, movieID uint, language string) (*Movie, error) {
+	byteMovie, err := m.get(ctx, fmt.Sprintf("movie/%v?%v", movieID, lookupValues(language).Encode()))
 	if err != nil {
 		return nil, fmt.Errorf("Failed to get movie: %w", err)
 	}
 
-	resp := &Movie{}
-	json.Unmarshal(byteMovie, &resp)
-
-	movie := &Movie{
-		ID:          resp.ID,
-		Title:       resp.Title,
-		Poster:      resp.Poster,
-		ReleaseDate: resp.ReleaseDate,
+	resp := &movieResp{}
+	err = json.Unmarshal(byteMovie, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode movie: %w", err)
 	}
-	return movie, nil
+
+	movie := resp.Movie
+	movie.MediaType = MediaMovie
+	movie.Cast = topCast(resp.Credits.Cast)
+	movie.Directors = directors(resp.Credits.Crew)
+	movie.WatchProviders = resp.WatchProviders.Results
+
+	return &movie, nil
 }
 
-func (m *MovieDBOptions) SearchMovies(searchTerm string) ([]Movie, error) {
-	byteMovies, err := m.get(fmt.Sprintf("search/movie?query=%v", searchTerm))
-	if err != nil {
-		return nil, fmt.Errorf("Failed to get movie: %w", err)
+func lookupValues(language string) url.Values {
+	values := url.Values{}
+	values.Set("append_to_response", "credits,watch/providers")
+	if language != "" {
+		values.Set("language", language)
 	}
+	return values
+}
 
-	resp := SearchMovieResp{}
-	json.Unmarshal(byteMovies, &resp)
+// topCast returns the first TopCastSize cast members in billing order.
+func topCast(cast []CastMember) []CastMember {
+	sort.SliceStable(cast, func(i, j int) bool {
+		return cast[i].Order < cast[j].Order
+	})
 
-	return resp.Movies, nil
+	if len(cast) > TopCastSize {
+		cast = cast[:TopCastSize]
+	}
+	return cast
+}
+
+func directors(crew []CrewMember) []CrewMember {
+	var directors []CrewMember
+	for _, member := range crew {
+		if member.Job == "Director" {
+			directors = append(directors, member)
+		}
+	}
+	return directors
 }
@@ -0,0 +1,49 @@
+package themoviedb
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var (
+	ErrNotFound     = errors.New("themoviedb: not found")
+	ErrUnauthorized = errors.New("themoviedb: unauthorized")
+	ErrRateLimited  = errors.New("themoviedb: rate limited")
+	ErrUnavailable  = errors.New("themoviedb: unavailable")
+)
+
+// APIError is a non 2xx response. It matches ErrNotFound, ErrUnauthorized,
+// ErrRateLimited or ErrUnavailable with errors.Is depending on the status.
+type APIError struct {
+	StatusCode    int           `json:"-"`
+	Code          int           `json:"status_code"`
+	StatusMessage string        `json:"status_message"`
+	RetryAfter    time.Duration `json:"-"`
+}
+
+func (e *APIError) Error() string {
+	if e.StatusMessage == "" {
+		return fmt.Sprintf("themoviedb: %v %v", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("themoviedb: %v %v", e.StatusCode, e.StatusMessage)
+}
+
+func (e *APIError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case e.StatusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.StatusCode >= 500:
+		return ErrUnavailable
+	}
+	return nil
+}
+
+func (e *APIError) retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
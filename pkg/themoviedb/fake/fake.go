@@ -0,0 +1,464 @@
+// Package fake serves recorded TMDB responses so the themoviedb client and
+// everything built on it can run without network access.
+//
+//	server := fake.NewServer()
+//	defer server.Close()
+//
+//	movieDB := themoviedb.NewMovieDBOptions("token", "")
+//	movieDB.BaseURL = server.URL
+package fake
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//go:embed fixtures/*.json
+var fixtures embed.FS
+
+const pageSize = 20
+
+// Server is an httptest server with the TMDB routes the API uses.
+type Server struct {
+	*httptest.Server
+	*Handler
+}
+
+func NewServer() *Server {
+	handler := NewHandler()
+
+	return &Server{
+		Server:  httptest.NewServer(handler),
+		Handler: handler,
+	}
+}
+
+// Handler serves the fixtures. It can be mounted on any listener, see
+// cmd/faketmdb.
+type Handler struct {
+	mu         sync.Mutex
+	failures   []failure
+	requests   []string
+	retryAfter int
+}
+
+type failure struct {
+	status int
+	count  int
+}
+
+func NewHandler() *Handler {
+	return &Handler{
+		retryAfter: 1,
+	}
+}
+
+// FailNext answers the next count requests with status and the matching
+// TMDB error body. 429 responses carry a Retry-After header.
+func (h *Handler) FailNext(status, count int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.failures = append(h.failures, failure{status: status, count: count})
+}
+
+// SetRetryAfter sets the Retry-After seconds sent with 429 responses.
+func (h *Handler) SetRetryAfter(seconds int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.retryAfter = seconds
+}
+
+// Requests returns the path and query of every request served so far.
+func (h *Handler) Requests() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	requests := make([]string, len(h.requests))
+	copy(requests, h.requests)
+	return requests
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	h.requests = append(h.requests, r.URL.RequestURI())
+	status := h.nextFailure()
+	retryAfter := h.retryAfter
+	h.mu.Unlock()
+
+	if status != 0 {
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		}
+		writeError(w, status)
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/t/p/") {
+		writeImage(w, strings.TrimPrefix(r.URL.Path, "/t/p/"))
+		return
+	}
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") && r.URL.Query().Get("api_key") == "" {
+		writeError(w, http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.Trim(r.URL.Path, "/")
+	parts := strings.Split(path, "/")
+	if len(parts) < 2 || parts[0] != "3" {
+		writeError(w, http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case len(parts) == 3 && parts[1] == "search" && parts[2] == "movie":
+		h.search(w, r, "search_movie.json")
+	case len(parts) == 3 && parts[1] == "search" && parts[2] == "multi":
+		h.search(w, r, "search_multi.json")
+	case len(parts) == 3 && parts[1] == "discover" && parts[2] == "movie":
+		h.discover(w, r)
+	case len(parts) == 3 && parts[1] == "find":
+		h.find(w, parts[2])
+	case len(parts) == 3 && parts[1] == "movie":
+		h.media(w, r, fmt.Sprintf("movie_%v.json", parts[2]), fmt.Sprintf("credits_%v.json", parts[2]), fmt.Sprintf("watch_providers_%v.json", parts[2]), "")
+	case len(parts) == 4 && parts[1] == "movie" && parts[3] == "credits":
+		writeFixture(w, fmt.Sprintf("credits_%v.json", parts[2]))
+	case len(parts) == 5 && parts[1] == "movie" && parts[3] == "watch" && parts[4] == "providers":
+		writeProviders(w, parts[2], fmt.Sprintf("watch_providers_%v.json", parts[2]))
+	case len(parts) == 3 && parts[1] == "tv":
+		h.media(w, r, fmt.Sprintf("tv_%v.json", parts[2]), fmt.Sprintf("tv_credits_%v.json", parts[2]), fmt.Sprintf("tv_watch_providers_%v.json", parts[2]), fmt.Sprintf("tv_external_ids_%v.json", parts[2]))
+	case len(parts) == 4 && parts[1] == "tv" && parts[3] == "credits":
+		writeFixture(w, fmt.Sprintf("tv_credits_%v.json", parts[2]))
+	case len(parts) == 5 && parts[1] == "tv" && parts[3] == "watch" && parts[4] == "providers":
+		writeProviders(w, parts[2], fmt.Sprintf("tv_watch_providers_%v.json", parts[2]))
+	default:
+		writeError(w, http.StatusNotFound)
+	}
+}
+
+func (h *Handler) nextFailure() int {
+	for len(h.failures) > 0 {
+		next := &h.failures[0]
+		if next.count <= 0 {
+			h.failures = h.failures[1:]
+			continue
+		}
+
+		next.count--
+		return next.status
+	}
+	return 0
+}
+
+func writeFixture(w http.ResponseWriter, name string) {
+	body, err := fixtures.ReadFile("fixtures/" + name)
+	if err != nil {
+		writeError(w, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+func writeError(w http.ResponseWriter, status int) {
+	body, err := fixtures.ReadFile(fmt.Sprintf("fixtures/error_%v.json", status))
+	if err != nil {
+		body, _ = fixtures.ReadFile("fixtures/error_500.json")
+	}
+
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+type searchResponse struct {
+	Page         int                      `json:"page"`
+	Results      []map[string]interface{} `json:"results"`
+	TotalPages   int                      `json:"total_pages"`
+	TotalResults int                      `json:"total_results"`
+}
+
+// search filters the recorded search results on the query, matching any
+// part of the movie title or the TV series and person name, and on the
+// release year.
+func (h *Handler) search(w http.ResponseWriter, r *http.Request, fixture string) {
+	recorded, err := readResults(fixture)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError)
+		return
+	}
+
+	query := strings.ToLower(r.URL.Query().Get("query"))
+	year := r.URL.Query().Get("year")
+
+	results := make([]map[string]interface{}, 0)
+	for _, result := range recorded {
+		title, _ := result["title"].(string)
+		if title == "" {
+			title, _ = result["name"].(string)
+		}
+		date, _ := result["release_date"].(string)
+		if year != "" && !strings.HasPrefix(date, year+"-") {
+			continue
+		}
+		if query != "" && strings.Contains(strings.ToLower(title), query) {
+			results = append(results, result)
+		}
+	}
+
+	writeResults(w, r, results)
+}
+
+// discover filters the recorded movie search results on with_genres and
+// the primary release date range, and sorts them on sort_by.
+func (h *Handler) discover(w http.ResponseWriter, r *http.Request) {
+	recorded, err := readResults("search_movie.json")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError)
+		return
+	}
+
+	params := r.URL.Query()
+	from := params.Get("primary_release_date.gte")
+	to := params.Get("primary_release_date.lte")
+
+	var genres []float64
+	for _, genre := range strings.Split(params.Get("with_genres"), ",") {
+		if id, err := strconv.ParseFloat(genre, 64); err == nil {
+			genres = append(genres, id)
+		}
+	}
+
+	results := make([]map[string]interface{}, 0)
+	for _, result := range recorded {
+		date, _ := result["release_date"].(string)
+		if (from != "" && date < from) || (to != "" && date > to) {
+			continue
+		}
+		if !hasGenres(result, genres) {
+			continue
+		}
+		results = append(results, result)
+	}
+
+	sortBy := strings.TrimSuffix(params.Get("sort_by"), ".desc")
+	if sortBy == "primary_release_date" {
+		sortBy = "release_date"
+	}
+	if sortBy != "" {
+		sort.SliceStable(results, func(i, j int) bool {
+			a, aNumber := results[i][sortBy].(float64)
+			b, bNumber := results[j][sortBy].(float64)
+			if aNumber && bNumber {
+				return a > b
+			}
+			return fmt.Sprint(results[i][sortBy]) > fmt.Sprint(results[j][sortBy])
+		})
+	}
+
+	writeResults(w, r, results)
+}
+
+func hasGenres(result map[string]interface{}, genres []float64) bool {
+	genreIDs, _ := result["genre_ids"].([]interface{})
+	for _, genre := range genres {
+		found := false
+		for _, genreID := range genreIDs {
+			if genreID == genre {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func readResults(fixture string) ([]map[string]interface{}, error) {
+	body, err := fixtures.ReadFile("fixtures/" + fixture)
+	if err != nil {
+		return nil, err
+	}
+
+	var recorded searchResponse
+	err = json.Unmarshal(body, &recorded)
+	if err != nil {
+		return nil, err
+	}
+	return recorded.Results, nil
+}
+
+// writeResults answers with the requested page of results, pages hold
+// pageSize results like TMDB's.
+func writeResults(w http.ResponseWriter, r *http.Request, results []map[string]interface{}) {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	response := searchResponse{
+		Page:         page,
+		Results:      make([]map[string]interface{}, 0),
+		TotalResults: len(results),
+		TotalPages:   (len(results) + pageSize - 1) / pageSize,
+	}
+
+	start := (page - 1) * pageSize
+	if start < len(results) {
+		end := start + pageSize
+		if end > len(results) {
+			end = len(results)
+		}
+		response.Results = results[start:end]
+	}
+
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// find answers with the recorded results of an external id, and with no
+// results for ids without a fixture, like TMDB does.
+func (h *Handler) find(w http.ResponseWriter, externalID string) {
+	fixture := fmt.Sprintf("find_%v.json", externalID)
+	_, err := fixtures.ReadFile("fixtures/" + fixture)
+	if err == nil {
+		writeFixture(w, fixture)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"movie_results":[],"person_results":[],"tv_results":[],"tv_episode_results":[],"tv_season_results":[]}`))
+}
+
+// media serves a movie or TV series fixture, with its credits, watch
+// providers and external ids when the request asks for them with
+// append_to_response.
+func (h *Handler) media(w http.ResponseWriter, r *http.Request, fixture, creditsFixture, providersFixture, externalIDsFixture string) {
+	appended := make(map[string]bool)
+	for _, name := range strings.Split(r.URL.Query().Get("append_to_response"), ",") {
+		appended[name] = true
+	}
+
+	if !appended["credits"] && !appended["watch/providers"] && !appended["external_ids"] {
+		writeFixture(w, fixture)
+		return
+	}
+
+	body, err := fixtures.ReadFile("fixtures/" + fixture)
+	if err != nil {
+		writeError(w, http.StatusNotFound)
+		return
+	}
+
+	var movie map[string]interface{}
+	err = json.Unmarshal(body, &movie)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError)
+		return
+	}
+
+	if appended["credits"] {
+		credits := map[string]interface{}{"cast": []interface{}{}, "crew": []interface{}{}}
+		body, err = fixtures.ReadFile("fixtures/" + creditsFixture)
+		if err == nil {
+			json.Unmarshal(body, &credits)
+			delete(credits, "id")
+		}
+		movie["credits"] = credits
+	}
+
+	if appended["watch/providers"] {
+		providers := map[string]interface{}{"results": map[string]interface{}{}}
+		body, err = fixtures.ReadFile("fixtures/" + providersFixture)
+		if err == nil {
+			json.Unmarshal(body, &providers)
+			delete(providers, "id")
+		}
+		movie["watch/providers"] = providers
+	}
+
+	if appended["external_ids"] && externalIDsFixture != "" {
+		externalIDs := map[string]interface{}{}
+		body, err = fixtures.ReadFile("fixtures/" + externalIDsFixture)
+		if err == nil {
+			json.Unmarshal(body, &externalIDs)
+			delete(externalIDs, "id")
+		}
+		movie["external_ids"] = externalIDs
+	}
+
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(movie)
+}
+
+// writeProviders answers with the recorded providers, or none when a
+// movie has no fixture, like TMDB does for titles nobody streams.
+func writeProviders(w http.ResponseWriter, id, fixture string) {
+	_, err := fixtures.ReadFile("fixtures/" + fixture)
+	if err == nil {
+		writeFixture(w, fixture)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, `{"id":%v,"results":{}}`, id)
+}
+
+// writeImage serves a placeholder for an image path such as w342/abc.jpg,
+// the way TMDB's image CDN does. Posters are 2:3 and the colour is derived
+// from the file name, so different images can be told apart.
+func writeImage(w http.ResponseWriter, path string) {
+	size, name, ok := strings.Cut(path, "/")
+	if !ok || name == "" || strings.Contains(name, "/") {
+		writeError(w, http.StatusNotFound)
+		return
+	}
+
+	width := 2000
+	if size != "original" {
+		parsed, err := strconv.Atoi(strings.TrimPrefix(size, "w"))
+		if err != nil || !strings.HasPrefix(size, "w") || parsed <= 0 || parsed > 2000 {
+			writeError(w, http.StatusNotFound)
+			return
+		}
+		width = parsed
+	}
+
+	sum := sha256.Sum256([]byte(name))
+	img := image.NewRGBA(image.Rect(0, 0, width, width*3/2))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: color.RGBA{R: sum[0], G: sum[1], B: sum[2], A: 255}}, image.Point{}, draw.Src)
+
+	w.Header().Set("X-Cache", "Hit from cloudfront")
+	switch {
+	case strings.HasSuffix(name, ".png"):
+		w.Header().Set("Content-Type", "image/png")
+		png.Encode(w, img)
+	case strings.HasSuffix(name, ".jpg"), strings.HasSuffix(name, ".jpeg"):
+		w.Header().Set("Content-Type", "image/jpeg")
+		jpeg.Encode(w, img, nil)
+	default:
+		writeError(w, http.StatusNotFound)
+	}
+}
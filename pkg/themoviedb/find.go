@@ -0,0 +1,54 @@
+package themoviedb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// External id sources Find looks up.
+const (
+	ExternalIMDb = "imdb_id"
+	ExternalTVDB = "tvdb_id"
+)
+
+type findResp struct {
+	MovieResults []Movie       `json:"movie_results"`
+	TVResults    []multiResult `json:"tv_results"`
+}
+
+// Find looks up a movie or TV series by its id on another site, such as
+// an IMDb id. The result only has the fields of a search result, it is
+// ErrNotFound when TMDB knows no title with that id.
+func (m *MovieDBOptions) Find(ctx context.Context, externalID, source, language string) (*Movie, error) {
+	values := url.Values{}
+	values.Set("external_source", source)
+	if language != "" {
+		values.Set("language", language)
+	}
+
+	byteResults, err := m.get(ctx, fmt.Sprintf("find/%v?%v", url.PathEscape(externalID), values.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to find %v: %w", externalID, err)
+	}
+
+	resp := &findResp{}
+	err = json.Unmarshal(byteResults, resp)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode find results: %w", err)
+	}
+
+	if len(resp.MovieResults) > 0 {
+		movie := resp.MovieResults[0]
+		movie.MediaType = MediaMovie
+		return &movie, nil
+	}
+
+	if len(resp.TVResults) > 0 {
+		tv := resp.TVResults[0].tv()
+		return &tv, nil
+	}
+
+	return nil, fmt.Errorf("Failed to find %v: %w", externalID, ErrNotFound)
+}
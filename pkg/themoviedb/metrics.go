@@ -0,0 +1,139 @@
+package themoviedb
+
+import (
+	"expvar"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultMetrics is used by every client unless MovieDBOptions.Metrics is
+// replaced. It is published as the "themoviedb" expvar.
+var DefaultMetrics = NewMetrics()
+
+func init() {
+	expvar.Publish("themoviedb", expvar.Func(func() interface{} {
+		return DefaultMetrics.Snapshot()
+	}))
+}
+
+// Metrics counts outbound requests by method, route template and status,
+// and hits and misses of named caches.
+type Metrics struct {
+	mu       sync.Mutex
+	requests map[requestKey]*RequestStats
+	caches   map[string]*CacheStats
+}
+
+type requestKey struct {
+	Method string
+	Route  string
+	Status int
+}
+
+type RequestStats struct {
+	Method       string        `json:"method"`
+	Route        string        `json:"route"`
+	Status       int           `json:"status"`
+	Count        int64         `json:"count"`
+	TotalLatency time.Duration `json:"total_latency"`
+	MaxLatency   time.Duration `json:"max_latency"`
+	CacheHits    int64         `json:"cache_hits"`
+	CacheMisses  int64         `json:"cache_misses"`
+}
+
+type CacheStats struct {
+	Name   string `json:"name"`
+	Hits   int64  `json:"hits"`
+	Misses int64  `json:"misses"`
+}
+
+type MetricsSnapshot struct {
+	Requests []RequestStats `json:"requests"`
+	Caches   []CacheStats   `json:"caches"`
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests: make(map[requestKey]*RequestStats),
+		caches:   make(map[string]*CacheStats),
+	}
+}
+
+// ObserveRequest records one request. Status is 0 when no response was
+// received, cache is "hit", "miss" or empty when unknown.
+func (m *Metrics) ObserveRequest(method, route string, status int, latency time.Duration, cache string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := requestKey{Method: method, Route: route, Status: status}
+	stats, exists := m.requests[key]
+	if !exists {
+		stats = &RequestStats{Method: method, Route: route, Status: status}
+		m.requests[key] = stats
+	}
+
+	stats.Count++
+	stats.TotalLatency += latency
+	if latency > stats.MaxLatency {
+		stats.MaxLatency = latency
+	}
+
+	switch cache {
+	case "hit":
+		stats.CacheHits++
+	case "miss":
+		stats.CacheMisses++
+	}
+}
+
+// ObserveCache records a lookup in a cache in front of TMDB.
+func (m *Metrics) ObserveCache(name string, hit bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats, exists := m.caches[name]
+	if !exists {
+		stats = &CacheStats{Name: name}
+		m.caches[name] = stats
+	}
+
+	if hit {
+		stats.Hits++
+	} else {
+		stats.Misses++
+	}
+}
+
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := MetricsSnapshot{
+		Requests: make([]RequestStats, 0, len(m.requests)),
+		Caches:   make([]CacheStats, 0, len(m.caches)),
+	}
+
+	for _, stats := range m.requests {
+		snapshot.Requests = append(snapshot.Requests, *stats)
+	}
+	for _, stats := range m.caches {
+		snapshot.Caches = append(snapshot.Caches, *stats)
+	}
+
+	sort.Slice(snapshot.Requests, func(i, j int) bool {
+		a, b := snapshot.Requests[i], snapshot.Requests[j]
+		if a.Route != b.Route {
+			return a.Route < b.Route
+		}
+		if a.Method != b.Method {
+			return a.Method < b.Method
+		}
+		return a.Status < b.Status
+	})
+	sort.Slice(snapshot.Caches, func(i, j int) bool {
+		return snapshot.Caches[i].Name < snapshot.Caches[j].Name
+	})
+
+	return snapshot
+}
@@ -0,0 +1,222 @@
+package themoviedb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// SearchOptions filter a search. Zero values leave a filter out. TMDB
+// searches have no genre filter, Genres is applied to the returned page,
+// as Year is for multi searches.
+type SearchOptions struct {
+	Query        string
+	Page         uint
+	Year         uint
+	Language     string
+	Region       string
+	IncludeAdult bool
+	Genres       []uint
+}
+
+// DiscoverOptions browse TMDB's discover/movie. YearFrom and YearTo limit
+// the primary release year, both included. SortBy takes TMDB's sort
+// values such as vote_average.desc, MinVoteCount keeps barely rated
+// movies out of vote sorted lists.
+type DiscoverOptions struct {
+	Page         uint
+	Language     string
+	Region       string
+	IncludeAdult bool
+	Genres       []uint
+	YearFrom     uint
+	YearTo       uint
+	SortBy       string
+	MinVoteCount uint
+}
+
+func (o SearchOptions) values() url.Values {
+	values := url.Values{}
+	values.Set("query", o.Query)
+	values.Set("include_adult", strconv.FormatBool(o.IncludeAdult))
+	setPage(values, o.Page)
+	setLocale(values, o.Language, o.Region)
+	if o.Year > 0 {
+		values.Set("year", strconv.FormatUint(uint64(o.Year), 10))
+	}
+	return values
+}
+
+func (o DiscoverOptions) values() url.Values {
+	values := url.Values{}
+	values.Set("include_adult", strconv.FormatBool(o.IncludeAdult))
+	setPage(values, o.Page)
+	setLocale(values, o.Language, o.Region)
+
+	if len(o.Genres) > 0 {
+		genres := make([]string, len(o.Genres))
+		for i, genre := range o.Genres {
+			genres[i] = strconv.FormatUint(uint64(genre), 10)
+		}
+		values.Set("with_genres", strings.Join(genres, ","))
+	}
+	if o.YearFrom > 0 {
+		values.Set("primary_release_date.gte", fmt.Sprintf("%04d-01-01", o.YearFrom))
+	}
+	if o.YearTo > 0 {
+		values.Set("primary_release_date.lte", fmt.Sprintf("%04d-12-31", o.YearTo))
+	}
+	if o.SortBy != "" {
+		values.Set("sort_by", o.SortBy)
+	}
+	if o.MinVoteCount > 0 {
+		values.Set("vote_count.gte", strconv.FormatUint(uint64(o.MinVoteCount), 10))
+	}
+	return values
+}
+
+func setPage(values url.Values, page uint) {
+	if page > 0 {
+		values.Set("page", strconv.FormatUint(uint64(page), 10))
+	}
+}
+
+func setLocale(values url.Values, language, region string) {
+	if language != "" {
+		values.Set("language", language)
+	}
+	if region != "" {
+		values.Set("region", region)
+	}
+}
+
+func (m *MovieDBOptions) SearchMovies(ctx context.Context, searchTerm string) ([]Movie, error) {
+	resp, err := m.SearchMoviesPage(ctx, SearchOptions{Query: searchTerm})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Movies, nil
+}
+
+func (m *MovieDBOptions) SearchMoviesPage(ctx context.Context, opts SearchOptions) (*SearchMovieResp, error) {
+	byteMovies, err := m.get(ctx, "search/movie?"+opts.values().Encode())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get movie: %w", err)
+	}
+
+	resp := &SearchMovieResp{}
+	err = json.Unmarshal(byteMovies, resp)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode movies: %w", err)
+	}
+
+	for i := range resp.Movies {
+		resp.Movies[i].MediaType = MediaMovie
+	}
+	resp.Movies = filterGenres(resp.Movies, opts.Genres)
+
+	return resp, nil
+}
+
+// SearchMulti searches movies and TV series at once. People are left out.
+func (m *MovieDBOptions) SearchMulti(ctx context.Context, searchTerm string) ([]Movie, error) {
+	resp, err := m.SearchMultiPage(ctx, SearchOptions{Query: searchTerm})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Movies, nil
+}
+
+func (m *MovieDBOptions) SearchMultiPage(ctx context.Context, opts SearchOptions) (*SearchMovieResp, error) {
+	values := opts.values()
+	values.Del("year")
+
+	byteResults, err := m.get(ctx, "search/multi?"+values.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to search: %w", err)
+	}
+
+	multi := searchMultiResp{}
+	err = json.Unmarshal(byteResults, &multi)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode search results: %w", err)
+	}
+
+	resp := &SearchMovieResp{
+		Page:         multi.Page,
+		TotalPages:   multi.TotalPages,
+		TotalResults: multi.TotalResults,
+		Movies:       make([]Movie, 0, len(multi.Results)),
+	}
+
+	year := ""
+	if opts.Year > 0 {
+		year = fmt.Sprintf("%04d-", opts.Year)
+	}
+
+	for _, result := range multi.Results {
+		var movie Movie
+		switch result.MediaType {
+		case MediaMovie:
+			movie = result.Movie
+		case MediaTV:
+			movie = result.tv()
+		default:
+			continue
+		}
+
+		if strings.HasPrefix(movie.ReleaseDate, year) {
+			resp.Movies = append(resp.Movies, movie)
+		}
+	}
+	resp.Movies = filterGenres(resp.Movies, opts.Genres)
+
+	return resp, nil
+}
+
+func (m *MovieDBOptions) DiscoverMovies(ctx context.Context, opts DiscoverOptions) (*SearchMovieResp, error) {
+	byteMovies, err := m.get(ctx, "discover/movie?"+opts.values().Encode())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to discover movies: %w", err)
+	}
+
+	resp := &SearchMovieResp{}
+	err = json.Unmarshal(byteMovies, resp)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode movies: %w", err)
+	}
+
+	for i := range resp.Movies {
+		resp.Movies[i].MediaType = MediaMovie
+	}
+
+	return resp, nil
+}
+
+// filterGenres keeps the movies that have every genre in genres.
+func filterGenres(movies []Movie, genres []uint) []Movie {
+	if len(genres) == 0 {
+		return movies
+	}
+
+	filtered := make([]Movie, 0, len(movies))
+	for _, movie := range movies {
+		matches := 0
+		for _, genre := range genres {
+			for _, genreID := range movie.GenreIDs {
+				if genreID == genre {
+					matches++
+					break
+				}
+			}
+		}
+
+		if matches == len(genres) {
+			filtered = append(filtered, movie)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,170 @@
+package themoviedb
+
+import (
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const redacted = "REDACTED"
+
+var (
+	sensitiveHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+	sensitiveParams  = []string{"api_key", "apikey", "access_token", "session_id", "guest_session_id", "request_token", "token"}
+)
+
+// Transport logs outbound TMDB requests as structured records and records
+// them in Metrics. Credentials are redacted from the logged headers and
+// query parameters. Successful requests are logged with probability
+// SampleRate, failures are always logged.
+type Transport struct {
+	Base       http.RoundTripper
+	Logger     *slog.Logger
+	SampleRate float64
+	Metrics    *Metrics
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+	latency := time.Since(start)
+
+	route := RouteTemplate(req.URL.Path)
+
+	var status int
+	var cache string
+	if resp != nil {
+		status = resp.StatusCode
+		cache = cacheStatus(resp.Header.Get("X-Cache"))
+	}
+
+	if t.Metrics != nil {
+		t.Metrics.ObserveRequest(req.Method, route, status, latency, cache)
+	}
+
+	failed := err != nil || status >= 400
+	if !failed && !t.sampled() {
+		return resp, err
+	}
+
+	logger := t.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("url", RedactURL(req.URL)),
+		slog.String("route", route),
+		slog.Int("status", status),
+		slog.Duration("latency", latency),
+	}
+	if cache != "" {
+		attrs = append(attrs, slog.String("cache", cache))
+	}
+
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, slog.String("error", err.Error()))
+	} else if failed {
+		level = slog.LevelWarn
+	}
+
+	logger.LogAttrs(req.Context(), level, "themoviedb request", attrs...)
+
+	if logger.Enabled(req.Context(), slog.LevelDebug) {
+		logger.LogAttrs(req.Context(), slog.LevelDebug, "themoviedb request headers",
+			slog.String("url", RedactURL(req.URL)),
+			slog.Any("request_headers", RedactHeaders(req.Header)),
+			slog.Any("response_headers", responseHeaders(resp)),
+		)
+	}
+
+	return resp, err
+}
+
+func (t *Transport) sampled() bool {
+	if t.SampleRate >= 1 {
+		return true
+	}
+	if t.SampleRate <= 0 {
+		return false
+	}
+	return rand.Float64() < t.SampleRate
+}
+
+// RedactHeaders returns a copy of header with credentials replaced.
+func RedactHeaders(header http.Header) http.Header {
+	clean := header.Clone()
+	for _, name := range sensitiveHeaders {
+		if _, exists := clean[name]; exists {
+			clean[name] = []string{redacted}
+		}
+	}
+	return clean
+}
+
+// RedactURL returns u as a string with credential query parameters and
+// user info replaced.
+func RedactURL(u *url.URL) string {
+	clean := *u
+	if clean.User != nil {
+		clean.User = url.User(redacted)
+	}
+
+	query := clean.Query()
+	for _, name := range sensitiveParams {
+		if query.Has(name) {
+			query.Set(name, redacted)
+		}
+	}
+	clean.RawQuery = query.Encode()
+
+	return clean.String()
+}
+
+// RouteTemplate replaces the ids in a TMDB path so requests can be grouped,
+// /3/movie/550/credits becomes /3/movie/{id}/credits. External ids such as
+// /3/find/tt0133093 are replaced as well.
+func RouteTemplate(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		// The first segment is the api version.
+		if i < 2 || segment == "" {
+			continue
+		}
+		if segments[i-1] == "find" || strings.ContainsAny(segment, "0123456789") {
+			segments[i] = "{id}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// cacheStatus reads the CDN cache header TMDB responses carry, such as
+// "Hit from cloudfront".
+func cacheStatus(header string) string {
+	header = strings.ToLower(header)
+	switch {
+	case strings.HasPrefix(header, "hit"), strings.HasPrefix(header, "refreshhit"):
+		return "hit"
+	case strings.HasPrefix(header, "miss"):
+		return "miss"
+	}
+	return ""
+}
+
+func responseHeaders(resp *http.Response) http.Header {
+	if resp == nil {
+		return nil
+	}
+	return RedactHeaders(resp.Header)
+}
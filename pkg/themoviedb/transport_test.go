@@ -0,0 +1,42 @@
+package themoviedb
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRouteTemplate(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/3/movie/550", "/3/movie/{id}"},
+		{"/3/movie/550/credits", "/3/movie/{id}/credits"},
+		{"/3/tv/1399/season/1", "/3/tv/{id}/season/{id}"},
+		{"/3/find/tt0133093", "/3/find/{id}"},
+		{"/3/find/nm0000206", "/3/find/{id}"},
+		{"/3/find/thematrix", "/3/find/{id}"},
+		{"/3/search/movie", "/3/search/movie"},
+		{"/3/watch/providers/movie", "/3/watch/providers/movie"},
+	}
+
+	for _, test := range tests {
+		got := RouteTemplate(test.path)
+		if got != test.want {
+			t.Errorf("RouteTemplate(%q) = %q, want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	u, err := url.Parse("https://www.omdbapi.com/?apikey=secret&i=tt0133093")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	got := RedactURL(u)
+	if strings.Contains(got, "secret") || !strings.Contains(got, "i=tt0133093") {
+		t.Errorf("RedactURL = %q, want the api key redacted and the id kept", got)
+	}
+}
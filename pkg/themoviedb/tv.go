@@ -0,0 +1,105 @@
+package themoviedb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+type Season struct {
+	ID           uint   `json:"id"`
+	SeasonNumber uint   `json:"season_number"`
+	Name         string `json:"name"`
+	Overview     string `json:"overview"`
+	AirDate      string `json:"air_date"`
+	EpisodeCount uint   `json:"episode_count"`
+	Poster       string `json:"poster_path"`
+}
+
+// tvResp is a TV series requested with
+// append_to_response=credits,watch/providers,external_ids.
+type tvResp struct {
+	ID             uint               `json:"id"`
+	OriginalName   string             `json:"original_name"`
+	Name           string             `json:"name"`
+	Overview       string             `json:"overview"`
+	Poster         string             `json:"poster_path"`
+	Backdrop       string             `json:"backdrop_path"`
+	FirstAirDate   string             `json:"first_air_date"`
+	Genres         []Genre            `json:"genres"`
+	EpisodeRunTime []uint             `json:"episode_run_time"`
+	VoteAverage    float64            `json:"vote_average"`
+	CreatedBy      []CrewMember       `json:"created_by"`
+	Seasons        []Season           `json:"seasons"`
+	Credits        Credits            `json:"credits"`
+	WatchProviders watchProvidersResp `json:"watch/providers"`
+	ExternalIDs    struct {
+		IMDbID string `json:"imdb_id"`
+	} `json:"external_ids"`
+}
+
+// multiResult is a search/multi result, movies carry titles and TV series
+// names.
+type multiResult struct {
+	Movie
+	OriginalName string `json:"original_name"`
+	Name         string `json:"name"`
+	FirstAirDate string `json:"first_air_date"`
+}
+
+func (r multiResult) tv() Movie {
+	tv := r.Movie
+	tv.MediaType = MediaTV
+	tv.Title = r.OriginalName
+	tv.LocalizedTitle = r.Name
+	tv.ReleaseDate = r.FirstAirDate
+	return tv
+}
+
+type searchMultiResp struct {
+	Page         uint          `json:"page"`
+	TotalPages   uint          `json:"total_pages"`
+	TotalResults uint          `json:"total_results"`
+	Results      []multiResult `json:"results"`
+}
+
+// GetTV looks up a TV series in language, TMDB's default when it is empty.
+func (m *MovieDBOptions) GetTV(ctx context.Context, tvID uint, language string) (*Movie, error) {
+	values := lookupValues(language)
+	values.Set("append_to_response", values.Get("append_to_response")+",external_ids")
+
+	byteTV, err := m.get(ctx, fmt.Sprintf("tv/%v?%v", tvID, values.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get tv series: %w", err)
+	}
+
+	resp := &tvResp{}
+	err = json.Unmarshal(byteTV, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode tv series: %w", err)
+	}
+
+	tv := &Movie{
+		ID:             resp.ID,
+		MediaType:      MediaTV,
+		Title:          resp.OriginalName,
+		LocalizedTitle: resp.Name,
+		Overview:       resp.Overview,
+		Poster:         resp.Poster,
+		Backdrop:       resp.Backdrop,
+		ReleaseDate:    resp.FirstAirDate,
+		Genres:         resp.Genres,
+		VoteAverage:    resp.VoteAverage,
+		Cast:           topCast(resp.Credits.Cast),
+		Directors:      directors(resp.Credits.Crew),
+		Creators:       resp.CreatedBy,
+		Seasons:        resp.Seasons,
+		WatchProviders: resp.WatchProviders.Results,
+		IMDbID:         resp.ExternalIDs.IMDbID,
+	}
+	if len(resp.EpisodeRunTime) > 0 {
+		tv.Runtime = resp.EpisodeRunTime[0]
+	}
+
+	return tv, nil
+}
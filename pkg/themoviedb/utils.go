@@ -1,33 +1,79 @@
 package themoviedb
 
 import (
-	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
-var (
-	baseUrl = "https://api.themoviedb.org"
+const (
+	DefaultBaseURL      = "https://api.themoviedb.org"
+	DefaultTimeout      = time.Second * 10
+	DefaultRateLimit    = 40
+	DefaultBurst        = 20
+	DefaultMaxRetries   = 3
+	DefaultRetryBackoff = time.Millisecond * 500
+	DefaultMaxRetryWait = time.Second * 10
 )
 
+// MovieDBOptions configures the TMDB client. BaseURL can point at another
+// TMDB compatible server, such as the fake package in tests. HTTPClient
+// replaces the default client, Timeout is only used by the default one.
+//
+// Requests are limited to RateLimit per second with bursts of Burst. 429
+// and 5xx responses are retried up to MaxRetries times with exponential
+// backoff starting at RetryBackoff, or after Retry-After when the server
+// sends it. A Retry-After longer than MaxRetryWait is not waited for.
+//
+// The options are read on the first request, share one MovieDBOptions
+// between callers so they share the connection pool and rate limit.
 type MovieDBOptions struct {
-	AuthToken  string `json:"authtoken"`
-	ApiKey     string `json:"apikey"`
-	ApiVersion uint8  `json:"version"`
-}
-
-type LoggingTransport struct {
-	Transport http.RoundTripper
+	AuthToken    string        `json:"authtoken"`
+	ApiKey       string        `json:"apikey"`
+	ApiVersion   uint8         `json:"version"`
+	BaseURL      string        `json:"baseurl"`
+	Timeout      time.Duration `json:"timeout"`
+	HTTPClient   *http.Client  `json:"-"`
+	RateLimit    float64       `json:"ratelimit"`
+	Burst        int           `json:"burst"`
+	MaxRetries   int           `json:"maxretries"`
+	RetryBackoff time.Duration `json:"retrybackoff"`
+	MaxRetryWait time.Duration `json:"maxretrywait"`
+
+	// Logger, LogSampleRate and Metrics configure the Transport of the
+	// default client.
+	Logger        *slog.Logger `json:"-"`
+	LogSampleRate float64      `json:"logsamplerate"`
+	Metrics       *Metrics     `json:"-"`
+
+	once       sync.Once
+	httpClient *http.Client
+	limiter    *tokenBucket
 }
 
 func NewMovieDBOptions(authToken string, apiKey string, apiVersion ...uint8) *MovieDBOptions {
 	mo := &MovieDBOptions{
-		AuthToken:  authToken,
-		ApiKey:     apiKey,
-		ApiVersion: 3,
+		AuthToken:    authToken,
+		ApiKey:       apiKey,
+		ApiVersion:   3,
+		BaseURL:      DefaultBaseURL,
+		Timeout:      DefaultTimeout,
+		RateLimit:    DefaultRateLimit,
+		Burst:        DefaultBurst,
+		MaxRetries:   DefaultMaxRetries,
+		RetryBackoff: DefaultRetryBackoff,
+		MaxRetryWait: DefaultMaxRetryWait,
+
+		LogSampleRate: 1,
+		Metrics:       DefaultMetrics,
 	}
 
 	if len(apiVersion) > 0 {
@@ -59,96 +105,122 @@ func (m *MovieDBOptions) setHeaders(request *http.Request) error {
 	return nil
 }
 
-func (m *MovieDBOptions) get(path string) ([]byte, error) {
-	client := &http.Client{
-		Transport: &LoggingTransport{
-			Transport: http.DefaultTransport,
-		},
+func (m *MovieDBOptions) init() {
+	m.once.Do(func() {
+		m.httpClient = m.HTTPClient
+		if m.httpClient == nil {
+			m.httpClient = &http.Client{
+				Timeout: m.Timeout,
+				Transport: &Transport{
+					Base:       http.DefaultTransport,
+					Logger:     m.Logger,
+					SampleRate: m.LogSampleRate,
+					Metrics:    m.Metrics,
+				},
+			}
+		}
+
+		m.limiter = newTokenBucket(m.RateLimit, m.Burst)
+	})
+}
+
+// get requests path and returns the body of a 2xx response. Any other
+// status is returned as an *APIError.
+func (m *MovieDBOptions) get(ctx context.Context, path string) ([]byte, error) {
+	m.init()
+
+	baseURL := strings.TrimSuffix(m.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
 	}
 
-	url := fmt.Sprintf("%v/%v/%v", baseUrl, m.ApiVersion, path)
+	url := fmt.Sprintf("%v/%v/%v", baseURL, m.ApiVersion, path)
 
-	request, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
+	for attempt := 0; ; attempt++ {
+		err := m.limiter.Wait(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := m.do(ctx, url)
+		if err == nil {
+			return body, nil
+		}
+
+		var apiError *APIError
+		if !errors.As(err, &apiError) || !apiError.retryable() || attempt >= m.MaxRetries {
+			return nil, err
+		}
+
+		wait := m.RetryBackoff * time.Duration(1<<attempt)
+		if apiError.RetryAfter > 0 {
+			wait = apiError.RetryAfter
+		}
+
+		if m.MaxRetryWait > 0 && wait > m.MaxRetryWait {
+			return nil, err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
 	}
+}
 
-	err = m.setHeaders(request)
+func (m *MovieDBOptions) do(ctx context.Context, url string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	response, err := client.Do(request)
+	err = m.setHeaders(request)
 	if err != nil {
 		return nil, err
 	}
 
-	body, err := io.ReadAll(response.Body)
+	response, err := m.httpClient.Do(request)
 	if err != nil {
 		return nil, err
 	}
-
 	defer response.Body.Close()
 
-	return body, nil
-}
-
-func (t *LoggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	start := time.Now()
-	log.Printf("Outgoing HTTP request: %s %s", req.Method, req.URL.String())
-
-	logRequestHeaders(req)
-
-	logRequestBody(req)
-
-	resp, err := t.Transport.RoundTrip(req)
-
+	body, err := io.ReadAll(response.Body)
 	if err != nil {
-		log.Printf("Error making HTTP request: %v", err)
 		return nil, err
 	}
 
-	elapsed := time.Since(start)
-	log.Printf("HTTP response status: %s", resp.Status)
-
-	logResponseHeaders(resp)
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return body, nil
+	}
 
-	log.Printf("HTTP request took: %v", elapsed)
+	apiError := &APIError{
+		StatusCode: response.StatusCode,
+		RetryAfter: parseRetryAfter(response.Header.Get("Retry-After")),
+	}
+	json.Unmarshal(body, apiError)
 
-	return resp, nil
+	return nil, apiError
 }
 
-func logRequestHeaders(req *http.Request) {
-	log.Println("Request Headers:")
-	for key, values := range req.Header {
-		for _, value := range values {
-			log.Printf("%s: %s", key, value)
-		}
+// parseRetryAfter accepts both the seconds and the HTTP date form.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
 	}
-}
 
-func logRequestBody(req *http.Request) {
-	if req.Body == nil {
-		log.Println("Request Body: <empty>")
-		return
+	seconds, err := strconv.Atoi(value)
+	if err == nil {
+		return time.Duration(seconds) * time.Second
 	}
 
-	body, err := io.ReadAll(req.Body)
-	if err != nil {
-		log.Printf("Error reading request body: %v", err)
-		return
+	date, err := http.ParseTime(value)
+	if err == nil {
+		return time.Until(date)
 	}
 
-	log.Printf("Request Body: %s", body)
-
-	req.Body = io.NopCloser(bytes.NewReader(body))
-}
-
-func logResponseHeaders(resp *http.Response) {
-	log.Println("Response Headers:")
-	for key, values := range resp.Header {
-		for _, value := range values {
-			log.Printf("%s: %s", key, value)
-		}
-	}
+	return 0
 }
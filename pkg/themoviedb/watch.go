@@ -0,0 +1,39 @@
+package themoviedb
+
+// WatchProvider is a streaming service, store or channel as listed by
+// JustWatch through TMDB.
+type WatchProvider struct {
+	ID              uint   `json:"provider_id"`
+	Name            string `json:"provider_name"`
+	Logo            string `json:"logo_path"`
+	DisplayPriority int    `json:"display_priority"`
+}
+
+// RegionProviders are the ways to watch a movie or TV series in one
+// region. Flatrate providers stream it as part of a subscription.
+type RegionProviders struct {
+	Link     string          `json:"link"`
+	Flatrate []WatchProvider `json:"flatrate,omitempty"`
+	Free     []WatchProvider `json:"free,omitempty"`
+	Ads      []WatchProvider `json:"ads,omitempty"`
+	Rent     []WatchProvider `json:"rent,omitempty"`
+	Buy      []WatchProvider `json:"buy,omitempty"`
+}
+
+// WatchProviders maps ISO 3166-1 regions to their providers.
+type WatchProviders map[string]RegionProviders
+
+type watchProvidersResp struct {
+	ID      uint           `json:"id"`
+	Results WatchProviders `json:"results"`
+}
+
+// Region returns the providers of region alone, so responses don't carry
+// every region TMDB knows about.
+func (p WatchProviders) Region(region string) WatchProviders {
+	providers, exists := p[region]
+	if !exists {
+		return nil
+	}
+	return WatchProviders{region: providers}
+}
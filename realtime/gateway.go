@@ -0,0 +1,538 @@
+package realtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/adamelfsborg-code/movie-nest/data"
+	"github.com/adamelfsborg-code/movie-nest/events"
+	"github.com/google/uuid"
+)
+
+const (
+	TopicRoom  = "room"
+	TopicShelf = "shelf"
+	TopicMovie = "movie"
+	TopicUser  = "user"
+)
+
+const (
+	MessageSubscribe    = "subscribe"
+	MessageUnsubscribe  = "unsubscribe"
+	MessageSubscribed   = "subscribed"
+	MessageUnsubscribed = "unsubscribed"
+	MessagePing         = "ping"
+	MessagePong         = "pong"
+	MessagePresence     = "presence"
+	MessageTyping       = "typing"
+	MessageEvent        = "event"
+	MessageError        = "error"
+)
+
+// ClientMessage is sent by websocket clients.
+//
+//	{"type":"subscribe","topic":"shelf","id":"<shelf id>"}
+//	{"type":"unsubscribe","topic":"shelf","id":"<shelf id>"}
+//	{"type":"ping"}
+//	{"type":"presence","room_id":"<room id>","status":"online"}
+//	{"type":"typing","room_id":"<room id>","typing":true}
+type ClientMessage struct {
+	Type   string    `json:"type"`
+	Topic  string    `json:"topic"`
+	ID     uuid.UUID `json:"id"`
+	RoomID uuid.UUID `json:"room_id"`
+	Status string    `json:"status"`
+	Typing bool      `json:"typing"`
+}
+
+// ServerMessage is sent to websocket clients. Event holds the envelope
+// of a room event for the subscription identified by Topic and ID.
+// Invitations are sent to the invited user without a subscription, with
+// the user topic and the user's id.
+type ServerMessage struct {
+	Type    string          `json:"type"`
+	Topic   string          `json:"topic,omitempty"`
+	ID      *uuid.UUID      `json:"id,omitempty"`
+	RoomID  *uuid.UUID      `json:"room_id,omitempty"`
+	UserID  *uuid.UUID      `json:"user_id,omitempty"`
+	Status  string          `json:"status,omitempty"`
+	Typing  *bool           `json:"typing,omitempty"`
+	Reason  string          `json:"reason,omitempty"`
+	Event   json.RawMessage `json:"event,omitempty"`
+	Message string          `json:"message,omitempty"`
+}
+
+// seenSize is how many event ids the gateway remembers to drop events the
+// outbox published again.
+const seenSize = 1024
+
+type subscriptionKey struct {
+	Topic string
+	ID    uuid.UUID
+}
+
+// Gateway routes room events to websocket connections. Every
+// subscription is authorized against the stores, and is re-checked
+// whenever the membership of its user changes, as soon as a revocation
+// arrives and again with the room event.
+type Gateway struct {
+	Bus        events.Bus
+	Stores     data.Stores
+	BufferSize int
+
+	mu            sync.Mutex
+	conns         map[*Conn]struct{}
+	subscriptions []events.Subscription
+	seen          map[string]struct{}
+	seenOrder     []string
+}
+
+type Conn struct {
+	UserID uuid.UUID
+	Send   chan ServerMessage
+
+	subscriptions map[subscriptionKey]struct{}
+	closed        bool
+}
+
+type presenceMessage struct {
+	Type   string    `json:"type"`
+	RoomID uuid.UUID `json:"room_id"`
+	UserID uuid.UUID `json:"user_id"`
+	Status string    `json:"status,omitempty"`
+	Typing bool      `json:"typing"`
+}
+
+func NewGateway(bus events.Bus, stores data.Stores) *Gateway {
+	return &Gateway{
+		Bus:        bus,
+		Stores:     stores,
+		BufferSize: DefaultBufferSize,
+		conns:      make(map[*Conn]struct{}),
+		seen:       make(map[string]struct{}),
+	}
+}
+
+func (g *Gateway) Start() error {
+	roomSubscription, err := g.Bus.Subscribe("rooms.>", g.dispatch)
+	if err != nil {
+		return fmt.Errorf("Failed to subscribe to room events: %w", err)
+	}
+
+	presenceSubscription, err := g.Bus.Subscribe("presence.rooms.>", g.dispatchPresence)
+	if err != nil {
+		roomSubscription.Unsubscribe()
+		return fmt.Errorf("Failed to subscribe to presence: %w", err)
+	}
+
+	revocationSubscription, err := g.Bus.Subscribe(events.RevocationSubjects, g.dispatchRevocation)
+	if err != nil {
+		roomSubscription.Unsubscribe()
+		presenceSubscription.Unsubscribe()
+		return fmt.Errorf("Failed to subscribe to revocations: %w", err)
+	}
+
+	g.mu.Lock()
+	g.subscriptions = []events.Subscription{roomSubscription, presenceSubscription, revocationSubscription}
+	g.mu.Unlock()
+
+	return nil
+}
+
+func (g *Gateway) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for conn := range g.conns {
+		g.close(conn)
+	}
+
+	for _, subscription := range g.subscriptions {
+		subscription.Unsubscribe()
+	}
+	g.subscriptions = nil
+
+	return nil
+}
+
+func (g *Gateway) Connect(userID uuid.UUID) *Conn {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	conn := &Conn{
+		UserID:        userID,
+		Send:          make(chan ServerMessage, g.BufferSize),
+		subscriptions: make(map[subscriptionKey]struct{}),
+	}
+	g.conns[conn] = struct{}{}
+
+	return conn
+}
+
+func (g *Gateway) Disconnect(conn *Conn) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.close(conn)
+}
+
+// Handle processes one message from the client.
+func (g *Gateway) Handle(conn *Conn, message ClientMessage) {
+	switch message.Type {
+	case MessagePing:
+		g.send(conn, ServerMessage{Type: MessagePong})
+	case MessageSubscribe:
+		g.subscribe(conn, message)
+	case MessageUnsubscribe:
+		key := subscriptionKey{Topic: message.Topic, ID: message.ID}
+
+		g.mu.Lock()
+		delete(conn.subscriptions, key)
+		g.mu.Unlock()
+
+		g.send(conn, ServerMessage{Type: MessageUnsubscribed, Topic: key.Topic, ID: &key.ID})
+	case MessagePresence, MessageTyping:
+		g.publishPresence(conn, message)
+	default:
+		g.send(conn, ServerMessage{Type: MessageError, Message: fmt.Sprintf("Unknown message type: %q", message.Type)})
+	}
+}
+
+func (g *Gateway) subscribe(conn *Conn, message ClientMessage) {
+	key := subscriptionKey{Topic: message.Topic, ID: message.ID}
+
+	allowed, err := g.access(key, conn.UserID)
+	if err != nil {
+		fmt.Println("Failed to check access: ", err)
+	}
+
+	if !allowed {
+		g.send(conn, ServerMessage{Type: MessageError, Topic: key.Topic, ID: &key.ID, Message: "Permission Not Allowd"})
+		return
+	}
+
+	g.mu.Lock()
+	if !conn.closed {
+		conn.subscriptions[key] = struct{}{}
+	}
+	g.mu.Unlock()
+
+	g.send(conn, ServerMessage{Type: MessageSubscribed, Topic: key.Topic, ID: &key.ID})
+}
+
+func (g *Gateway) access(key subscriptionKey, userID uuid.UUID) (bool, error) {
+	var role data.Role
+	var err error
+
+	switch key.Topic {
+	case TopicRoom:
+		role, err = g.Stores.Rooms.GetRoomRole(key.ID, userID)
+	case TopicShelf:
+		role, err = g.Stores.Shelves.GetShelfRole(key.ID, userID)
+	case TopicMovie:
+		role, err = g.Stores.Movies.GetMovieRole(key.ID, userID)
+	default:
+		return false, fmt.Errorf("Unknown topic: %q", key.Topic)
+	}
+
+	return role.Can(data.PermissionView), err
+}
+
+func (g *Gateway) publishPresence(conn *Conn, message ClientMessage) {
+	role, err := g.Stores.Rooms.GetRoomRole(message.RoomID, conn.UserID)
+	if err != nil || !role.Can(data.PermissionView) {
+		g.send(conn, ServerMessage{Type: MessageError, RoomID: &message.RoomID, Message: "Permission Not Allowd"})
+		return
+	}
+
+	data, err := json.Marshal(presenceMessage{
+		Type:   message.Type,
+		RoomID: message.RoomID,
+		UserID: conn.UserID,
+		Status: message.Status,
+		Typing: message.Typing,
+	})
+	if err != nil {
+		fmt.Println("Failed to encode presence: ", err)
+		return
+	}
+
+	err = g.Bus.Publish(events.Event{
+		Subject: fmt.Sprintf("presence.rooms.%v.%v", message.RoomID, conn.UserID),
+		Data:    data,
+	})
+	if err != nil {
+		fmt.Println("Failed to publish presence: ", err)
+	}
+}
+
+func (g *Gateway) dispatch(event events.Event) {
+	tokens := strings.Split(event.Subject, ".")
+	if len(tokens) < 3 || g.redelivered(event.ID) {
+		return
+	}
+
+	// Membership changed, drop whatever the user may no longer see before
+	// the event itself is delivered.
+	if tokens[2] == "members" && len(tokens) > 3 {
+		userID, err := uuid.Parse(tokens[3])
+		if err == nil {
+			g.revalidate(userID)
+		}
+	}
+
+	// Invitations and membership changes also reach the user they are
+	// about, who may not be subscribed to the room (anymore).
+	var recipient uuid.UUID
+	if (tokens[2] == "invitations" || tokens[2] == "members") && len(tokens) > 3 {
+		recipient, _ = uuid.Parse(tokens[3])
+	}
+
+	// A deleted room or shelf or a removed movie is gone for everyone who
+	// got the event, their subscriptions are dropped once it is delivered.
+	subscribers := make(map[uuid.UUID]struct{})
+	verb := tokens[len(tokens)-1]
+	if verb == "deleted" || (verb == "removed" && tokens[2] == "shelves") {
+		defer func() {
+			for userID := range subscribers {
+				g.revalidate(userID)
+			}
+		}()
+	}
+
+	keys := subjectKeys(tokens)
+	if verb == "moved" && tokens[2] == "shelves" {
+		keys = append(keys, movedFromKeys(event)...)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for conn := range g.conns {
+		delivered := false
+		for _, key := range keys {
+			if _, exists := conn.subscriptions[key]; !exists {
+				continue
+			}
+
+			id := key.ID
+			g.sendLocked(conn, ServerMessage{
+				Type:  MessageEvent,
+				Topic: key.Topic,
+				ID:    &id,
+				Event: event.Data,
+			})
+			delivered = true
+			subscribers[conn.UserID] = struct{}{}
+		}
+
+		if !delivered && recipient != uuid.Nil && conn.UserID == recipient {
+			g.sendLocked(conn, ServerMessage{
+				Type:  MessageEvent,
+				Topic: TopicUser,
+				ID:    &recipient,
+				Event: event.Data,
+			})
+		}
+	}
+}
+
+// redelivered records id and tells whether it was seen before.
+func (g *Gateway) redelivered(id string) bool {
+	if id == "" {
+		return false
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, exists := g.seen[id]; exists {
+		return true
+	}
+
+	g.seen[id] = struct{}{}
+	g.seenOrder = append(g.seenOrder, id)
+	if len(g.seenOrder) > seenSize {
+		delete(g.seen, g.seenOrder[0])
+		g.seenOrder = g.seenOrder[1:]
+	}
+	return false
+}
+
+func (g *Gateway) dispatchPresence(event events.Event) {
+	var presence presenceMessage
+	err := json.Unmarshal(event.Data, &presence)
+	if err != nil {
+		fmt.Println("Failed to decode presence: ", err)
+		return
+	}
+
+	key := subscriptionKey{Topic: TopicRoom, ID: presence.RoomID}
+	message := ServerMessage{
+		Type:   presence.Type,
+		RoomID: &presence.RoomID,
+		UserID: &presence.UserID,
+		Status: presence.Status,
+	}
+	if presence.Type == MessageTyping {
+		message.Typing = &presence.Typing
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for conn := range g.conns {
+		if conn.UserID == presence.UserID {
+			continue
+		}
+		if _, exists := conn.subscriptions[key]; exists {
+			g.sendLocked(conn, message)
+		}
+	}
+}
+
+// dispatchRevocation drops the subscriptions of a user who lost access to
+// a room without waiting for the outbox to deliver the room event.
+func (g *Gateway) dispatchRevocation(event events.Event) {
+	tokens := strings.Split(event.Subject, ".")
+	if len(tokens) != 5 {
+		return
+	}
+
+	userID, err := uuid.Parse(tokens[4])
+	if err != nil {
+		return
+	}
+
+	g.revalidate(userID)
+}
+
+// revalidate re-runs the access checks for every subscription the user
+// holds and removes the ones that are no longer allowed.
+func (g *Gateway) revalidate(userID uuid.UUID) {
+	g.mu.Lock()
+	var conns []*Conn
+	var keys []subscriptionKey
+	seen := make(map[subscriptionKey]struct{})
+	for conn := range g.conns {
+		if conn.UserID != userID {
+			continue
+		}
+		conns = append(conns, conn)
+		for key := range conn.subscriptions {
+			if _, exists := seen[key]; !exists {
+				seen[key] = struct{}{}
+				keys = append(keys, key)
+			}
+		}
+	}
+	g.mu.Unlock()
+
+	var revoked []subscriptionKey
+	for _, key := range keys {
+		allowed, err := g.access(key, userID)
+		if err != nil {
+			fmt.Println("Failed to check access: ", err)
+		}
+		if !allowed {
+			revoked = append(revoked, key)
+		}
+	}
+
+	if len(revoked) == 0 {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, conn := range conns {
+		for _, key := range revoked {
+			if _, exists := conn.subscriptions[key]; !exists {
+				continue
+			}
+
+			delete(conn.subscriptions, key)
+
+			id := key.ID
+			g.sendLocked(conn, ServerMessage{
+				Type:   MessageUnsubscribed,
+				Topic:  key.Topic,
+				ID:     &id,
+				Reason: "revoked",
+			})
+		}
+	}
+}
+
+func (g *Gateway) send(conn *Conn, message ServerMessage) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.sendLocked(conn, message)
+}
+
+// sendLocked never blocks, a connection whose buffer is full is closed.
+func (g *Gateway) sendLocked(conn *Conn, message ServerMessage) {
+	if conn.closed {
+		return
+	}
+
+	select {
+	case conn.Send <- message:
+	default:
+		g.close(conn)
+	}
+}
+
+func (g *Gateway) close(conn *Conn) {
+	delete(g.conns, conn)
+	if conn.closed {
+		return
+	}
+	conn.closed = true
+	close(conn.Send)
+}
+
+// subjectKeys lists the subscriptions an event on
+// rooms.{room_id}.shelves.{shelf_id}.movies.{movie_id}.<verb> is delivered to.
+func subjectKeys(tokens []string) []subscriptionKey {
+	var keys []subscriptionKey
+
+	if id, err := uuid.Parse(tokens[1]); err == nil {
+		keys = append(keys, subscriptionKey{Topic: TopicRoom, ID: id})
+	}
+
+	if len(tokens) > 4 && tokens[2] == "shelves" {
+		if id, err := uuid.Parse(tokens[3]); err == nil {
+			keys = append(keys, subscriptionKey{Topic: TopicShelf, ID: id})
+		}
+	}
+
+	if len(tokens) > 6 && tokens[4] == "movies" {
+		if id, err := uuid.Parse(tokens[5]); err == nil {
+			keys = append(keys, subscriptionKey{Topic: TopicMovie, ID: id})
+		}
+	}
+
+	return keys
+}
+
+// movedFromKeys lists the shelf a moved movie left. The event is published
+// under the shelf it was moved to, subscribers of the other one have to
+// see the movie go as well.
+func movedFromKeys(event events.Event) []subscriptionKey {
+	_, payload, err := events.Decode(event.Data)
+	if err != nil {
+		fmt.Println("Failed to decode event: ", err)
+		return nil
+	}
+
+	moved, ok := payload.(*events.ShelfMovieMoved)
+	if !ok || moved.FromShelfID == uuid.Nil || moved.FromShelfID == moved.ShelfID {
+		return nil
+	}
+	return []subscriptionKey{{Topic: TopicShelf, ID: moved.FromShelfID}}
+}
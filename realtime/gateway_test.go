@@ -0,0 +1,159 @@
+package realtime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adamelfsborg-code/movie-nest/config"
+	"github.com/adamelfsborg-code/movie-nest/data"
+	"github.com/adamelfsborg-code/movie-nest/events"
+	"github.com/google/uuid"
+)
+
+func receiveMessage(t *testing.T, conn *Conn) ServerMessage {
+	t.Helper()
+
+	select {
+	case message := <-conn.Send:
+		return message
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for a message")
+		return ServerMessage{}
+	}
+}
+
+// newTestGateway starts a gateway over memory stores holding a room with
+// two shelves owned by the returned user.
+func newTestGateway(t *testing.T) (*Gateway, *events.ChannelBus, uuid.UUID, uuid.UUID, []uuid.UUID) {
+	t.Helper()
+
+	bus := events.NewChannelBus()
+	t.Cleanup(func() { bus.Close() })
+
+	stores := data.NewMemoryStores(config.Environments{}, data.NewMemoryDB(), events.NewNoopBus())
+
+	user := data.User{ID: uuid.New(), Name: "owner", Password: "secret"}
+	err := stores.Users.Register(user)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	room := data.NewRoom("Movie night")
+	room.ID = uuid.New()
+	err = stores.Rooms.CreateRoom(*room, user.ID)
+	if err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+
+	var shelfIDs []uuid.UUID
+	for _, name := range []string{"Watchlist", "Watched"} {
+		shelf := data.NewShelf(name, room.ID)
+		shelf.ID = uuid.New()
+		err = stores.Shelves.CreateShelf(*shelf, user.ID)
+		if err != nil {
+			t.Fatalf("CreateShelf: %v", err)
+		}
+		shelfIDs = append(shelfIDs, shelf.ID)
+	}
+
+	gateway := NewGateway(bus, stores)
+	err = gateway.Start()
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { gateway.Close() })
+
+	return gateway, bus, user.ID, room.ID, shelfIDs
+}
+
+func subscribeConn(t *testing.T, gateway *Gateway, userID uuid.UUID, topic string, id uuid.UUID) *Conn {
+	t.Helper()
+
+	conn := gateway.Connect(userID)
+	gateway.Handle(conn, ClientMessage{Type: MessageSubscribe, Topic: topic, ID: id})
+	if message := receiveMessage(t, conn); message.Type != MessageSubscribed {
+		t.Fatalf("Subscribe to %v %v = %+v, want %v", topic, id, message, MessageSubscribed)
+	}
+	return conn
+}
+
+func TestGatewayDeliversMovesToBothShelves(t *testing.T) {
+	gateway, bus, userID, roomID, shelfIDs := newTestGateway(t)
+	from, to := shelfIDs[0], shelfIDs[1]
+
+	source := subscribeConn(t, gateway, userID, TopicShelf, from)
+	target := subscribeConn(t, gateway, userID, TopicShelf, to)
+
+	publishRoomEvent(t, bus, roomID, &events.ShelfMovieMoved{
+		ID:          uuid.New(),
+		RoomID:      roomID,
+		ShelfID:     to,
+		FromShelfID: from,
+		MediaType:   "movie",
+		MovieID:     603,
+	})
+
+	tests := []struct {
+		name    string
+		conn    *Conn
+		shelfID uuid.UUID
+	}{
+		{"source", source, from},
+		{"target", target, to},
+	}
+
+	for _, test := range tests {
+		message := receiveMessage(t, test.conn)
+		if message.Type != MessageEvent || message.Topic != TopicShelf || message.ID == nil || *message.ID != test.shelfID {
+			t.Errorf("%v shelf subscriber got %+v, want the move on shelf %v", test.name, message, test.shelfID)
+		}
+	}
+}
+
+func TestGatewayDropsRedeliveredEvents(t *testing.T) {
+	gateway, bus, userID, roomID, _ := newTestGateway(t)
+	conn := subscribeConn(t, gateway, userID, TopicRoom, roomID)
+
+	event, err := events.NewEnvelopeEvent(uuid.New(), roomID, &events.RoomUpdated{ID: roomID, Name: "Movie night"})
+	if err != nil {
+		t.Fatalf("NewEnvelopeEvent: %v", err)
+	}
+
+	// The outbox could not confirm the first publish and sent it again.
+	bus.Publish(event)
+	bus.Publish(event)
+	publishRoomEvent(t, bus, roomID, &events.RoomArchived{ID: roomID})
+
+	first := receiveMessage(t, conn)
+	second := receiveMessage(t, conn)
+
+	envelope, err := events.DecodeEnvelope(second.Event)
+	if err != nil {
+		t.Fatalf("DecodeEnvelope: %v", err)
+	}
+	if string(first.Event) != string(event.Data) || envelope.Type != events.TypeRoomArchived {
+		t.Errorf("Got %s and %s, want the update once and then the archive", first.Event, second.Event)
+	}
+}
+
+func TestGatewayRevokesWithoutRoomEvent(t *testing.T) {
+	gateway, bus, userID, roomID, _ := newTestGateway(t)
+	conn := subscribeConn(t, gateway, userID, TopicRoom, roomID)
+
+	// The stores publish their events on a noop bus, only the revocation
+	// reaches the gateway.
+	err := gateway.Stores.Rooms.DeleteRoom(roomID, userID)
+	if err != nil {
+		t.Fatalf("DeleteRoom: %v", err)
+	}
+
+	err = events.PublishRevocation(bus, roomID, userID)
+	if err != nil {
+		t.Fatalf("PublishRevocation: %v", err)
+	}
+
+	message := receiveMessage(t, conn)
+	if message.Type != MessageUnsubscribed || message.Reason != "revoked" || message.ID == nil || *message.ID != roomID {
+		t.Errorf("Got %+v, want the room subscription revoked", message)
+	}
+}
@@ -0,0 +1,220 @@
+package realtime
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/adamelfsborg-code/movie-nest/events"
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultHistorySize = 256
+	DefaultBufferSize  = 64
+)
+
+// Message is one room event ready to be sent to a client.
+type Message struct {
+	ID      string    `json:"id"`
+	Type    string    `json:"type"`
+	Subject string    `json:"subject"`
+	RoomID  uuid.UUID `json:"room_id"`
+	Data    []byte    `json:"data"`
+}
+
+// Hub subscribes once to every room subject on the bus and fans the
+// events out to connected clients. It keeps the latest events of every
+// room so a reconnecting client can resume from its last event id, and
+// drops events it already has.
+type Hub struct {
+	Bus         events.Bus
+	HistorySize int
+	BufferSize  int
+
+	mu           sync.Mutex
+	history      map[uuid.UUID][]Message
+	clients      map[uuid.UUID]map[*Client]struct{}
+	subscription events.Subscription
+}
+
+// Client receives the events of one room. Messages is closed when the
+// client falls more than BufferSize events behind, it is then expected to
+// reconnect and resume from the last event it received. It is also closed
+// once UserID leaves or is removed from the room, or the room is deleted.
+type Client struct {
+	RoomID   uuid.UUID
+	UserID   uuid.UUID
+	Messages chan Message
+
+	hub     *Hub
+	once    sync.Once
+	dropped bool
+}
+
+func NewHub(bus events.Bus) *Hub {
+	return &Hub{
+		Bus:         bus,
+		HistorySize: DefaultHistorySize,
+		BufferSize:  DefaultBufferSize,
+		history:     make(map[uuid.UUID][]Message),
+		clients:     make(map[uuid.UUID]map[*Client]struct{}),
+	}
+}
+
+func (h *Hub) Start() error {
+	subscription, err := h.Bus.Subscribe("rooms.>", h.dispatch)
+	if err != nil {
+		return fmt.Errorf("Failed to subscribe to room events: %w", err)
+	}
+
+	h.mu.Lock()
+	h.subscription = subscription
+	h.mu.Unlock()
+
+	return nil
+}
+
+func (h *Hub) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, clients := range h.clients {
+		for client := range clients {
+			client.close()
+		}
+	}
+	h.clients = make(map[uuid.UUID]map[*Client]struct{})
+
+	if h.subscription == nil {
+		return nil
+	}
+	return h.subscription.Unsubscribe()
+}
+
+// Subscribe registers a client for a room. When lastEventID is set the
+// events published after it are returned as backlog. resync is true when
+// lastEventID is no longer in the history and the client has to reload
+// the room state instead.
+func (h *Hub) Subscribe(roomID, userID uuid.UUID, lastEventID string) (client *Client, backlog []Message, resync bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	client = &Client{
+		RoomID:   roomID,
+		UserID:   userID,
+		Messages: make(chan Message, h.BufferSize),
+		hub:      h,
+	}
+
+	if h.clients[roomID] == nil {
+		h.clients[roomID] = make(map[*Client]struct{})
+	}
+	h.clients[roomID][client] = struct{}{}
+
+	if lastEventID == "" {
+		return client, nil, false
+	}
+
+	history := h.history[roomID]
+	for i, message := range history {
+		if message.ID == lastEventID {
+			backlog = make([]Message, len(history)-i-1)
+			copy(backlog, history[i+1:])
+			return client, backlog, false
+		}
+	}
+
+	return client, nil, true
+}
+
+func (h *Hub) Unsubscribe(client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.remove(client)
+}
+
+func (h *Hub) dispatch(event events.Event) {
+	envelope, err := events.DecodeEnvelope(event.Data)
+	if err != nil {
+		fmt.Println("Failed to decode room event: ", err)
+		return
+	}
+
+	message := Message{
+		ID:      envelope.ID,
+		Type:    envelope.Type,
+		Subject: event.Subject,
+		RoomID:  envelope.RoomID,
+		Data:    event.Data,
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// The outbox publishes an event again when it could not confirm it.
+	for _, recorded := range h.history[message.RoomID] {
+		if recorded.ID == message.ID {
+			return
+		}
+	}
+
+	history := append(h.history[message.RoomID], message)
+	if len(history) > h.HistorySize {
+		history = history[len(history)-h.HistorySize:]
+	}
+	h.history[message.RoomID] = history
+
+	for client := range h.clients[message.RoomID] {
+		select {
+		case client.Messages <- message:
+		default:
+			client.dropped = true
+			h.remove(client)
+		}
+	}
+
+	// The event is the last one a user who lost access gets.
+	switch message.Type {
+	case events.TypeRoomMemberLeft, events.TypeRoomMemberRemoved:
+		tokens := strings.Split(event.Subject, ".")
+		userID, err := uuid.Parse(tokens[len(tokens)-2])
+		if err != nil {
+			return
+		}
+
+		for client := range h.clients[message.RoomID] {
+			if client.UserID == userID {
+				h.remove(client)
+			}
+		}
+	case events.TypeRoomDeleted:
+		for client := range h.clients[message.RoomID] {
+			h.remove(client)
+		}
+	}
+}
+
+func (h *Hub) remove(client *Client) {
+	clients := h.clients[client.RoomID]
+	delete(clients, client)
+	if len(clients) == 0 {
+		delete(h.clients, client.RoomID)
+	}
+	client.close()
+}
+
+// Dropped reports whether the client was disconnected for being too slow.
+func (c *Client) Dropped() bool {
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+
+	return c.dropped
+}
+
+func (c *Client) close() {
+	c.once.Do(func() {
+		close(c.Messages)
+	})
+}
@@ -0,0 +1,108 @@
+package realtime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adamelfsborg-code/movie-nest/events"
+	"github.com/google/uuid"
+)
+
+func publishRoomEvent(t *testing.T, bus events.Bus, roomID uuid.UUID, payload events.Payload) {
+	t.Helper()
+
+	event, err := events.NewEnvelopeEvent(uuid.New(), roomID, payload)
+	if err != nil {
+		t.Fatalf("NewEnvelopeEvent: %v", err)
+	}
+
+	err = bus.Publish(event)
+	if err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+}
+
+func receive(t *testing.T, client *Client) (Message, bool) {
+	t.Helper()
+
+	select {
+	case message, ok := <-client.Messages:
+		return message, ok
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for a message")
+		return Message{}, false
+	}
+}
+
+func TestHubClosesRemovedMembers(t *testing.T) {
+	bus := events.NewChannelBus()
+	defer bus.Close()
+
+	hub := NewHub(bus)
+	err := hub.Start()
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer hub.Close()
+
+	roomID := uuid.New()
+	removedID := uuid.New()
+	stayingID := uuid.New()
+
+	removed, _, _ := hub.Subscribe(roomID, removedID, "")
+	staying, _, _ := hub.Subscribe(roomID, stayingID, "")
+
+	publishRoomEvent(t, bus, roomID, &events.RoomMemberRemoved{RoomID: roomID, UserID: removedID})
+
+	message, ok := receive(t, removed)
+	if !ok || message.Type != events.TypeRoomMemberRemoved {
+		t.Fatalf("Removed member got %+v, %v, want the %v event", message, ok, events.TypeRoomMemberRemoved)
+	}
+
+	if _, ok := receive(t, removed); ok {
+		t.Error("Removed member's stream is still open")
+	}
+
+	if _, ok := receive(t, staying); !ok {
+		t.Error("Remaining member's stream was closed")
+	}
+
+	publishRoomEvent(t, bus, roomID, &events.RoomDeleted{ID: roomID})
+
+	receive(t, staying)
+	if _, ok := receive(t, staying); ok {
+		t.Error("Stream is still open after the room was deleted")
+	}
+}
+
+func TestHubDropsRedeliveredEvents(t *testing.T) {
+	bus := events.NewChannelBus()
+	defer bus.Close()
+
+	hub := NewHub(bus)
+	err := hub.Start()
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer hub.Close()
+
+	roomID := uuid.New()
+	client, _, _ := hub.Subscribe(roomID, uuid.New(), "")
+
+	event, err := events.NewEnvelopeEvent(uuid.New(), roomID, &events.RoomUpdated{ID: roomID, Name: "Movie night"})
+	if err != nil {
+		t.Fatalf("NewEnvelopeEvent: %v", err)
+	}
+
+	// The outbox could not confirm the first publish and sent it again.
+	bus.Publish(event)
+	bus.Publish(event)
+	publishRoomEvent(t, bus, roomID, &events.RoomArchived{ID: roomID})
+
+	if message, _ := receive(t, client); message.ID != event.ID {
+		t.Fatalf("Got %+v, want %v", message, event.ID)
+	}
+	if message, _ := receive(t, client); message.Type != events.TypeRoomArchived {
+		t.Errorf("Got %+v after the redelivery, want the %v event", message, events.TypeRoomArchived)
+	}
+}
@@ -0,0 +1,99 @@
+package rpc
+
+import (
+	"github.com/adamelfsborg-code/movie-nest/data"
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go/micro"
+)
+
+type invitationRequest struct {
+	InvitationID uuid.UUID `json:"invitation_id"`
+}
+
+func (s *Service) inviteUser(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body struct {
+		RoomID uuid.UUID `json:"room_id"`
+		UserID uuid.UUID `json:"user_id"`
+		Role   string    `json:"role"`
+	}
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := data.NewRole(body.Role)
+	if err != nil {
+		return nil, &Error{Code: CodeBadRequest, Description: err.Error()}
+	}
+
+	invitation := data.NewRoomInvitation(body.RoomID, body.UserID, role)
+	return s.Stores.Invitations.InviteUser(*invitation, userID)
+}
+
+func (s *Service) getInvitations(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body roomRequest
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.requireRoomRole(body.RoomID, userID, data.PermissionInvite)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Stores.Invitations.GetInvitationsByRoomID(body.RoomID), nil
+}
+
+func (s *Service) cancelInvitation(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body struct {
+		RoomID       uuid.UUID `json:"room_id"`
+		InvitationID uuid.UUID `json:"invitation_id"`
+	}
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Stores.Invitations.CancelInvitation(body.RoomID, body.InvitationID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return message("Invitation cancelled"), nil
+}
+
+func (s *Service) getUserInvitations(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	return s.Stores.Invitations.GetInvitationsByUserID(userID), nil
+}
+
+func (s *Service) acceptInvitation(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body invitationRequest
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Stores.Invitations.AcceptInvitation(body.InvitationID, userID)
+}
+
+func (s *Service) declineInvitation(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body struct {
+		InvitationID uuid.UUID `json:"invitation_id"`
+		Block        bool      `json:"block"`
+	}
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Stores.Invitations.DeclineInvitation(body.InvitationID, userID, body.Block)
+	if err != nil {
+		return nil, err
+	}
+
+	return message("Invitation declined"), nil
+}
@@ -0,0 +1,87 @@
+package rpc
+
+import (
+	"time"
+
+	"github.com/adamelfsborg-code/movie-nest/data"
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go/micro"
+)
+
+func (s *Service) createInvite(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body struct {
+		RoomID    uuid.UUID  `json:"room_id"`
+		Role      string     `json:"role"`
+		ExpiresAt *time.Time `json:"expires_at"`
+		MaxUses   *int       `json:"max_uses"`
+	}
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.requireRoomRole(body.RoomID, userID, data.PermissionInvite)
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := data.NewRole(body.Role)
+	if err != nil {
+		return nil, &Error{Code: CodeBadRequest, Description: err.Error()}
+	}
+
+	invite, err := data.NewRoomInvite(body.RoomID, role, body.ExpiresAt, body.MaxUses)
+	if err != nil {
+		return nil, &Error{Code: CodeBadRequest, Description: err.Error()}
+	}
+
+	return s.Stores.Invites.CreateInvite(*invite, userID)
+}
+
+func (s *Service) getInvites(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body roomRequest
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.requireRoomRole(body.RoomID, userID, data.PermissionInvite)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Stores.Invites.GetInvitesByRoomID(body.RoomID), nil
+}
+
+func (s *Service) revokeInvite(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body struct {
+		RoomID   uuid.UUID `json:"room_id"`
+		InviteID uuid.UUID `json:"invite_id"`
+	}
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Stores.Invites.RevokeInvite(body.RoomID, body.InviteID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return message("Invite revoked"), nil
+}
+
+func (s *Service) redeemInvite(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body struct {
+		Code string `json:"code"`
+	}
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Stores.Invites.RedeemInvite(body.Code, userID)
+}
@@ -0,0 +1,196 @@
+package rpc
+
+import (
+	"github.com/adamelfsborg-code/movie-nest/data"
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go/micro"
+)
+
+func (s *Service) addMovieEndpoints(group micro.Group) error {
+	endpoints := map[string]handlerFunc{
+		"create":  s.createMovie,
+		"get":     s.getMovie,
+		"tv":      s.getTV,
+		"find":    s.findByIMDbID,
+		"details": s.getMovieDetails,
+		"rate":    s.rateMovie,
+		"remove":  s.removeMovie,
+		"move":    s.moveMovie,
+		"copy":    s.copyMovie,
+	}
+
+	for name, handler := range endpoints {
+		err := group.AddEndpoint(name, s.handle(handler))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) createMovie(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body struct {
+		MediaType    string    `json:"media_type"`
+		MovieID      uint      `json:"movie_id"`
+		SeasonNumber *uint     `json:"season_number"`
+		ShelfID      uuid.UUID `json:"shelf_id"`
+	}
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	movie, err := data.NewMediaItem(body.MediaType, body.MovieID, body.SeasonNumber, body.ShelfID)
+	if err != nil {
+		return nil, &Error{Code: CodeBadRequest, Description: err.Error()}
+	}
+
+	err = s.Stores.Movies.CreateMovie(*movie, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return message("Movie created"), nil
+}
+
+// getMovie looks up a movie on TMDB, movie_id is the TMDB id.
+func (s *Service) getMovie(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body struct {
+		MovieID uint `json:"movie_id"`
+	}
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := s.context()
+	defer cancel()
+
+	return s.Stores.Movies.GetMovie(ctx, body.MovieID, userID)
+}
+
+// getTV looks up a TV series on TMDB, tv_id is the TMDB id.
+func (s *Service) getTV(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body struct {
+		TVID uint `json:"tv_id"`
+	}
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := s.context()
+	defer cancel()
+
+	return s.Stores.Movies.GetTV(ctx, body.TVID, userID)
+}
+
+// findByIMDbID looks up a movie or TV series by imdb_id.
+func (s *Service) findByIMDbID(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body struct {
+		IMDbID string `json:"imdb_id"`
+	}
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := s.context()
+	defer cancel()
+
+	return s.Stores.Movies.FindByIMDbID(ctx, body.IMDbID, userID)
+}
+
+func (s *Service) getMovieDetails(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body struct {
+		MovieID uuid.UUID `json:"movie_id"`
+	}
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.requireMovieRole(body.MovieID, userID, data.PermissionView)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := s.context()
+	defer cancel()
+
+	return s.Stores.Movies.GetMovieDetails(ctx, body.MovieID, userID)
+}
+
+func (s *Service) rateMovie(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body struct {
+		MovieID uuid.UUID `json:"movie_id"`
+		Rating  float64   `json:"rating"`
+	}
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Stores.Movies.RateMovie(data.MovieRating{
+		MovieID: body.MovieID,
+		UserID:  userID,
+		Rating:  body.Rating,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return message("Movie rated"), nil
+}
+
+func (s *Service) removeMovie(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body struct {
+		MovieID uuid.UUID `json:"movie_id"`
+	}
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Stores.Movies.RemoveMovie(body.MovieID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return message("Movie removed"), nil
+}
+
+type placeMovieRequest struct {
+	MovieID uuid.UUID `json:"movie_id"`
+	ShelfID uuid.UUID `json:"shelf_id"`
+}
+
+func (s *Service) moveMovie(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body placeMovieRequest
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Stores.Movies.MoveMovie(body.MovieID, body.ShelfID, userID)
+}
+
+func (s *Service) copyMovie(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body placeMovieRequest
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Stores.Movies.CopyMovie(body.MovieID, body.ShelfID, userID)
+}
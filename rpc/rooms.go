@@ -0,0 +1,365 @@
+package rpc
+
+import (
+	"github.com/adamelfsborg-code/movie-nest/data"
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go/micro"
+)
+
+type roomRequest struct {
+	RoomID uuid.UUID `json:"room_id"`
+}
+
+func (s *Service) addRoomEndpoints(group micro.Group) error {
+	endpoints := map[string]handlerFunc{
+		"list":               s.listRooms,
+		"list_with_users":    s.listRoomsWithUsers,
+		"user_rooms":         s.getUserRooms,
+		"get":                s.getRoom,
+		"info":               s.getRoomInfo,
+		"with_users":         s.getRoomWithUsers,
+		"access":             s.getRoomAccess,
+		"role":               s.getRoomRole,
+		"available_users":    s.getAvailableUsers,
+		"create":             s.createRoom,
+		"invite_user":        s.inviteUser,
+		"set_role":           s.setRoomUserRole,
+		"set_locale":         s.setRoomLocale,
+		"set_subscriptions":  s.setRoomSubscriptions,
+		"update":             s.updateRoom,
+		"archive":            s.archiveRoom,
+		"unarchive":          s.unarchiveRoom,
+		"leave":              s.leaveRoom,
+		"remove_user":        s.removeRoomUser,
+		"transfer":           s.transferRoom,
+		"delete":             s.deleteRoom,
+		"create_invite":      s.createInvite,
+		"invites":            s.getInvites,
+		"revoke_invite":      s.revokeInvite,
+		"redeem_invite":      s.redeemInvite,
+		"invitations":        s.getInvitations,
+		"cancel_invitation":  s.cancelInvitation,
+		"user_invitations":   s.getUserInvitations,
+		"accept_invitation":  s.acceptInvitation,
+		"decline_invitation": s.declineInvitation,
+	}
+
+	for name, handler := range endpoints {
+		err := group.AddEndpoint(name, s.handle(handler))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) listRooms(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	return s.Stores.Rooms.ListRooms(), nil
+}
+
+func (s *Service) listRoomsWithUsers(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	return s.Stores.Rooms.ListRoomsWithUsers(), nil
+}
+
+func (s *Service) getUserRooms(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	return s.Stores.Rooms.GetUserRoomsByID(userID), nil
+}
+
+func (s *Service) getRoom(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body roomRequest
+	err := s.decodeRoom(request, &body, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Stores.Rooms.GetRoomByID(body.RoomID)
+}
+
+func (s *Service) getRoomInfo(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body roomRequest
+	err := s.decodeRoom(request, &body, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Stores.Rooms.GetRoomInfoByID(body.RoomID, userID)
+}
+
+func (s *Service) getRoomWithUsers(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body roomRequest
+	err := s.decodeRoom(request, &body, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Stores.Rooms.GetRoomWithUsersByID(body.RoomID), nil
+}
+
+func (s *Service) getRoomAccess(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body roomRequest
+	err := s.decodeRoom(request, &body, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return true, nil
+}
+
+func (s *Service) getRoomRole(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body roomRequest
+	err := s.decodeRoom(request, &body, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := s.Stores.Rooms.GetRoomRole(body.RoomID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return data.NewRoomRole(role), nil
+}
+
+func (s *Service) getAvailableUsers(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body struct {
+		RoomID          uuid.UUID `json:"room_id"`
+		SearchTerm      string    `json:"search_term"`
+		ExcludeSelf     *bool     `json:"exclude_self"`
+		ExcludeExisting *bool     `json:"exclude_existing"`
+	}
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.requireRoomRole(body.RoomID, userID, data.PermissionView)
+	if err != nil {
+		return nil, err
+	}
+
+	excludeSelf := body.ExcludeSelf == nil || *body.ExcludeSelf
+	excludeExisting := body.ExcludeExisting == nil || *body.ExcludeExisting
+
+	return s.Stores.Rooms.GetAvailableUsers(body.RoomID, userID, body.SearchTerm, excludeSelf, excludeExisting), nil
+}
+
+func (s *Service) createRoom(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body struct {
+		Name string `json:"name"`
+	}
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	room := data.NewRoom(body.Name)
+	err = s.Stores.Rooms.CreateRoom(*room, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return message("Room created"), nil
+}
+
+func (s *Service) setRoomUserRole(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body struct {
+		RoomID uuid.UUID `json:"room_id"`
+		UserID uuid.UUID `json:"user_id"`
+		Role   string    `json:"role"`
+	}
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := data.NewRole(body.Role)
+	if err != nil {
+		return nil, &Error{Code: CodeBadRequest, Description: err.Error()}
+	}
+
+	err = s.Stores.Rooms.SetRoomUserRole(body.RoomID, body.UserID, role, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return message("Role updated"), nil
+}
+
+func (s *Service) setRoomLocale(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body struct {
+		RoomID   uuid.UUID `json:"room_id"`
+		Language string    `json:"language"`
+		Region   string    `json:"region"`
+	}
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	update, err := data.NewRoomUpdate(nil, &body.Language, &body.Region, nil)
+	if err != nil {
+		return nil, &Error{Code: CodeBadRequest, Description: err.Error()}
+	}
+
+	_, err = s.Stores.Rooms.UpdateRoom(body.RoomID, *update, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return message("Locale updated"), nil
+}
+
+func (s *Service) setRoomSubscriptions(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body struct {
+		RoomID      uuid.UUID `json:"room_id"`
+		ProviderIDs []uint    `json:"provider_ids"`
+	}
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	update, err := data.NewRoomUpdate(nil, nil, nil, &body.ProviderIDs)
+	if err != nil {
+		return nil, &Error{Code: CodeBadRequest, Description: err.Error()}
+	}
+
+	_, err = s.Stores.Rooms.UpdateRoom(body.RoomID, *update, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return message("Subscriptions updated"), nil
+}
+
+// updateRoom only changes the fields in the request.
+func (s *Service) updateRoom(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body struct {
+		RoomID        uuid.UUID `json:"room_id"`
+		Name          *string   `json:"name"`
+		Language      *string   `json:"language"`
+		Region        *string   `json:"region"`
+		Subscriptions *[]uint   `json:"subscriptions"`
+	}
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	update, err := data.NewRoomUpdate(body.Name, body.Language, body.Region, body.Subscriptions)
+	if err != nil {
+		return nil, &Error{Code: CodeBadRequest, Description: err.Error()}
+	}
+
+	return s.Stores.Rooms.UpdateRoom(body.RoomID, *update, userID)
+}
+
+func (s *Service) archiveRoom(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body roomRequest
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Stores.Rooms.SetRoomArchived(body.RoomID, true, userID)
+}
+
+func (s *Service) unarchiveRoom(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body roomRequest
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Stores.Rooms.SetRoomArchived(body.RoomID, false, userID)
+}
+
+func (s *Service) leaveRoom(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body roomRequest
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Stores.Rooms.LeaveRoom(body.RoomID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return message("Left room"), nil
+}
+
+func (s *Service) removeRoomUser(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body struct {
+		RoomID uuid.UUID `json:"room_id"`
+		UserID uuid.UUID `json:"user_id"`
+	}
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Stores.Rooms.RemoveRoomUser(body.RoomID, body.UserID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return message("User removed"), nil
+}
+
+func (s *Service) transferRoom(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body struct {
+		RoomID uuid.UUID `json:"room_id"`
+		UserID uuid.UUID `json:"user_id"`
+	}
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Stores.Rooms.TransferRoomOwnership(body.RoomID, body.UserID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return message("Ownership transferred"), nil
+}
+
+func (s *Service) deleteRoom(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body roomRequest
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Stores.Rooms.DeleteRoom(body.RoomID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return message("Room deleted"), nil
+}
+
+// decodeRoom decodes a request for a single room and checks that the user
+// is a member.
+func (s *Service) decodeRoom(request micro.Request, body *roomRequest, userID uuid.UUID) error {
+	err := decode(request, body)
+	if err != nil {
+		return err
+	}
+
+	return s.requireRoomRole(body.RoomID, userID, data.PermissionView)
+}
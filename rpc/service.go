@@ -0,0 +1,276 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/adamelfsborg-code/movie-nest/data"
+	"github.com/adamelfsborg-code/movie-nest/pkg/omdb"
+	"github.com/adamelfsborg-code/movie-nest/pkg/themoviedb"
+	"github.com/adamelfsborg-code/movie-nest/shared"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+const (
+	ServiceName       = "movie-nest"
+	ServiceVersion    = "1.0.0"
+	SubjectPrefix     = "nest"
+	DefaultQueueGroup = "movie-nest"
+	DefaultTimeout    = time.Second * 30
+)
+
+// Error codes follow the HTTP status the same operation returns over HTTP.
+const (
+	CodeBadRequest   = "400"
+	CodeUnauthorized = "401"
+	CodeForbidden    = "403"
+	CodeNotFound     = "404"
+	CodeConflict     = "409"
+	CodeGone         = "410"
+	CodeInternal     = "500"
+	CodeBadGateway   = "502"
+	CodeUnavailable  = "503"
+	CodeTimeout      = "504"
+)
+
+// Service exposes the room, shelf and movie operations as a NATS micro
+// service on nest.rooms.*, nest.shelves.* and nest.movies.*. Requests are
+// JSON and carry the login token in the Authorization header, the same
+// way HTTP clients do. Every instance joins QueueGroup so requests are
+// spread over all running APIs. Requests that call TMDB give up after
+// Timeout.
+type Service struct {
+	Conn       *nats.Conn
+	Stores     data.Stores
+	SecretKey  []byte
+	QueueGroup string
+	Timeout    time.Duration
+
+	service micro.Service
+}
+
+type handlerFunc func(userID uuid.UUID, request micro.Request) (interface{}, error)
+
+// Error is returned by handlers to answer with a specific code.
+type Error struct {
+	Code        string
+	Description string
+}
+
+func (e *Error) Error() string {
+	return e.Description
+}
+
+func New(conn *nats.Conn, stores data.Stores, secretKey []byte) *Service {
+	return &Service{
+		Conn:       conn,
+		Stores:     stores,
+		SecretKey:  secretKey,
+		QueueGroup: DefaultQueueGroup,
+		Timeout:    DefaultTimeout,
+	}
+}
+
+func (s *Service) Start() error {
+	service, err := micro.AddService(s.Conn, micro.Config{
+		Name:        ServiceName,
+		Version:     ServiceVersion,
+		Description: "Rooms, shelves and movies over request-reply",
+		QueueGroup:  s.QueueGroup,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to add nats service: %w", err)
+	}
+	s.service = service
+
+	err = s.addRoomEndpoints(service.AddGroup(SubjectPrefix + ".rooms"))
+	if err == nil {
+		err = s.addShelfEndpoints(service.AddGroup(SubjectPrefix + ".shelves"))
+	}
+	if err == nil {
+		err = s.addMovieEndpoints(service.AddGroup(SubjectPrefix + ".movies"))
+	}
+	if err != nil {
+		service.Stop()
+		return fmt.Errorf("Failed to add nats endpoints: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Service) Stop() error {
+	if s.service == nil {
+		return nil
+	}
+	return s.service.Stop()
+}
+
+// handle authenticates the request and responds with the JSON encoded
+// result of handler.
+func (s *Service) handle(handler handlerFunc) micro.Handler {
+	return micro.HandlerFunc(func(request micro.Request) {
+		userID, err := s.authenticate(request)
+		if err != nil {
+			request.Error(CodeUnauthorized, "Unauthorized", nil)
+			return
+		}
+
+		response, err := handler(userID, request)
+		if err != nil {
+			if requestError, ok := err.(*Error); ok {
+				request.Error(requestError.Code, requestError.Description, nil)
+				return
+			}
+
+			if code := storeCode(err); code != "" {
+				request.Error(code, storeDescription(err, code), nil)
+				return
+			}
+
+			fmt.Printf("Failed to handle %v: %v\n", request.Subject(), err)
+
+			if code := movieDBCode(err); code != "" {
+				request.Error(code, movieDBDescription(code), nil)
+				return
+			}
+
+			request.Error(CodeInternal, "Internal error", nil)
+			return
+		}
+
+		err = request.RespondJSON(response)
+		if err != nil {
+			fmt.Println("Failed to respond: ", err)
+		}
+	})
+}
+
+// context bounds the upstream calls of one request.
+func (s *Service) context() (context.Context, context.CancelFunc) {
+	if s.Timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), s.Timeout)
+}
+
+func (s *Service) authenticate(request micro.Request) (uuid.UUID, error) {
+	parts := strings.Split(request.Headers().Get("Authorization"), " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return uuid.Nil, fmt.Errorf("Missing token")
+	}
+
+	return shared.ParseUserToken(s.SecretKey, parts[1])
+}
+
+// requireRoomRole checks that the user's role in the room has permission,
+// like the RequireRoomRole middleware does for HTTP.
+func (s *Service) requireRoomRole(roomID, userID uuid.UUID, permission data.Permission) error {
+	role, err := s.Stores.Rooms.GetRoomRole(roomID, userID)
+	if err != nil || !role.Can(permission) {
+		return &Error{Code: CodeForbidden, Description: "Permission Not Allowd"}
+	}
+	return nil
+}
+
+func (s *Service) requireShelfRole(shelfID, userID uuid.UUID, permission data.Permission) error {
+	role, err := s.Stores.Shelves.GetShelfRole(shelfID, userID)
+	if err != nil || !role.Can(permission) {
+		return &Error{Code: CodeForbidden, Description: "Permission Not Allowd"}
+	}
+	return nil
+}
+
+// requireMovieRole checks the user's role in the room of the shelf the
+// movie is on, like the RequireMovieRole middleware does for HTTP.
+func (s *Service) requireMovieRole(movieID, userID uuid.UUID, permission data.Permission) error {
+	role, err := s.Stores.Movies.GetMovieRole(movieID, userID)
+	if err != nil || !role.Can(permission) {
+		return &Error{Code: CodeForbidden, Description: "Permission Not Allowd"}
+	}
+	return nil
+}
+
+func decode(request micro.Request, body interface{}) error {
+	if len(request.Data()) == 0 {
+		return nil
+	}
+
+	err := json.Unmarshal(request.Data(), body)
+	if err != nil {
+		return &Error{Code: CodeBadRequest, Description: "Failed to decode json"}
+	}
+	return nil
+}
+
+func message(text string) map[string]string {
+	return map[string]string{"message": text}
+}
+
+// storeCode maps store errors the same way the HTTP handlers do.
+func storeCode(err error) string {
+	switch {
+	case errors.Is(err, data.ErrShelfOrder), errors.Is(err, data.ErrOtherRoom),
+		errors.Is(err, data.ErrUnknownMediaType), errors.Is(err, data.ErrDiscoverMediaType),
+		errors.Is(err, data.ErrDiscoverUnsupported):
+		return CodeBadRequest
+	case errors.Is(err, data.ErrForbidden), errors.Is(err, data.ErrBlocked):
+		return CodeForbidden
+	case errors.Is(err, pg.ErrNoRows):
+		return CodeNotFound
+	case errors.Is(err, data.ErrInviteExpired), errors.Is(err, data.ErrInviteRevoked),
+		errors.Is(err, data.ErrInviteUsedUp), errors.Is(err, data.ErrInvitationClosed):
+		return CodeGone
+	case errors.Is(err, data.ErrAlreadyMember), errors.Is(err, data.ErrAlreadyInvited),
+		errors.Is(err, data.ErrRoomArchived), errors.Is(err, data.ErrOwnerCannotLeave),
+		errors.Is(err, data.ErrMovieOnShelf):
+		return CodeConflict
+	}
+	return ""
+}
+
+// storeDescription answers with the error itself when it tells the caller
+// why the request cannot be done, like storeMessage does for HTTP.
+func storeDescription(err error, code string) string {
+	switch {
+	case code == CodeBadRequest, code == CodeGone, code == CodeConflict, errors.Is(err, data.ErrBlocked):
+		return err.Error()
+	case code == CodeForbidden:
+		return "Permission Not Allowd"
+	}
+	return "Not found"
+}
+
+// movieDBCode maps TMDB and OMDb client errors the same way the HTTP handlers do.
+func movieDBCode(err error) string {
+	switch {
+	case errors.Is(err, themoviedb.ErrNotFound), errors.Is(err, omdb.ErrNotFound):
+		return CodeNotFound
+	case errors.Is(err, themoviedb.ErrRateLimited):
+		return CodeUnavailable
+	case errors.Is(err, themoviedb.ErrUnauthorized), errors.Is(err, themoviedb.ErrUnavailable),
+		errors.Is(err, omdb.ErrUnauthorized), errors.Is(err, omdb.ErrUnavailable):
+		return CodeBadGateway
+	case errors.Is(err, context.DeadlineExceeded):
+		return CodeTimeout
+	}
+	return ""
+}
+
+// movieDBDescription leaves out the upstream error, it may hold urls and
+// response bodies.
+func movieDBDescription(code string) string {
+	switch code {
+	case CodeNotFound:
+		return "Not found"
+	case CodeTimeout:
+		return "Movie database timed out"
+	}
+	return "Movie database unavailable"
+}
@@ -0,0 +1,207 @@
+package rpc
+
+import (
+	"github.com/adamelfsborg-code/movie-nest/data"
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go/micro"
+)
+
+type shelfRequest struct {
+	ShelfID uuid.UUID `json:"shelf_id"`
+}
+
+func (s *Service) addShelfEndpoints(group micro.Group) error {
+	endpoints := map[string]handlerFunc{
+		"create":           s.createShelf,
+		"by_room":          s.getShelvesByRoom,
+		"movies":           s.getShelfMovies,
+		"info":             s.getShelfInfo,
+		"available_movies": s.getAvailableMovies,
+		"rename":           s.renameShelf,
+		"reorder":          s.reorderShelves,
+		"delete":           s.deleteShelf,
+	}
+
+	for name, handler := range endpoints {
+		err := group.AddEndpoint(name, s.handle(handler))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) createShelf(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body struct {
+		Name   string    `json:"name"`
+		RoomID uuid.UUID `json:"room_id"`
+	}
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	shelf := data.NewShelf(body.Name, body.RoomID)
+	err = s.Stores.Shelves.CreateShelf(*shelf, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return message("Shelf created"), nil
+}
+
+func (s *Service) getShelvesByRoom(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body roomRequest
+	err := s.decodeRoom(request, &body, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Stores.Shelves.GetShelvesByRoomID(body.RoomID), nil
+}
+
+func (s *Service) getShelfMovies(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body struct {
+		ShelfID    uuid.UUID `json:"shelf_id"`
+		Streamable bool      `json:"streamable"`
+	}
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.requireShelfRole(body.ShelfID, userID, data.PermissionView)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Stores.Shelves.GetShelfMoviesByID(body.ShelfID, userID, body.Streamable), nil
+}
+
+func (s *Service) getShelfInfo(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body shelfRequest
+	err := s.decodeShelf(request, &body, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Stores.Shelves.GetShelfInfoByID(body.ShelfID), nil
+}
+
+func (s *Service) getAvailableMovies(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body struct {
+		ShelfID         uuid.UUID `json:"shelf_id"`
+		SearchTerm      string    `json:"search_term"`
+		MediaType       string    `json:"media_type"`
+		Discover        bool      `json:"discover"`
+		Page            uint      `json:"page"`
+		Year            uint      `json:"year"`
+		YearFrom        uint      `json:"year_from"`
+		YearTo          uint      `json:"year_to"`
+		Language        string    `json:"language"`
+		Region          string    `json:"region"`
+		IncludeAdult    bool      `json:"include_adult"`
+		Genres          []uint    `json:"genres"`
+		SortBy          string    `json:"sort_by"`
+		MinVoteCount    uint      `json:"min_vote_count"`
+		ExcludeExisting *bool     `json:"exclude_existing"`
+	}
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.requireShelfRole(body.ShelfID, userID, data.PermissionView)
+	if err != nil {
+		return nil, err
+	}
+
+	if body.Page > 500 {
+		return nil, &Error{Code: CodeBadRequest, Description: "Invalid page, TMDB serves pages 1 to 500"}
+	}
+
+	search := data.MovieSearch{
+		SearchTerm:   body.SearchTerm,
+		MediaType:    body.MediaType,
+		Discover:     body.Discover,
+		Page:         body.Page,
+		Year:         body.Year,
+		YearFrom:     body.YearFrom,
+		YearTo:       body.YearTo,
+		Language:     body.Language,
+		Region:       body.Region,
+		IncludeAdult: body.IncludeAdult,
+		Genres:       body.Genres,
+		SortBy:       body.SortBy,
+		MinVoteCount: body.MinVoteCount,
+	}
+
+	excludeExisting := body.ExcludeExisting == nil || *body.ExcludeExisting
+
+	ctx, cancel := s.context()
+	defer cancel()
+
+	return s.Stores.Shelves.GetAvailableMovies(ctx, body.ShelfID, userID, search, excludeExisting)
+}
+
+func (s *Service) renameShelf(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body struct {
+		ShelfID uuid.UUID `json:"shelf_id"`
+		Name    string    `json:"name"`
+	}
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := data.NewShelfName(body.Name)
+	if err != nil {
+		return nil, &Error{Code: CodeBadRequest, Description: err.Error()}
+	}
+
+	return s.Stores.Shelves.RenameShelf(body.ShelfID, name, userID)
+}
+
+func (s *Service) reorderShelves(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body struct {
+		RoomID   uuid.UUID   `json:"room_id"`
+		ShelfIDs []uuid.UUID `json:"shelf_ids"`
+	}
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Stores.Shelves.ReorderShelves(body.RoomID, body.ShelfIDs, userID)
+}
+
+func (s *Service) deleteShelf(userID uuid.UUID, request micro.Request) (interface{}, error) {
+	var body shelfRequest
+
+	err := decode(request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Stores.Shelves.DeleteShelf(body.ShelfID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return message("Shelf deleted"), nil
+}
+
+func (s *Service) decodeShelf(request micro.Request, body *shelfRequest, userID uuid.UUID) error {
+	err := decode(request, body)
+	if err != nil {
+		return err
+	}
+
+	return s.requireShelfRole(body.ShelfID, userID, data.PermissionView)
+}
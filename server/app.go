@@ -9,41 +9,92 @@ import (
 	"time"
 
 	"github.com/adamelfsborg-code/movie-nest/config"
+	"github.com/adamelfsborg-code/movie-nest/data"
 	"github.com/adamelfsborg-code/movie-nest/db"
+	"github.com/adamelfsborg-code/movie-nest/db/migrations"
+	"github.com/adamelfsborg-code/movie-nest/events"
+	"github.com/adamelfsborg-code/movie-nest/pkg/images"
+	"github.com/adamelfsborg-code/movie-nest/realtime"
+	"github.com/adamelfsborg-code/movie-nest/rpc"
 	"github.com/go-pg/pg/v10"
-	"github.com/nats-io/nats.go"
 )
 
 type Server struct {
-	router  http.Handler
-	config  config.Environments
-	datbase pg.DB
-	nats    *nats.Conn
+	router   http.Handler
+	config   config.Environments
+	datbase  *pg.DB
+	bus      events.Bus
+	services Services
 }
 
-func New(config config.Environments) *Server {
+func New(config config.Environments) (*Server, error) {
 	server := &Server{
 		config: config,
 	}
 
-	d := pg.Connect(&pg.Options{
-		Addr:     config.DatabaseAddr,
-		Database: config.DatabaseName,
-		User:     config.DatabaseUser,
-		Password: config.DatabasePassword,
-	})
+	server.bus = newEventBus(config)
 
-	nats, _ := ConnectNats(&Nats{
-		host: config.NatsAddr,
-	})
+	if config.DemoMode {
+		fmt.Println("Running in demo mode, data is kept in memory")
+		err := server.setServices(data.NewMemoryStores(config, data.NewMemoryDB(), server.bus))
+		if err != nil {
+			server.bus.Close()
+			return nil, err
+		}
+		return server, nil
+	}
+
+	d := db.Connect(config)
+
+	migrator, err := migrations.New(d)
+	if err != nil {
+		d.Close()
+		server.bus.Close()
+		return nil, err
+	}
+
+	err = migrator.Check(context.Background())
+	if err != nil {
+		d.Close()
+		server.bus.Close()
+		return nil, fmt.Errorf("Refusing to start, run `migrate up` first: %w", err)
+	}
+
+	err = server.setServices(data.NewStores(config, d, server.bus))
+	if err != nil {
+		d.Close()
+		server.bus.Close()
+		return nil, err
+	}
+	server.datbase = d
+
+	return server, nil
+}
 
-	server.nats = nats
+func (a *Server) setServices(stores data.Stores) error {
+	history, err := newEventHistory(a.bus, a.config.EventHistoryAge)
+	if err != nil {
+		return fmt.Errorf("Failed to set up event history: %w", err)
+	}
 
-	server.datbase = *d
+	imageCache, err := images.NewCache(a.config.ImageCacheDir, a.config.ImageCacheSize)
+	if err != nil {
+		return fmt.Errorf("Failed to set up image cache: %w", err)
+	}
 
-	server.loadRoutes()
+	a.services = Services{
+		Stores:  stores,
+		Hub:     realtime.NewHub(a.bus),
+		Gateway: realtime.NewGateway(a.bus, stores),
+		History: history,
+		Images:  images.NewProxy(a.config.ImageBaseURL, imageCache),
 
-	return server
+		AllowedOrigins: a.config.AllowedOrigins,
+		SecretKey:      a.config.SecretKey,
+	}
+
+	a.loadRoutes()
+	return nil
 }
 
 func (a *Server) Start(ctx context.Context) error {
@@ -52,49 +103,71 @@ func (a *Server) Start(ctx context.Context) error {
 		Handler: a.router,
 	}
 
-	err := a.datbase.Ping(ctx)
-	if err != nil {
-		return fmt.Errorf("Failed to connect to repo: %w", err)
+	if a.datbase != nil {
+		err := a.datbase.Ping(ctx)
+		if err != nil {
+			return fmt.Errorf("Failed to connect to repo: %w", err)
+		}
+
+		defer func() {
+			err := a.datbase.Close()
+			if err != nil {
+				fmt.Println("Failed to close Repo", err)
+			}
+		}()
+
+		a.datbase.AddQueryHook(&db.QueryLogger{})
+
+		go a.watchDatabase(ctx)
+
+		go data.NewOutboxRelay(a.datbase, a.bus).Run(ctx)
+	}
+
+	go a.services.Stores.Metadata.Run(ctx)
+
+	if a.config.DebugAddr != "" {
+		debugServer := &http.Server{
+			Addr:    a.config.DebugAddr,
+			Handler: NewDebugRouter(),
+		}
+
+		go func() {
+			err := debugServer.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				fmt.Println("Failed to start debug server: ", err)
+			}
+		}()
+
+		defer debugServer.Close()
 	}
 
 	defer func() {
-		err := a.datbase.Close()
+		err := a.bus.Close()
 		if err != nil {
-			fmt.Println("Failed to close Repo", err)
+			fmt.Println("Failed to close event bus", err)
 		}
 	}()
 
-	defer func() {
-		a.nats.Close()
-	}()
+	err := a.services.Hub.Start()
+	if err != nil {
+		return err
+	}
 
-	err = a.nats.Publish("your.subject", []byte("your message"))
+	err = a.services.Gateway.Start()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	a.datbase.AddQueryHook(&db.QueryLogger{})
+	service, err := a.startService()
+	if err != nil {
+		return err
+	}
 
-	go func() {
-		ticker := time.NewTicker(time.Minute)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ticker.C:
-				err := a.datbase.Ping(ctx)
-				var searchPath string
-				_, err = a.datbase.QueryOne(pg.Scan(&searchPath), "SHOW search_path")
-				if err != nil {
-					fmt.Println("Error getting search path:", err)
-					os.Exit(1)
-				}
-
-				if err != nil {
-					log.Println("Database connection lost:", err)
-				}
-			}
-		}
-	}()
+	server.RegisterOnShutdown(func() {
+		a.services.Hub.Close()
+		a.services.Gateway.Close()
+		service.Stop()
+	})
 
 	ch := make(chan error, 1)
 
@@ -118,3 +191,47 @@ func (a *Server) Start(ctx context.Context) error {
 		return server.Shutdown(timeout)
 	}
 }
+
+// startService serves the API over NATS request-reply as well when the
+// event bus is NATS.
+func (a *Server) startService() (*rpc.Service, error) {
+	service := &rpc.Service{}
+
+	natsBus, ok := a.bus.(*events.NatsBus)
+	if !ok {
+		return service, nil
+	}
+
+	service = rpc.New(natsBus.Conn, a.services.Stores, a.config.SecretKey)
+	service.QueueGroup = a.config.NatsQueueGroup
+
+	err := service.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	return service, nil
+}
+
+func (a *Server) watchDatabase(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := a.datbase.Ping(ctx)
+			var searchPath string
+			_, err = a.datbase.QueryOne(pg.Scan(&searchPath), "SHOW search_path")
+			if err != nil {
+				fmt.Println("Error getting search path:", err)
+				os.Exit(1)
+			}
+
+			if err != nil {
+				log.Println("Database connection lost:", err)
+			}
+		}
+	}
+}
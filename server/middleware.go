@@ -1,13 +1,16 @@
 package server
 
 import (
+	"fmt"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/adamelfsborg-code/movie-nest/config"
 	"github.com/adamelfsborg-code/movie-nest/data"
+	"github.com/adamelfsborg-code/movie-nest/shared"
 	"github.com/go-chi/chi/v5"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
@@ -19,106 +22,144 @@ func Authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return config.Env.SecretKey, nil
-		})
-
-		if err != nil || !token.Valid {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-
-		userID, ok := claims["sub"].(string)
-		if !ok {
+		userID, err := shared.ParseUserToken(config.Env.SecretKey, tokenString)
+		if err != nil {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		r.Header.Set("X-UserID", userID)
+		r.Header.Set("X-UserID", userID.String())
 
 		next.ServeHTTP(w, r)
 	})
 }
 
-func CustomAuthMiddleware() func(next http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return Authenticate(next)
+// Tickets remembers redeemed websocket tickets until they expire, so a
+// ticket that ended up in a log cannot be used again. Tickets are
+// remembered per instance.
+type Tickets struct {
+	mu       sync.Mutex
+	redeemed map[string]time.Time
+}
+
+func NewTickets() *Tickets {
+	return &Tickets{
+		redeemed: make(map[string]time.Time),
 	}
 }
 
-func AccessRoom(next http.Handler, data data.RoomData) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		idParam := r.Header.Get("X-UserID")
+func (t *Tickets) redeem(ticket *shared.Ticket) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-		userID, err := uuid.Parse(idParam)
-		if err != nil {
-			http.Error(w, "Permission Not Allowd", http.StatusForbidden)
-			return
+	now := time.Now()
+	for id, expiresAt := range t.redeemed {
+		if expiresAt.Before(now) {
+			delete(t.redeemed, id)
 		}
+	}
 
-		idParam = chi.URLParam(r, "room_id")
-
-		roomID, err := uuid.Parse(idParam)
-		if err != nil {
-			http.Error(w, "Permission Not Allowd", http.StatusForbidden)
-			return
-		}
+	if _, exists := t.redeemed[ticket.ID]; exists {
+		return false
+	}
+	t.redeemed[ticket.ID] = ticket.ExpiresAt
+	return true
+}
 
-		permission, err := data.GetRoomAccess(roomID, userID)
+// AuthenticateTicket authenticates with a ?ticket= from
+// POST /ws/tickets for clients that cannot set headers, such as browser
+// websockets. Requests without a ticket need the bearer token.
+func AuthenticateTicket(secretKey []byte, tickets *Tickets) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ticketString := r.URL.Query().Get("ticket")
+			if ticketString == "" {
+				Authenticate(next).ServeHTTP(w, r)
+				return
+			}
+
+			ticket, err := shared.ParseTicket(secretKey, ticketString)
+			if err != nil || !tickets.redeem(ticket) {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			r.Header.Set("X-UserID", ticket.UserID.String())
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
 
-		if !permission {
-			http.Error(w, "Permission Not Allowd", http.StatusForbidden)
-			return
+// TokenFromCookie accepts the token of the login cookie for clients that
+// cannot set headers, such as browser EventSources. It is only used on
+// read-only routes, as the browser sends the cookie on cross-site
+// requests as well.
+func TokenFromCookie(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(shared.TokenCookie)
+		if err == nil && cookie.Value != "" && r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", fmt.Sprintf("Bearer %v", cookie.Value))
 		}
 
 		next.ServeHTTP(w, r)
 	})
 }
 
-func CustomAccessRoomMiddleware(data data.RoomData) func(next http.Handler) http.Handler {
+func CustomAuthMiddleware() func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
-		return AccessRoom(next, data)
+		return Authenticate(next)
 	}
 }
 
-func AccessShelf(next http.Handler, data data.ShelfData) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		idParam := r.Header.Get("X-UserID")
-
-		userID, err := uuid.Parse(idParam)
-		if err != nil {
-			http.Error(w, "Permission Not Allowd", http.StatusForbidden)
-			return
-		}
-
-		idParam = chi.URLParam(r, "shelf_id")
-
-		shelfID, err := uuid.Parse(idParam)
-		if err != nil {
-			http.Error(w, "Permission Not Allowd", http.StatusForbidden)
-			return
-		}
-
-		permission, err := data.GetShelfAccess(shelfID, userID)
+// RequireRoomRole lets requests through when the caller's role in the
+// {room_id} room has permission.
+func RequireRoomRole(rooms data.RoomStore, permission data.Permission) func(next http.Handler) http.Handler {
+	return requireRole("room_id", rooms.GetRoomRole, permission)
+}
 
-		if !permission {
-			http.Error(w, "Permission Not Allowd", http.StatusForbidden)
-			return
-		}
+// RequireShelfRole checks the caller's role in the room of {shelf_id}.
+func RequireShelfRole(shelves data.ShelfStore, permission data.Permission) func(next http.Handler) http.Handler {
+	return requireRole("shelf_id", shelves.GetShelfRole, permission)
+}
 
-		next.ServeHTTP(w, r)
-	})
+// RequireMovieRole checks the caller's role in the room of the shelf item
+// {movie_id}.
+func RequireMovieRole(movies data.MovieStore, permission data.Permission) func(next http.Handler) http.Handler {
+	return requireRole("movie_id", movies.GetMovieRole, permission)
 }
 
-func CustomAccessShelfMiddleware(data data.ShelfData) func(next http.Handler) http.Handler {
+func requireRole(param string, getRole func(id, userID uuid.UUID) (data.Role, error), permission data.Permission) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
-		return AccessShelf(next, data)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			idParam := r.Header.Get("X-UserID")
+
+			userID, err := uuid.Parse(idParam)
+			if err != nil {
+				http.Error(w, "Permission Not Allowd", http.StatusForbidden)
+				return
+			}
+
+			idParam = chi.URLParam(r, param)
+
+			id, err := uuid.Parse(idParam)
+			if err != nil {
+				http.Error(w, "Permission Not Allowd", http.StatusForbidden)
+				return
+			}
+
+			role, err := getRole(id, userID)
+			if err != nil {
+				fmt.Println("Failed to get role: ", err)
+			}
+
+			if err != nil || !role.Can(permission) {
+				http.Error(w, "Permission Not Allowd", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
 	}
 }
 
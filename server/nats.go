@@ -1,8 +1,11 @@
 package server
 
 import (
-	"log"
+	"fmt"
+	"time"
 
+	"github.com/adamelfsborg-code/movie-nest/config"
+	"github.com/adamelfsborg-code/movie-nest/events"
 	"github.com/nats-io/nats.go"
 )
 
@@ -10,11 +13,49 @@ type Nats struct {
 	host string
 }
 
+// ConnectNats keeps retrying in the background when the server is not
+// reachable yet, publishes are buffered until the connection is up.
 func ConnectNats(n *Nats) (*nats.Conn, error) {
-	nc, err := nats.Connect(n.host)
+	nc, err := nats.Connect(n.host,
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+	)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	return nc, nil
 }
+
+func newEventBus(env config.Environments) events.Bus {
+	switch env.EventBus {
+	case config.EventBusNoop:
+		return events.NewNoopBus()
+	case config.EventBusLocal:
+		return events.NewChannelBus()
+	}
+
+	nc, err := ConnectNats(&Nats{
+		host: env.NatsAddr,
+	})
+	if err != nil {
+		fmt.Println("Failed to connect to nats, using in-process events: ", err)
+		return events.NewChannelBus()
+	}
+
+	return events.NewNatsBus(nc)
+}
+
+// newEventHistory stores room events in JetStream when the bus is NATS and
+// falls back to keeping them in memory otherwise.
+func newEventHistory(bus events.Bus, maxAge time.Duration) (events.History, error) {
+	if natsBus, ok := bus.(*events.NatsBus); ok {
+		history, err := events.NewJetStreamHistory(natsBus.Conn, maxAge)
+		if err == nil {
+			return history, nil
+		}
+		fmt.Println("Failed to set up JetStream, keeping event history in memory: ", err)
+	}
+
+	return events.NewMemoryHistory(bus, maxAge)
+}
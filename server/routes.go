@@ -1,21 +1,53 @@
 package server
 
 import (
+	"expvar"
 	"net/http"
 
 	"github.com/adamelfsborg-code/movie-nest/data"
+	"github.com/adamelfsborg-code/movie-nest/events"
 	"github.com/adamelfsborg-code/movie-nest/handlers"
+	"github.com/adamelfsborg-code/movie-nest/pkg/images"
+	"github.com/adamelfsborg-code/movie-nest/realtime"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 )
 
+// Services are the dependencies of the HTTP API. Stores can be backed by
+// Postgres or by the in-memory implementation. Hub and Gateway feed the
+// realtime endpoints and have to be started by the caller. History replays
+// past room events. Images proxies TMDB posters and backdrops.
+// AllowedOrigins are the browser origins allowed to call the API and open
+// websockets, SecretKey signs websocket tickets.
+type Services struct {
+	Stores         data.Stores
+	Hub            *realtime.Hub
+	Gateway        *realtime.Gateway
+	History        events.History
+	Images         *images.Proxy
+	AllowedOrigins []string
+	SecretKey      []byte
+}
+
+type routes struct {
+	Services
+}
+
 func (a *Server) loadRoutes() {
+	a.router = NewRouter(a.services)
+}
+
+func NewRouter(services Services) http.Handler {
+	rt := &routes{
+		Services: services,
+	}
+
 	router := chi.NewRouter()
 
 	router.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"http://localhost:5173"}, // Replace with your allowed origins
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE"},
+		AllowedOrigins:   rt.AllowedOrigins,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
 		AllowCredentials: true,
 	}))
@@ -26,28 +58,67 @@ func (a *Server) loadRoutes() {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	router.Route("/users", a.loadUserRoutes)
+	router.Route("/users", rt.loadUserRoutes)
+
+	imageHandler := &handlers.ImageHandler{
+		Images: rt.Images,
+	}
+
+	// Images are loaded by img tags, which cannot send a token.
+	router.Get("/images/{size}/{path}", imageHandler.GetImage)
+
+	gatewayHandler := &handlers.GatewayHandler{
+		Gateway:        rt.Gateway,
+		SecretKey:      rt.SecretKey,
+		AllowedOrigins: rt.AllowedOrigins,
+	}
+
+	router.Group(func(r chi.Router) {
+		r.Use(AuthenticateTicket(rt.SecretKey, NewTickets()))
+		r.Get("/ws", gatewayHandler.Connect)
+	})
 
 	router.Group(func(r chi.Router) {
 		r.Use(CustomAuthMiddleware())
-		r.Route("/rooms", a.loadRoomRoutes)
-		r.Route("/movies", a.loadMovieRoutes)
-		r.Route("/shelves", a.loadShelfRoutes)
+		r.Post("/ws/tickets", gatewayHandler.CreateTicket)
 	})
 
-	a.router = router
+	eventsHandler := &handlers.EventsHandler{
+		Hub: rt.Hub,
+	}
+
+	router.Group(func(r chi.Router) {
+		r.Use(TokenFromCookie)
+		r.Use(CustomAuthMiddleware())
+		r.Use(RequireRoomRole(rt.Stores.Rooms, data.PermissionView))
+		r.Get("/rooms/{room_id}/events", eventsHandler.StreamRoomEvents)
+	})
+
+	router.Group(func(r chi.Router) {
+		r.Use(CustomAuthMiddleware())
+		r.Route("/rooms", rt.loadRoomRoutes)
+		r.Route("/movies", rt.loadMovieRoutes)
+		r.Route("/shelves", rt.loadShelfRoutes)
+		r.Route("/invites", rt.loadInviteRoutes)
+	})
+
+	return router
 }
 
-func (a *Server) loadUserRoutes(router chi.Router) {
-	roomData := data.RoomData{
-		DB: a.datbase,
-	}
+// NewDebugRouter serves the expvar metrics. It is meant for an internal
+// listener, as the vars include the command line and memory stats.
+func NewDebugRouter() http.Handler {
+	router := chi.NewRouter()
+	router.Handle("/debug/vars", expvar.Handler())
+	return router
+}
+
+func (a *routes) loadUserRoutes(router chi.Router) {
 	userHandler := &handlers.UserHandler{
-		Data: data.UserData{
-			Env:  a.config,
-			DB:   a.datbase,
-			Nats: a.nats,
-		},
+		Data: a.Stores.Users,
+	}
+	invitationHandler := &handlers.InvitationHandler{
+		Data: a.Stores.Invitations,
 	}
 
 	router.Group(func(r chi.Router) {
@@ -55,9 +126,15 @@ func (a *Server) loadUserRoutes(router chi.Router) {
 		r.Get("/", userHandler.SelectUsers)
 		r.Get("/user", userHandler.GetUserInfoByID)
 		r.Get("/access", userHandler.HandleUserAccess)
+		r.Put("/locale", userHandler.SetUserLocale)
+		r.Get("/invitations", invitationHandler.GetUserInvitations)
+		r.Post("/invitations/{invitation_id}/accept", invitationHandler.AcceptInvitation)
+		r.Post("/invitations/{invitation_id}/decline", invitationHandler.DeclineInvitation)
+		r.Get("/blocks", invitationHandler.GetBlockedUsers)
+		r.Delete("/blocks/{user_id}", invitationHandler.UnblockUser)
 
 		r.Group(func(r chi.Router) {
-			r.Use(CustomAccessRoomMiddleware(roomData))
+			r.Use(RequireRoomRole(a.Stores.Rooms, data.PermissionView))
 			r.Get("/rooms/{room_id}", userHandler.GetUsersInRoom)
 		})
 	})
@@ -66,80 +143,145 @@ func (a *Server) loadUserRoutes(router chi.Router) {
 	router.Post("/login", userHandler.Login)
 }
 
-func (a *Server) loadRoomRoutes(router chi.Router) {
-	data := data.RoomData{
-		DB:   a.datbase,
-		Nats: a.nats,
-	}
+func (a *routes) loadRoomRoutes(router chi.Router) {
 	roomHandler := &handlers.RoomHandler{
-		Data: data,
+		Data: a.Stores.Rooms,
+	}
+	historyHandler := &handlers.HistoryHandler{
+		History: a.History,
+	}
+	inviteHandler := &handlers.InviteHandler{
+		Data: a.Stores.Invites,
+	}
+	invitationHandler := &handlers.InvitationHandler{
+		Data: a.Stores.Invitations,
 	}
 
 	router.Get("/", roomHandler.SelectRooms)
 
 	router.Group(func(r chi.Router) {
-		r.Use(CustomAccessRoomMiddleware(data))
+		r.Use(RequireRoomRole(a.Stores.Rooms, data.PermissionView))
 		r.Get("/{room_id}", roomHandler.GetRoomByID)
 		r.Get("/{room_id}/info", roomHandler.GetRoomInfoByID)
 		r.Get("/{room_id}/access", roomHandler.GetRoomAccess)
+		r.Get("/{room_id}/role", roomHandler.GetRoomRole)
 		r.Get("/{room_id}/available-users", roomHandler.GetAvailableUsers)
 		r.Get("/withusers/{room_id}", roomHandler.GetRoomWithUsersByID)
+		r.Get("/{room_id}/history", historyHandler.GetRoomHistory)
+		r.Post("/{room_id}/leave", roomHandler.LeaveRoom)
 
 		r.Get("/{room_id}/access-allowed", func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusOK)
 		})
 	})
 
+	router.Group(func(r chi.Router) {
+		r.Use(RequireRoomRole(a.Stores.Rooms, data.PermissionManageRoom))
+		r.Put("/{room_id}/locale", roomHandler.SetRoomLocale)
+		r.Put("/{room_id}/subscriptions", roomHandler.SetRoomSubscriptions)
+		r.Put("/{room_id}/users/{user_id}/role", roomHandler.SetRoomUserRole)
+		r.Put("/{room_id}", roomHandler.UpdateRoom)
+		r.Patch("/{room_id}", roomHandler.UpdateRoom)
+		r.Post("/{room_id}/archive", roomHandler.ArchiveRoom)
+		r.Post("/{room_id}/unarchive", roomHandler.UnarchiveRoom)
+		r.Delete("/{room_id}/users/{user_id}", roomHandler.RemoveRoomUser)
+		r.Post("/{room_id}/transfer", roomHandler.TransferRoomOwnership)
+	})
+
+	router.Group(func(r chi.Router) {
+		r.Use(RequireRoomRole(a.Stores.Rooms, data.PermissionDelete))
+		r.Delete("/{room_id}", roomHandler.DeleteRoom)
+	})
+
+	router.Group(func(r chi.Router) {
+		r.Use(RequireRoomRole(a.Stores.Rooms, data.PermissionInvite))
+		r.Post("/{room_id}/invites", inviteHandler.CreateInvite)
+		r.Get("/{room_id}/invites", inviteHandler.GetInvitesByRoomID)
+		r.Delete("/{room_id}/invites/{invite_id}", inviteHandler.RevokeInvite)
+		r.Get("/{room_id}/invitations", invitationHandler.GetInvitationsByRoomID)
+		r.Delete("/{room_id}/invitations/{invitation_id}", invitationHandler.CancelInvitation)
+	})
+
 	router.Get("/withusers", roomHandler.ListRoomsWithUsers)
 	router.Get("/users", roomHandler.GetUserRoomsByID)
 
 	router.Post("/", roomHandler.CreateRoom)
-	router.Post("/users", roomHandler.AddUserToRoom)
+	router.Post("/users", invitationHandler.InviteUser)
 
 }
 
-func (a *Server) loadMovieRoutes(router chi.Router) {
+func (a *routes) loadMovieRoutes(router chi.Router) {
 	movieHandler := &handlers.MovieHandler{
-		Data: data.MovieData{
-			Env:  a.config,
-			DB:   a.datbase,
-			Nats: a.nats,
-		},
+		Data: a.Stores.Movies,
 	}
 
 	router.Get("/{movie_id}", movieHandler.GetMovie)
-	router.Get("/{movie_id}/details", movieHandler.GetMovieDetails)
+	router.Get("/tv/{tv_id}", movieHandler.GetTV)
+	router.Get("/imdb/{imdb_id}", movieHandler.FindByIMDbID)
 
-	router.Post("/", movieHandler.CreateMovie)
-	router.Post("/{movie_id}/ratings", movieHandler.RateMovie)
-}
+	router.Group(func(r chi.Router) {
+		r.Use(RequireMovieRole(a.Stores.Movies, data.PermissionView))
+		r.Get("/{movie_id}/details", movieHandler.GetMovieDetails)
+	})
 
-func (a *Server) loadShelfRoutes(router chi.Router) {
-	shelfData := data.ShelfData{
-		Env:  a.config,
-		DB:   a.datbase,
-		Nats: a.nats,
-	}
+	router.Group(func(r chi.Router) {
+		r.Use(RequireMovieRole(a.Stores.Movies, data.PermissionRate))
+		r.Post("/{movie_id}/ratings", movieHandler.RateMovie)
+	})
 
-	roomData := data.RoomData{
-		DB: a.datbase,
-	}
+	router.Group(func(r chi.Router) {
+		r.Use(RequireMovieRole(a.Stores.Movies, data.PermissionAddMovie))
+		r.Post("/{movie_id}/move", movieHandler.MoveMovie)
+		r.Post("/{movie_id}/copy", movieHandler.CopyMovie)
+	})
+
+	router.Group(func(r chi.Router) {
+		r.Use(RequireMovieRole(a.Stores.Movies, data.PermissionDelete))
+		r.Delete("/{movie_id}", movieHandler.RemoveMovie)
+	})
+
+	router.Post("/", movieHandler.CreateMovie)
+}
 
+func (a *routes) loadShelfRoutes(router chi.Router) {
 	shelfHandler := &handlers.ShelfHandler{
-		Data: shelfData,
+		Data: a.Stores.Shelves,
 	}
 
 	router.Group(func(r chi.Router) {
-		r.Use(CustomAccessShelfMiddleware(shelfData))
+		r.Use(RequireShelfRole(a.Stores.Shelves, data.PermissionView))
 		r.Get("/{shelf_id}/movies", shelfHandler.GetShelfMoviesByID)
 		r.Get("/{shelf_id}/info", shelfHandler.GetShelfInfoByID)
 		r.Get("/{shelf_id}/available-movies", shelfHandler.GetAvailableMovies)
 	})
 
 	router.Group(func(r chi.Router) {
-		r.Use(CustomAccessRoomMiddleware(roomData))
+		r.Use(RequireShelfRole(a.Stores.Shelves, data.PermissionCreateShelf))
+		r.Put("/{shelf_id}", shelfHandler.RenameShelf)
+	})
+
+	router.Group(func(r chi.Router) {
+		r.Use(RequireShelfRole(a.Stores.Shelves, data.PermissionDelete))
+		r.Delete("/{shelf_id}", shelfHandler.DeleteShelf)
+	})
+
+	router.Group(func(r chi.Router) {
+		r.Use(RequireRoomRole(a.Stores.Rooms, data.PermissionView))
 		r.Get("/rooms/{room_id}", shelfHandler.GetShelvesByRoomID)
 	})
 
+	router.Group(func(r chi.Router) {
+		r.Use(RequireRoomRole(a.Stores.Rooms, data.PermissionCreateShelf))
+		r.Put("/rooms/{room_id}/order", shelfHandler.ReorderShelves)
+	})
+
 	router.Post("/", shelfHandler.CreateShelf)
 }
+
+func (a *routes) loadInviteRoutes(router chi.Router) {
+	inviteHandler := &handlers.InviteHandler{
+		Data: a.Stores.Invites,
+	}
+
+	router.Post("/{code}", inviteHandler.RedeemInvite)
+}
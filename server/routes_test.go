@@ -0,0 +1,364 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/adamelfsborg-code/movie-nest/config"
+	"github.com/adamelfsborg-code/movie-nest/data"
+	"github.com/adamelfsborg-code/movie-nest/events"
+	"github.com/adamelfsborg-code/movie-nest/pkg/images"
+	"github.com/google/uuid"
+)
+
+type testAPI struct {
+	t      *testing.T
+	router http.Handler
+}
+
+type testUser struct {
+	ID    uuid.UUID
+	Token string
+}
+
+// newTestAPI builds the router over the memory stores, the way demo mode
+// runs it. Images come from a fake CDN serving the same bytes for every
+// path.
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+
+	env := config.Environments{SecretKey: []byte("test-secret")}
+
+	previous := config.Env
+	config.Env = &env
+	t.Cleanup(func() { config.Env = previous })
+
+	bus := events.NewChannelBus()
+	t.Cleanup(func() { bus.Close() })
+
+	history, err := events.NewMemoryHistory(bus, time.Hour)
+	if err != nil {
+		t.Fatalf("NewMemoryHistory: %v", err)
+	}
+	t.Cleanup(func() { history.Close() })
+
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<svg/>"))
+	}))
+	t.Cleanup(cdn.Close)
+
+	imageCache, err := images.NewCache(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("NewCache: %v", err)
+	}
+
+	imageProxy := images.NewProxy(cdn.URL, imageCache)
+	imageProxy.Metrics = nil
+
+	router := NewRouter(Services{
+		Stores:    data.NewMemoryStores(env, data.NewMemoryDB(), bus),
+		History:   history,
+		Images:    imageProxy,
+		SecretKey: env.SecretKey,
+	})
+
+	return &testAPI{t: t, router: router}
+}
+
+// do sends body as JSON and decodes the response into out when it is set.
+func (a *testAPI) do(method, path, token string, body, out interface{}) int {
+	a.t.Helper()
+
+	var reader bytes.Buffer
+	if body != nil {
+		err := json.NewEncoder(&reader).Encode(body)
+		if err != nil {
+			a.t.Fatalf("Encode: %v", err)
+		}
+	}
+
+	request := httptest.NewRequest(method, path, &reader)
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	recorder := httptest.NewRecorder()
+	a.router.ServeHTTP(recorder, request)
+
+	if out != nil && recorder.Code < 300 {
+		err := json.Unmarshal(recorder.Body.Bytes(), out)
+		if err != nil {
+			a.t.Fatalf("%v %v: Failed to decode %q: %v", method, path, recorder.Body.String(), err)
+		}
+	}
+
+	return recorder.Code
+}
+
+// expect fails the test unless the request is answered with status.
+func (a *testAPI) expect(status int, method, path, token string, body, out interface{}) {
+	a.t.Helper()
+
+	got := a.do(method, path, token, body, out)
+	if got != status {
+		a.t.Fatalf("%v %v = %v, want %v", method, path, got, status)
+	}
+}
+
+func (a *testAPI) register(name string) testUser {
+	a.t.Helper()
+
+	credentials := map[string]string{"name": name, "password": "correct-horse"}
+	a.expect(http.StatusCreated, "POST", "/users/register", "", credentials, nil)
+
+	var login struct {
+		Token string `json:"token"`
+	}
+	a.expect(http.StatusOK, "POST", "/users/login", "", credentials, &login)
+
+	var user data.User
+	a.expect(http.StatusOK, "GET", "/users/user", login.Token, nil, &user)
+
+	return testUser{ID: user.ID, Token: login.Token}
+}
+
+func (a *testAPI) createRoom(owner testUser, name string) uuid.UUID {
+	a.t.Helper()
+
+	a.expect(http.StatusCreated, "POST", "/rooms/", owner.Token, map[string]string{"name": name}, nil)
+
+	var rooms []data.Room
+	a.expect(http.StatusOK, "GET", "/rooms/users", owner.Token, nil, &rooms)
+	for _, room := range rooms {
+		if room.Name == name {
+			return room.ID
+		}
+	}
+
+	a.t.Fatalf("Room %v is not among the owner's rooms %+v", name, rooms)
+	return uuid.Nil
+}
+
+func (a *testAPI) join(roomID uuid.UUID, owner, user testUser, role data.Role) {
+	a.t.Helper()
+
+	var invitation data.RoomInvitation
+	a.expect(http.StatusCreated, "POST", "/rooms/users", owner.Token, map[string]interface{}{
+		"room_id": roomID,
+		"user_id": user.ID,
+		"role":    role,
+	}, &invitation)
+
+	a.expect(http.StatusOK, "POST", "/users/invitations/"+invitation.ID.String()+"/accept", user.Token, nil, nil)
+}
+
+func TestRouterAuthentication(t *testing.T) {
+	api := newTestAPI(t)
+	user := api.register("alice")
+
+	ticket := struct {
+		Ticket string `json:"ticket"`
+	}{}
+	api.expect(http.StatusCreated, "POST", "/ws/tickets", user.Token, nil, &ticket)
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"invalid token", "not-a-token", http.StatusUnauthorized},
+		{"websocket ticket", ticket.Ticket, http.StatusUnauthorized},
+		{"login token", user.Token, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		got := api.do("GET", "/rooms/users", test.token, nil, nil)
+		if got != test.status {
+			t.Errorf("%v: GET /rooms/users = %v, want %v", test.name, got, test.status)
+		}
+	}
+
+	api.expect(http.StatusBadRequest, "POST", "/users/login", "", map[string]string{"name": "alice", "password": "wrong"}, nil)
+}
+
+func TestRouterRoomRoles(t *testing.T) {
+	api := newTestAPI(t)
+	owner := api.register("owner")
+	viewer := api.register("viewer")
+	outsider := api.register("outsider")
+
+	roomID := api.createRoom(owner, "Movie night")
+	api.join(roomID, owner, viewer, data.RoleViewer)
+
+	room := "/rooms/" + roomID.String()
+	rename := map[string]string{"name": "Film club"}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		user   testUser
+		body   interface{}
+		status int
+	}{
+		{"outsider views", "GET", room, outsider, nil, http.StatusForbidden},
+		{"viewer views", "GET", room, viewer, nil, http.StatusOK},
+		{"viewer renames", "PATCH", room, viewer, rename, http.StatusForbidden},
+		{"viewer creates shelf order", "PUT", "/shelves/rooms/" + roomID.String() + "/order", viewer, map[string][]uuid.UUID{"shelf_ids": {}}, http.StatusForbidden},
+		{"viewer invites", "GET", room + "/invites", viewer, nil, http.StatusForbidden},
+		{"viewer deletes", "DELETE", room, viewer, nil, http.StatusForbidden},
+		{"owner renames", "PATCH", room, owner, rename, http.StatusOK},
+		{"invalid room id", "GET", "/rooms/not-a-room", owner, nil, http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		got := api.do(test.method, test.path, test.user.Token, test.body, nil)
+		if got != test.status {
+			t.Errorf("%v: %v %v = %v, want %v", test.name, test.method, test.path, got, test.status)
+		}
+	}
+
+	var renamed data.Room
+	api.expect(http.StatusOK, "GET", room, viewer.Token, nil, &renamed)
+	if renamed.Name != "Film club" {
+		t.Errorf("Room name = %q, want %q", renamed.Name, "Film club")
+	}
+
+	var role struct {
+		Role data.Role `json:"role"`
+	}
+	api.expect(http.StatusOK, "GET", room+"/role", viewer.Token, nil, &role)
+	if role.Role != data.RoleViewer {
+		t.Errorf("Viewer's role = %v, want %v", role.Role, data.RoleViewer)
+	}
+}
+
+func TestRouterShelves(t *testing.T) {
+	api := newTestAPI(t)
+	owner := api.register("owner")
+	member := api.register("member")
+
+	roomID := api.createRoom(owner, "Movie night")
+	api.join(roomID, owner, member, data.RoleMember)
+
+	for _, name := range []string{"Watchlist", "Watched"} {
+		api.expect(http.StatusCreated, "POST", "/shelves/", member.Token, map[string]interface{}{"name": name, "room_id": roomID}, nil)
+	}
+
+	shelvesPath := "/shelves/rooms/" + roomID.String()
+
+	var shelves []data.Shelf
+	api.expect(http.StatusOK, "GET", shelvesPath, member.Token, nil, &shelves)
+	if len(shelves) != 2 || shelves[0].Name != "Watchlist" || shelves[1].Name != "Watched" {
+		t.Fatalf("Shelves = %+v, want Watchlist and Watched in order", shelves)
+	}
+
+	watchlist := "/shelves/" + shelves[0].ID.String()
+
+	var renamed data.Shelf
+	api.expect(http.StatusOK, "PUT", watchlist, member.Token, map[string]string{"name": "Up next"}, &renamed)
+	if renamed.Name != "Up next" {
+		t.Errorf("Renamed shelf = %+v, want Up next", renamed)
+	}
+
+	api.expect(http.StatusOK, "PUT", shelvesPath+"/order", member.Token, map[string][]uuid.UUID{
+		"shelf_ids": {shelves[1].ID, shelves[0].ID},
+	}, nil)
+
+	api.expect(http.StatusOK, "GET", shelvesPath, member.Token, nil, &shelves)
+	if len(shelves) != 2 || shelves[0].Name != "Watched" {
+		t.Errorf("Shelves after reordering = %+v, want Watched first", shelves)
+	}
+
+	// Invalid searches are rejected before TMDB is asked.
+	api.expect(http.StatusBadRequest, "GET", watchlist+"/available-movies?mediaType=book", member.Token, nil, nil)
+	api.expect(http.StatusBadRequest, "GET", watchlist+"/available-movies?discover=true&mediaType=tv", member.Token, nil, nil)
+
+	// Members can't delete shelves, the owner can.
+	api.expect(http.StatusForbidden, "DELETE", watchlist, member.Token, nil, nil)
+	api.expect(http.StatusOK, "DELETE", watchlist, owner.Token, nil, nil)
+	api.expect(http.StatusForbidden, "GET", watchlist+"/info", owner.Token, nil, nil)
+
+	// Archived rooms are read-only.
+	api.expect(http.StatusOK, "POST", "/rooms/"+roomID.String()+"/archive", owner.Token, nil, nil)
+	api.expect(http.StatusConflict, "POST", "/shelves/", owner.Token, map[string]interface{}{"name": "Later", "room_id": roomID}, nil)
+}
+
+func TestRouterHistory(t *testing.T) {
+	api := newTestAPI(t)
+	owner := api.register("owner")
+	roomID := api.createRoom(owner, "Movie night")
+
+	api.expect(http.StatusCreated, "POST", "/shelves/", owner.Token, map[string]interface{}{"name": "Watchlist", "room_id": roomID}, nil)
+
+	path := "/rooms/" + roomID.String() + "/history"
+	api.expect(http.StatusBadRequest, "GET", path+"?cursor=first", owner.Token, nil, nil)
+
+	// The memory history records events as the bus delivers them.
+	deadline := time.Now().Add(time.Second * 5)
+	for {
+		var page struct {
+			Events     []json.RawMessage `json:"events"`
+			HasMore    bool              `json:"has_more"`
+			NextCursor string            `json:"next_cursor"`
+		}
+		api.expect(http.StatusOK, "GET", path+"?limit=1", owner.Token, nil, &page)
+
+		if len(page.Events) > 0 {
+			if page.NextCursor == "" || page.NextCursor == "0" {
+				t.Errorf("History page = %+v, want a cursor", page)
+			}
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("History has no events")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func TestRouterImages(t *testing.T) {
+	api := newTestAPI(t)
+
+	request := httptest.NewRequest("GET", "/images/original/logo.svg", nil)
+	recorder := httptest.NewRecorder()
+	api.router.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "<svg/>" {
+		t.Fatalf("GET = %v %q, want the svg", recorder.Code, recorder.Body.String())
+	}
+
+	headers := map[string]string{
+		"Content-Type":            "image/svg+xml",
+		"X-Content-Type-Options":  "nosniff",
+		"Content-Security-Policy": "default-src 'none'; style-src 'unsafe-inline'; sandbox",
+	}
+	for name, want := range headers {
+		if got := recorder.Header().Get(name); got != want {
+			t.Errorf("%v = %q, want %q", name, got, want)
+		}
+	}
+
+	etag := recorder.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("Response has no ETag")
+	}
+
+	request = httptest.NewRequest("GET", "/images/original/logo.svg", nil)
+	request.Header.Set("If-None-Match", etag)
+	recorder = httptest.NewRecorder()
+	api.router.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusNotModified || recorder.Body.Len() != 0 {
+		t.Errorf("GET with the ETag = %v %q, want 304 without a body", recorder.Code, recorder.Body.String())
+	}
+
+	api.expect(http.StatusBadRequest, "GET", "/images/w343/logo.svg", "", nil, nil)
+	api.expect(http.StatusBadRequest, "GET", "/images/original/logo.gif", "", nil, nil)
+}
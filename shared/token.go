@@ -0,0 +1,118 @@
+package shared
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// TokenCookie holds the login token for clients that cannot set headers,
+// such as browser EventSources.
+const TokenCookie = "movie_nest_token"
+
+// TicketTTL is how long a websocket ticket can be redeemed.
+const TicketTTL = time.Second * 30
+
+const ticketAudience = "websocket"
+
+// Ticket lets a browser open a websocket without putting its login token
+// in the url. It is short-lived and meant to be redeemed once.
+type Ticket struct {
+	ID        string
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+}
+
+// ParseUserToken validates a token signed at login and returns the id of
+// the user it was issued to.
+func ParseUserToken(secretKey []byte, tokenString string) (uuid.UUID, error) {
+	claims, err := parseClaims(secretKey, tokenString)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	// Tickets are signed with the same key but are no login tokens.
+	if _, exists := claims["aud"]; exists {
+		return uuid.Nil, fmt.Errorf("Invalid token audience")
+	}
+
+	return parseSubject(claims)
+}
+
+func NewTicket(secretKey []byte, userID uuid.UUID) (string, *Ticket, error) {
+	ticket := &Ticket{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(TicketTTL),
+	}
+
+	claims := jwt.MapClaims{
+		"sub": userID,
+		"aud": ticketAudience,
+		"jti": ticket.ID,
+		"exp": ticket.ExpiresAt.Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString(secretKey)
+	if err != nil {
+		return "", nil, err
+	}
+	return tokenString, ticket, nil
+}
+
+func ParseTicket(secretKey []byte, tokenString string) (*Ticket, error) {
+	claims, err := parseClaims(secretKey, tokenString, jwt.WithAudience(ticketAudience))
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := parseSubject(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := claims["jti"].(string)
+	if !ok || id == "" {
+		return nil, fmt.Errorf("Invalid ticket id")
+	}
+
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		return nil, fmt.Errorf("Invalid ticket expiry")
+	}
+
+	return &Ticket{
+		ID:        id,
+		UserID:    userID,
+		ExpiresAt: expiresAt.Time,
+	}, nil
+}
+
+func parseClaims(secretKey []byte, tokenString string, options ...jwt.ParserOption) (jwt.MapClaims, error) {
+	options = append(options, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return secretKey, nil
+	}, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("Invalid token")
+	}
+	return claims, nil
+}
+
+func parseSubject(claims jwt.MapClaims) (uuid.UUID, error) {
+	subject, ok := claims["sub"].(string)
+	if !ok {
+		return uuid.Nil, fmt.Errorf("Invalid token subject")
+	}
+
+	return uuid.Parse(subject)
+}